@@ -0,0 +1,145 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// Lengths of the client and server cookies in bytes (RFC 7873).
+	clientCookieLen = 8
+	serverCookieLen = 16
+
+	// Max age of a server cookie after which a fresh one is issued.
+	cookieMaxAge = time.Hour
+)
+
+// cookies generates and validates DNS server cookies (RFC 7873). The server
+// cookie follows the layout of RFC 9018: version (1), reserved (3),
+// timestamp (4), and a hash (8) of the client cookie and IP, keyed
+// with a secret.
+type cookies struct {
+	secret []byte
+}
+
+// cookieWriter is a dns.ResponseWriter that adds the server cookie
+// to the responses.
+type cookieWriter struct {
+	dns.ResponseWriter
+
+	cookie string
+}
+
+// newCookies returns a cookie generator. If the secret is empty, a random
+// one is generated, which invalidates cookies across restarts and instances.
+func newCookies(secret string) (*cookies, error) {
+	b := []byte(secret)
+	if len(b) == 0 {
+		b = make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+	}
+
+	return &cookies{secret: b}, nil
+}
+
+// wrap returns a ResponseWriter that echoes the client cookie in the query
+// along with a server cookie. It returns false if the cookie option is
+// malformed, in which case the query should get a FORMERR.
+func (c *cookies) wrap(w dns.ResponseWriter, r *dns.Msg) (dns.ResponseWriter, bool) {
+	opt := r.IsEdns0()
+	if opt == nil {
+		return w, true
+	}
+
+	var in *dns.EDNS0_COOKIE
+	for _, o := range opt.Option {
+		if e, ok := o.(*dns.EDNS0_COOKIE); ok {
+			in = e
+			break
+		}
+	}
+	if in == nil {
+		return w, true
+	}
+
+	b, err := hex.DecodeString(in.Cookie)
+	if err != nil || len(b) < clientCookieLen || (len(b) > clientCookieLen && (len(b) < clientCookieLen+8 || len(b) > clientCookieLen+32)) {
+		return w, false
+	}
+
+	var (
+		client = b[:clientCookieLen]
+		ip     = net.ParseIP(hostIP(w.RemoteAddr().String()))
+		server = b[clientCookieLen:]
+	)
+
+	// Reuse the client's server cookie if it's valid and fresh. Otherwise,
+	// issue a new one. Queries with invalid cookies are still answered
+	// as a client may have a stale cookie (RFC 7873, 5.2.3).
+	if !c.valid(client, server, ip) {
+		server = c.generate(client, ip, time.Now())
+	}
+
+	return &cookieWriter{ResponseWriter: w, cookie: hex.EncodeToString(append(client, server...))}, true
+}
+
+// generate generates a server cookie for a client cookie and IP.
+func (c *cookies) generate(client []byte, ip net.IP, t time.Time) []byte {
+	out := make([]byte, 8, serverCookieLen)
+
+	// Version 1, reserved, and the timestamp.
+	out[0] = 1
+	binary.BigEndian.PutUint32(out[4:8], uint32(t.Unix()))
+
+	return append(out, c.hash(client, out, ip)...)
+}
+
+// valid checks if a server cookie was issued by the server for the
+// client cookie and IP and hasn't expired.
+func (c *cookies) valid(client, server []byte, ip net.IP) bool {
+	if len(server) != serverCookieLen || server[0] != 1 {
+		return false
+	}
+
+	ts := time.Unix(int64(binary.BigEndian.Uint32(server[4:8])), 0)
+	if age := time.Since(ts); age > cookieMaxAge || age < -5*time.Minute {
+		return false
+	}
+
+	return hmac.Equal(server[8:], c.hash(client, server[:8], ip))
+}
+
+// hash returns the keyed hash for a server cookie.
+func (c *cookies) hash(client, prefix []byte, ip net.IP) []byte {
+	h := hmac.New(sha256.New, c.secret)
+	h.Write(client)
+	h.Write(prefix)
+	h.Write(ip)
+
+	return h.Sum(nil)[:8]
+}
+
+// WriteMsg adds the cookie to the response's OPT record and writes it.
+func (w *cookieWriter) WriteMsg(m *dns.Msg) error {
+	opt := m.IsEdns0()
+	if opt == nil {
+		m.SetEdns0(dns.DefaultMsgSize, false)
+		opt = m.IsEdns0()
+	}
+
+	opt.Option = append(opt.Option, &dns.EDNS0_COOKIE{
+		Code:   dns.EDNS0COOKIE,
+		Cookie: w.cookie,
+	})
+
+	return w.ResponseWriter.WriteMsg(m)
+}
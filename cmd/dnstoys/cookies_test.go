@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// cookieQuery sends a query with a cookie option (hex) from an address
+// and returns the response and the cookie in it.
+func cookieQuery(t *testing.T, f dns.HandlerFunc, addr net.Addr, cookie string) (*dns.Msg, string) {
+	t.Helper()
+
+	r := &dns.Msg{}
+	r.SetQuestion("x.test.", dns.TypeTXT)
+	if cookie != "" {
+		r.SetEdns0(dns.DefaultMsgSize, false)
+		opt := r.IsEdns0()
+		opt.Option = append(opt.Option, &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: cookie})
+	}
+
+	w := &testWriter{addr: addr}
+	f(w, r)
+	if w.msg == nil {
+		t.Fatal("no response")
+	}
+
+	if opt := w.msg.IsEdns0(); opt != nil {
+		for _, o := range opt.Option {
+			if c, ok := o.(*dns.EDNS0_COOKIE); ok {
+				return w.msg, c.Cookie
+			}
+		}
+	}
+
+	return w.msg, ""
+}
+
+func TestCookies(t *testing.T) {
+	c, err := newCookies("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := newTestHandlers()
+	h.cookies = c
+	f := h.handle("test", svcFunc(func(ctx context.Context, q string) ([]string, error) {
+		return []string{q + " 1 TXT \"ok\""}, nil
+	}))
+
+	var (
+		client = "0102030405060708"
+		addr1  = &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 5353}
+		addr2  = &net.UDPAddr{IP: net.ParseIP("192.0.2.2"), Port: 5353}
+	)
+
+	// No cookie in the query, no cookie in the response.
+	if _, ck := cookieQuery(t, f, addr1, ""); ck != "" {
+		t.Fatalf("unexpected cookie: %s", ck)
+	}
+
+	// A client cookie gets the client cookie echoed with a server cookie.
+	m, ck := cookieQuery(t, f, addr1, client)
+	if m.Rcode != dns.RcodeSuccess || len(m.Answer) != 1 {
+		t.Fatalf("unexpected response: %v", m)
+	}
+	if len(ck) != (clientCookieLen+serverCookieLen)*2 || ck[:len(client)] != client {
+		t.Fatalf("unexpected cookie: %s", ck)
+	}
+
+	// The server cookie round trips.
+	if _, ck2 := cookieQuery(t, f, addr1, ck); ck2 != ck {
+		t.Fatalf("expected the server cookie to be reused, got %s, want %s", ck2, ck)
+	}
+
+	// It's not valid for another client IP, which gets a new one.
+	_, ck3 := cookieQuery(t, f, addr2, ck)
+	if ck3 == ck || ck3[:len(client)] != client {
+		t.Fatalf("expected a new server cookie for another IP, got %s", ck3)
+	}
+
+	// Nor for another secret.
+	c2, _ := newCookies("other secret")
+	b, _ := hex.DecodeString(ck)
+	if c2.valid(b[:clientCookieLen], b[clientCookieLen:], addr1.IP) {
+		t.Fatal("cookie is valid with another secret")
+	}
+
+	// Expired server cookies are replaced.
+	cb, _ := hex.DecodeString(client)
+	old := hex.EncodeToString(append(cb, c.generate(cb, addr1.IP, time.Now().Add(-cookieMaxAge*2))...))
+	if _, ck4 := cookieQuery(t, f, addr1, old); ck4 == old || len(ck4) != len(ck) {
+		t.Fatalf("expected a fresh server cookie, got %s", ck4)
+	}
+}
+
+func TestCookiesMalformed(t *testing.T) {
+	c, _ := newCookies("")
+	h := newTestHandlers()
+	h.cookies = c
+	f := h.handle("test", svcFunc(func(ctx context.Context, q string) ([]string, error) {
+		return []string{q + " 1 TXT \"ok\""}, nil
+	}))
+
+	addr := &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 5353}
+	for _, ck := range []string{
+		// Short client cookie.
+		"01020304",
+
+		// Server cookie shorter than 8 or longer than 32 bytes.
+		"0102030405060708" + "0102",
+		"0102030405060708" + hex.EncodeToString(make([]byte, 33)),
+	} {
+		m, _ := cookieQuery(t, f, addr, ck)
+		if m.Rcode != dns.RcodeFormatError {
+			t.Errorf("%s: expected FORMERR, got %s", ck, dns.RcodeToString[m.Rcode])
+		}
+	}
+}
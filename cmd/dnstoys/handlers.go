@@ -1,132 +1,712 @@
 package main
 
 import (
-	"errors"
+	"context"
+	"encoding/json"
 	"fmt"
 	"log"
+	"math"
+	"math/rand"
+	"net"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/miekg/dns"
+
+	"github.com/knadh/dns.toys/internal/errcode"
+	"github.com/knadh/dns.toys/internal/extra"
 )
 
 // Service represents a Service that responds to a particular kind
-// of DNS query.
+// of DNS query. The context passed to Query() carries the per-query
+// deadline and should be honoured by long running services.
 type Service interface {
-	Query(string) ([]string, error)
+	Query(context.Context, string) ([]string, error)
 	Dump() ([]byte, error)
 }
 
 type handlers struct {
-	services map[string]Service
-	domain   string
-	help     []dns.RR
+	services     map[string]Service
+	domain       string
+	queryTimeout time.Duration
+	help         []dns.RR
+
+	// Max answer records in a response. 0 for no limit.
+	maxAnswers int
+
+	// Queries that take longer than this are logged. 0 to disable.
+	slowThreshold time.Duration
+
+	// Per-service compute budgets that are tighter than the query timeout.
+	budgets map[Service]time.Duration
+
+	// TTL range (seconds) that the answers' TTLs are clamped to.
+	// A maxTTL of 0 is no ceiling.
+	minTTL, maxTTL uint32
+
+	// Allow queries to ask for a TTL, eg: berlin/ttl=60.weather, to
+	// test resolvers. Not meant for production.
+	allowTTLOverride bool
+
+	// Services whose answers are shuffled in every response, and the shuffle
+	// function. nil for rand.Shuffle.
+	shuffle   map[Service]bool
+	shuffleFn func(n int, swap func(i, j int))
+
+	// Max fraction (eg: 0.1 for 10%) by which the TTLs of a response are
+	// randomly perturbed. 0 to disable.
+	ttlJitter float64
+
+	// DNS cookie (RFC 7873) generator. nil if disabled.
+	cookies *cookies
+
+	// Block size to pad responses over TLS to (RFC 7830). 0 to disable.
+	padding int
+
+	// Set to 1 when the server is draining before shutdown.
+	draining int32
+
+	// Handler for the subnet calculator queries on the ip. suffix,
+	// eg: 192.168.1.37/26.ip. nil if disabled.
+	ipcalc dns.HandlerFunc
+
+	// Query suffixes that are answered by another service => the service,
+	// eg: aliases (forecast => weather), and ip => ipcalc without the IP echo.
+	names map[string]string
+
+	// CHAOS class names (version.bind etc.) => the values to respond with.
+	// nil to refuse the queries.
+	chaos map[string]string
+
+	// Optional banner lines prepended to help (and default) responses.
+	banner          []dns.RR
+	bannerOnDefault bool
+
+	// Optional description of the instance that's returned for the TXT
+	// query on the domain apex instead of the default one.
+	apexTXT []string
+
+	// Response to queries for known services that are disabled.
+	disabledMsg string
+
+	// Number of malformed messages received (atomic), and whether to log them.
+	malformed    uint64
+	logMalformed bool
 }
 
-var reClean = regexp.MustCompile("[^a-zA-Z0-9/\\-\\.:]")
+// Letters, numbers, combining marks, symbols (eg: emoji), the zero width
+// joiner in emoji sequences, argument separators, and arithmetic operators
+// and parentheses (for calc) are allowed in queries.
+var reClean = regexp.MustCompile("[^\\p{L}\\p{N}\\p{M}\\p{So}\\p{Sk}\\x{200d}/\\-\\.:,_+*()]")
+
+// TTL override in a query, eg: berlin/ttl=60.weather.
+var reTTLOverride = regexp.MustCompile(`(?i)/ttl=([0-9]{1,9})`)
+
+// Parentheses group lines in the zone file format that records are
+// parsed from, so they're escaped in owner names that come from queries.
+var ownerEscaper = strings.NewReplacer("(", "\\(", ")", "\\)")
+
+var errBudget = errcode.New(errcode.Limit, "computation too expensive, lower the input.")
 
 // register registers a Service for a given query suffix on the DNS server.
 // A Service responds to a DNS query via Query().
 func (h *handlers) register(suffix string, s Service, mux *dns.ServeMux) func(w dns.ResponseWriter, r *dns.Msg) {
-	f := func(w dns.ResponseWriter, r *dns.Msg) {
+	f := h.handle(suffix, s)
+
+	h.services[suffix] = s
+	mux.HandleFunc(suffix+".", f)
+	return f
+}
+
+// registerAlias registers an alternate query suffix for an already
+// registered Service, eg: forecast. for weather.
+func (h *handlers) registerAlias(alias, suffix string, mux *dns.ServeMux) error {
+	s, ok := h.services[suffix]
+	if !ok {
+		return fmt.Errorf("unknown service '%s' for alias '%s'", suffix, alias)
+	}
+
+	if _, ok := h.services[alias]; ok {
+		return fmt.Errorf("alias '%s' conflicts with an existing service", alias)
+	}
+
+	mux.HandleFunc(alias+".", h.handle(alias, s))
+	h.names[alias] = suffix
+	return nil
+}
+
+// handle returns a DNS handler that executes a Service on the questions
+// in a DNS query and writes the responses.
+func (h *handlers) handle(suffix string, s Service) func(w dns.ResponseWriter, r *dns.Msg) {
+	return func(w dns.ResponseWriter, r *dns.Msg) {
 		m := &dns.Msg{}
 		m.SetReply(r)
 		m.Compress = false
 
+		// Pad responses over TLS. This wraps the writer before the cookies
+		// so that the padding is computed last.
+		if h.padding > 0 {
+			w = wrapPadding(w, r, h.padding)
+		}
+
+		// Truncate oversized UDP responses so that clients retry over TCP.
+		w = wrapTruncate(w, r)
+
+		// Echo the client cookie with a server cookie in the responses.
+		if h.cookies != nil {
+			cw, ok := h.cookies.wrap(w, r)
+			if !ok {
+				respFormErr(w, m)
+				return
+			}
+			w = cw
+		}
+
 		if r.Opcode != dns.OpcodeQuery {
 			w.WriteMsg(m)
 			return
 		}
 
-		if len(m.Question) > 5 {
-			respErr(errors.New("too many queries."), w, m)
+		// The mux routes a message by its first question, so the other
+		// questions may be for another service. Multi-question messages
+		// are practically unsupported by DNS implementations (RFC 9619),
+		// so reject them.
+		if len(r.Question) != 1 {
+			respFormErr(w, m)
 			return
 		}
 
-		// Execute the service on all the questions.
-		out := []dns.RR{}
-		for _, q := range m.Question {
-			if q.Qtype != dns.TypeTXT && q.Qtype != dns.TypeA {
-				continue
+		q := m.Question[0]
+		if q.Qtype != dns.TypeTXT && q.Qtype != dns.TypeA && q.Qtype != dns.TypeAAAA {
+			w.WriteMsg(m)
+			return
+		}
+
+		timeout, budgeted := h.queryTimeout, false
+		if budget, ok := h.budgets[s]; ok && budget < timeout {
+			timeout, budgeted = budget, true
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		// Call the service with the incoming query.
+		// Strip the service suffix from the query eg: mumbai.time.
+		// Strip the optional TTL override before the query is cleaned
+		// as = isn't allowed in queries.
+		name, ttl := q.Name, -1
+		if h.allowTTLOverride {
+			name, ttl = parseTTLOverride(name)
+		}
+
+		start := time.Now()
+		ans, err := query(ctx, s, cleanQuery(name, "."+suffix+"."))
+		if took := time.Since(start); h.slowThreshold > 0 && took >= h.slowThreshold {
+			lo.Printf("slow query: %s %s %s took %v", suffix, q.Name, dns.TypeToString[q.Qtype], took.Round(time.Microsecond))
+		}
+		if err != nil {
+			// Running out of the compute budget is the input's fault.
+			if budgeted && errcode.Of(err) == errcode.Timeout {
+				err = errBudget
 			}
+			respErr(err, w, m)
+			return
+		}
 
-			// Call the service with the incoming query.
-			// Strip the service suffix from the query eg: mumbai.time.
-			ans, err := s.Query(cleanQuery(q.Name, "."+suffix+"."))
-			if err != nil {
-				respErr(err, w, m)
-				return
+		// Convert string responses to dns.RR{}. Supplementary records,
+		// eg: debug info, go in the additional section.
+		ans, ext := extra.Split(ans)
+		o, err := makeResp(ans)
+		if err != nil {
+			log.Printf("error preparing response: %v", err)
+			respErr(errcode.New(errcode.Internal, "error preparing response."), w, m)
+			return
+		}
+		out := filterType(o, q.Qtype)
+
+		oe, err := makeResp(ext)
+		if err != nil {
+			log.Printf("error preparing response: %v", err)
+			respErr(errcode.New(errcode.Internal, "error preparing response."), w, m)
+			return
+		}
+
+		// Randomize the order of equivalent answers, eg: for load spreading.
+		if h.shuffle[s] {
+			shuffle := rand.Shuffle
+			if h.shuffleFn != nil {
+				shuffle = h.shuffleFn
 			}
+			shuffle(len(out), func(i, j int) {
+				out[i], out[j] = out[j], out[i]
+			})
+		}
 
-			// Convert string responses to dns.RR{}.
-			o, err := makeResp(ans)
+		// Cap the number of answers to prevent oversized responses.
+		if h.maxAnswers > 0 && len(out) > h.maxAnswers {
+			r, err := dns.NewRR(fmt.Sprintf("%s 1 TXT \"results truncated to %d.\"", q.Name, h.maxAnswers))
 			if err != nil {
 				log.Printf("error preparing response: %v", err)
-				respErr(errors.New("error preparing response."), w, m)
+				respErr(errcode.New(errcode.Internal, "error preparing response."), w, m)
 				return
 			}
 
-			out = append(out, o...)
+			out = append(out[:h.maxAnswers], r)
 		}
 
-		// Write the response.
-		m.Answer = out
+		// Write the response. The TTLs of all the records in a response are
+		// perturbed by the same random offset so that downstream caches
+		// don't all expire at once, and then clamped so that the jitter
+		// doesn't push them out of the configured range.
+		j := h.jitter()
+		if ttl >= 0 {
+			setTTL(out, uint32(ttl))
+			setTTL(oe, uint32(ttl))
+			j = 1
+		}
+		m.Answer = h.clampTTL(jitterTTL(out, j))
+		m.Extra = append(m.Extra, h.clampTTL(jitterTTL(oe, j))...)
 		w.WriteMsg(m)
 	}
+}
 
-	h.services[suffix] = s
-	mux.HandleFunc(suffix+".", f)
-	return f
+// clampTTL clamps the TTLs of records into the configured range,
+// regardless of what the services set.
+func (h *handlers) clampTTL(rr []dns.RR) []dns.RR {
+	for _, r := range rr {
+		hdr := r.Header()
+		if hdr.Ttl < h.minTTL {
+			hdr.Ttl = h.minTTL
+		}
+		if h.maxTTL > 0 && hdr.Ttl > h.maxTTL {
+			hdr.Ttl = h.maxTTL
+		}
+	}
+
+	return rr
+}
+
+// parseTTLOverride strips a TTL override, eg: /ttl=60, from a query name and
+// returns the TTL. The TTL is -1 if there's no override.
+func parseTTLOverride(name string) (string, int) {
+	m := reTTLOverride.FindStringSubmatchIndex(name)
+	if m == nil {
+		return name, -1
+	}
+
+	ttl, err := strconv.Atoi(name[m[2]:m[3]])
+	if err != nil {
+		return name, -1
+	}
+
+	return name[:m[0]] + name[m[1]:], ttl
+}
+
+// setTTL sets the TTLs of records.
+func setTTL(rr []dns.RR, ttl uint32) {
+	for _, r := range rr {
+		r.Header().Ttl = ttl
+	}
+}
+
+// jitter returns a random TTL multiplier within the configured jitter,
+// eg: 0.9 - 1.1 for 10%.
+func (h *handlers) jitter() float64 {
+	if h.ttlJitter == 0 {
+		return 1
+	}
+
+	return 1 + (rand.Float64()*2-1)*h.ttlJitter
+}
+
+// jitterTTL multiplies the TTLs of records by j.
+func jitterTTL(rr []dns.RR, j float64) []dns.RR {
+	if j == 1 {
+		return rr
+	}
+
+	for _, r := range rr {
+		hdr := r.Header()
+		hdr.Ttl = uint32(math.Round(float64(hdr.Ttl) * j))
+	}
+
+	return rr
+}
+
+// query executes a Service's Query() and returns an error if the Service
+// doesn't respond before the context's deadline.
+func query(ctx context.Context, s Service, q string) ([]string, error) {
+	type result struct {
+		ans []string
+		err error
+	}
+
+	ch := make(chan result, 1)
+	go func() {
+		ans, err := s.Query(ctx, q)
+		ch <- result{ans: ans, err: err}
+	}()
+
+	select {
+	case r := <-ch:
+		return r.ans, r.err
+	case <-ctx.Done():
+		return nil, errcode.New(errcode.Timeout, "query timed out.")
+	}
 }
 
 // handleEchoIP returns the client's IP address as a DNS response.
 // Although it is a service, it's not registered like a Service as it
 // uses w.RemoteAddr() instead of m.Question unlike a typical service.
+// json.ip returns the IP, its family, and reverse name as a JSON string.
 func (h *handlers) handleEchoIP(w dns.ResponseWriter, r *dns.Msg) {
+	// Address/prefix queries are for the subnet calculator.
+	if h.ipcalc != nil && len(r.Question) == 1 && strings.Contains(r.Question[0].Name, "/") {
+		h.ipcalc(w, r)
+		return
+	}
+
 	m := &dns.Msg{}
 	m.SetReply(r)
 	m.Compress = false
 
-	for _, q := range m.Question {
-		if q.Qtype != dns.TypeTXT && q.Qtype != dns.TypeA {
-			continue
+	if len(r.Question) != 1 {
+		respFormErr(w, m)
+		return
+	}
+
+	q := m.Question[0]
+	if q.Qtype != dns.TypeTXT && q.Qtype != dns.TypeA && q.Qtype != dns.TypeAAAA && q.Qtype != dns.TypeANY {
+		w.WriteMsg(m)
+		return
+	}
+
+	ip := net.ParseIP(hostIP(w.RemoteAddr().String()))
+	if ip == nil {
+		respErr(errcode.New(errcode.Internal, "unable to detect IP."), w, m)
+		return
+	}
+
+	// The address record is for machines and the TXT for humans. A and AAAA
+	// queries get the record of the client's family, TXT queries get the
+	// TXT, and ANY queries get both.
+	var (
+		v4   = ip.To4() != nil
+		addr = q.Qtype == dns.TypeANY || (q.Qtype == dns.TypeA && v4) || (q.Qtype == dns.TypeAAAA && !v4)
+		txt  = q.Qtype == dns.TypeTXT || q.Qtype == dns.TypeANY
+	)
+
+	if addr {
+		t := "AAAA"
+		if v4 {
+			t = "A"
 		}
 
-		a := strings.Split(w.RemoteAddr().String(), ":")
-		if len(a) != 2 {
-			respErr(errors.New("unable to detect IP."), w, m)
-			w.WriteMsg(m)
+		rr, err := dns.NewRR(fmt.Sprintf("%s 1 %s %s", q.Name, t, ip))
+		if err != nil {
+			lo.Printf("error preparing ip response: %v", err)
 			return
 		}
+		m.Answer = append(m.Answer, rr)
+	}
 
-		rr, err := dns.NewRR(fmt.Sprintf("ip. 1 TXT \"%s\"", a[0]))
+	if txt {
+		var (
+			rr  dns.RR
+			err error
+		)
+		if strings.ToLower(q.Name) == "json.ip." {
+			rr, err = makeIPJSON(q.Name, ip)
+		} else {
+			rr, err = dns.NewRR(fmt.Sprintf("ip. 1 TXT \"%s\"", ip))
+		}
 		if err != nil {
 			lo.Printf("error preparing ip response: %v", err)
 			return
 		}
-
 		m.Answer = append(m.Answer, rr)
 	}
 
 	w.WriteMsg(m)
 }
 
+// makeIPJSON returns a TXT record with the IP, its family, and reverse
+// name as a compact JSON string.
+func makeIPJSON(name string, ip net.IP) (dns.RR, error) {
+	family := "ipv6"
+	if ip.To4() != nil {
+		family = "ipv4"
+	}
+
+	rev, err := dns.ReverseAddr(ip.String())
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(struct {
+		IP      string `json:"ip"`
+		Family  string `json:"family"`
+		Reverse string `json:"reverse"`
+	}{ip.String(), family, rev})
+	if err != nil {
+		return nil, err
+	}
+
+	// Set the string directly instead of parsing an RR so that the JSON
+	// quotes don't have to be escaped.
+	return &dns.TXT{
+		Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 1},
+		Txt: []string{string(b)},
+	}, nil
+}
+
+// handleEcho returns metadata about the incoming query for debugging clients.
+// Like handleEchoIP, it's not a Service as it reads the raw query.
+func (h *handlers) handleEcho(w dns.ResponseWriter, r *dns.Msg) {
+	m := &dns.Msg{}
+	m.SetReply(r)
+	m.Compress = false
+
+	if len(r.Question) != 1 {
+		respFormErr(w, m)
+		return
+	}
+
+	var (
+		q    = r.Question[0]
+		edns = "none"
+		ecs  = "none"
+		ip   = hostIP(w.RemoteAddr().String())
+	)
+	if opt := r.IsEdns0(); opt != nil {
+		edns = strconv.Itoa(int(opt.UDPSize()))
+
+		for _, o := range opt.Option {
+			if e, ok := o.(*dns.EDNS0_SUBNET); ok {
+				ecs = fmt.Sprintf("%s/%d", e.Address, e.SourceNetmask)
+			}
+		}
+	}
+
+	out := []string{
+		fmt.Sprintf("%s 1 TXT \"name %s\"", q.Name, q.Name),
+		fmt.Sprintf("%s 1 TXT \"qtype %s\"", q.Name, dns.TypeToString[q.Qtype]),
+		fmt.Sprintf("%s 1 TXT \"client %s\"", q.Name, ip),
+		fmt.Sprintf("%s 1 TXT \"protocol %s\"", q.Name, w.RemoteAddr().Network()),
+		fmt.Sprintf("%s 1 TXT \"edns buffer %s\"", q.Name, edns),
+		fmt.Sprintf("%s 1 TXT \"ecs %s\"", q.Name, ecs),
+	}
+
+	rr, err := makeResp(out)
+	if err != nil {
+		lo.Printf("error preparing echo response: %v", err)
+		respErr(errcode.New(errcode.Internal, "error preparing response."), w, m)
+		return
+	}
+
+	m.Answer = rr
+	w.WriteMsg(m)
+}
+
 func (h *handlers) handleHelp(w dns.ResponseWriter, r *dns.Msg) {
 	m := &dns.Msg{}
 	m.SetReply(r)
 	m.Compress = false
 	m.Answer = h.help
+	wrapTruncate(w, r).WriteMsg(m)
+}
+
+// handleHealth responds with "ok" for health checks,
+// or fails if the server is draining before a shutdown.
+func (h *handlers) handleHealth(w dns.ResponseWriter, r *dns.Msg) {
+	m := &dns.Msg{}
+	m.SetReply(r)
+	m.Compress = false
+
+	if atomic.LoadInt32(&h.draining) == 1 {
+		respErr(errcode.New(errcode.Unavailable, "draining."), w, m)
+		return
+	}
+
+	rr, err := dns.NewRR("health. 1 TXT \"ok\"")
+	if err != nil {
+		lo.Printf("error preparing health response: %v", err)
+		return
+	}
+
+	// Counters for monitoring.
+	st, err := dns.NewRR(fmt.Sprintf("health. 1 TXT \"malformed %d\"", atomic.LoadUint64(&h.malformed)))
+	if err != nil {
+		lo.Printf("error preparing health response: %v", err)
+		return
+	}
+
+	m.Answer = []dns.RR{rr}
+	m.Extra = []dns.RR{st}
 	w.WriteMsg(m)
 }
 
 func (h *handlers) handleDefault(w dns.ResponseWriter, m *dns.Msg) {
-	respErr(fmt.Errorf(`unknown query. try: dig help @%s`, h.domain), w, m)
+	// A query without a name (dig @dns.toys) or for the domain apex.
+	if len(m.Question) == 1 {
+		if n := m.Question[0].Name; n == "." || isApex(n, h.domain) {
+			h.handleApex(w, m)
+			return
+		}
+	}
+
+	if h.bannerOnDefault {
+		m.Answer = h.banner
+	}
+	respErr(errcode.Errorf(errcode.NotFound, `unknown query. try: dig help @%s`, h.domain), w, m)
+	w.WriteMsg(m)
+}
+
+// restrict returns a handler that answers queries with mux except for the
+// services in deny, which are answered as unknown queries. Queries are
+// matched to services by their suffix, or its target if it's an alias.
+func (h *handlers) restrict(mux *dns.ServeMux, deny map[string]bool) dns.HandlerFunc {
+	return func(w dns.ResponseWriter, r *dns.Msg) {
+		if len(deny) > 0 && len(r.Question) == 1 && deny[h.service(r.Question[0].Name)] {
+			h.handleDefault(w, r)
+			return
+		}
+
+		mux.ServeDNS(w, r)
+	}
+}
+
+// service returns the name of the service that answers a query name,
+// eg: weather for berlin.forecast. if forecast is an alias of weather.
+func (h *handlers) service(name string) string {
+	l := dns.SplitDomainName(name)
+	if len(l) == 0 {
+		return ""
+	}
+
+	s := strings.ToLower(l[len(l)-1])
+	if t, ok := h.names[s]; ok {
+		return t
+	}
+
+	// Address/prefix queries on ip. are for the subnet calculator.
+	if s == "ip" && h.ipcalc != nil && strings.Contains(name, "/") {
+		return "ipcalc"
+	}
+
+	return s
+}
+
+// handleDisabled responds to queries for known services that are disabled
+// on the server.
+func (h *handlers) handleDisabled(w dns.ResponseWriter, r *dns.Msg) {
+	m := &dns.Msg{}
+	m.SetReply(r)
+	m.Compress = false
+
+	respErr(errcode.New(errcode.Disabled, h.disabledMsg), w, m)
+}
+
+// handleChaos responds to the CHAOS class queries, eg: version.bind, that
+// are used to fingerprint servers. Queries in other classes are unknown.
+func (h *handlers) handleChaos(w dns.ResponseWriter, r *dns.Msg) {
+	if len(r.Question) != 1 || r.Question[0].Qclass != dns.ClassCHAOS {
+		h.handleDefault(w, r)
+		return
+	}
+
+	m := &dns.Msg{}
+	m.SetReply(r)
+	m.Compress = false
+
+	if h.chaos == nil {
+		m.Rcode = dns.RcodeRefused
+		w.WriteMsg(m)
+		return
+	}
+
+	q := r.Question[0]
+	if v, ok := h.chaos[strings.ToLower(q.Name)]; ok && (q.Qtype == dns.TypeTXT || q.Qtype == dns.TypeANY) {
+		m.Answer = []dns.RR{&dns.TXT{
+			Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeTXT, Class: dns.ClassCHAOS},
+			Txt: []string{v},
+		}}
+	}
+
+	w.WriteMsg(m)
+}
+
+// handleApex responds to apex queries with pointers to help and the list
+// of enabled services.
+func (h *handlers) handleApex(w dns.ResponseWriter, r *dns.Msg) {
+	// Subdomains of the domain, eg: berlin.weather.dns.toys, are unknown.
+	// Queries without a name (.) are answered like the apex.
+	if len(r.Question) != 1 || (r.Question[0].Name != "." && !isApex(r.Question[0].Name, h.domain)) {
+		h.handleDefault(w, r)
+		return
+	}
+
+	m := &dns.Msg{}
+	m.SetReply(r)
+	m.Compress = false
+
+	// The apex only has TXT records. Nameless queries are answered whatever
+	// the type, as a bare `dig @server` asks for NS records of the root.
+	if t := r.Question[0].Qtype; r.Question[0].Name != "." && t != dns.TypeTXT && t != dns.TypeANY {
+		w.WriteMsg(m)
+		return
+	}
+
+	names := make([]string, 0, len(h.services))
+	for n := range h.services {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	var (
+		q   = r.Question[0].Name
+		out []string
+	)
+	if len(h.apexTXT) == 0 {
+		out = append(out, fmt.Sprintf("%s 1 TXT \"useful utilities over DNS. try: dig help @%s\"", q, h.domain))
+	}
+	if len(names) > 0 {
+		out = append(out, fmt.Sprintf("%s 1 TXT \"services: %s\"", q, strings.Join(names, ", ")))
+	}
+
+	rr, err := makeResp(out)
+	if err != nil {
+		lo.Printf("error preparing apex response: %v", err)
+		return
+	}
+
+	// The configured description is used as is and not parsed.
+	desc := make([]dns.RR, 0, len(h.apexTXT))
+	for _, l := range h.apexTXT {
+		desc = append(desc, &dns.TXT{
+			Hdr: dns.RR_Header{Name: q, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 1},
+			Txt: []string{l},
+		})
+	}
+
+	m.Answer = append(desc, rr...)
 	w.WriteMsg(m)
 }
 
+// isApex checks if a query name is the domain apex.
+func isApex(name, domain string) bool {
+	return strings.EqualFold(name, dns.Fqdn(domain))
+}
+
 // respErr writes an error message to a DNS response.
 func respErr(err error, w dns.ResponseWriter, m *dns.Msg) {
-	r, err := dns.NewRR(fmt.Sprintf(". 1 IN TXT \"error: %s\"", err.Error()))
+	// Prefix the message with its machine readable code,
+	// eg: error: E_NOT_FOUND unknown city.
+	r, err := dns.NewRR(fmt.Sprintf(". 1 IN TXT \"error: %s %s\"", errcode.Of(err), err.Error()))
 	if err != nil {
 		lo.Println(err)
 		return
@@ -138,21 +718,109 @@ func respErr(err error, w dns.ResponseWriter, m *dns.Msg) {
 	w.WriteMsg(m)
 }
 
+// respFormErr writes a FORMERR response, eg: for messages with
+// multiple questions.
+func respFormErr(w dns.ResponseWriter, m *dns.Msg) {
+	m.Rcode = dns.RcodeFormatError
+	w.WriteMsg(m)
+}
+
 // cleanQuery removes all non-alpha chars, and trims the service suffix
 // from the given query string.
 func cleanQuery(q, trimSuffix string) string {
-	return reClean.ReplaceAllString(strings.TrimSuffix(q, trimSuffix), "")
+	return reClean.ReplaceAllString(unescape(strings.TrimSuffix(q, trimSuffix)), "")
+}
+
+// unescape decodes the \DDD escaped bytes in a query name back into
+// (UTF-8) characters. Invalid UTF-8 sequences are dropped.
+func unescape(q string) string {
+	if !strings.Contains(q, "\\") {
+		return q
+	}
+
+	b := make([]byte, 0, len(q))
+	for i := 0; i < len(q); i++ {
+		if q[i] == '\\' && i+3 < len(q) {
+			if n, err := strconv.Atoi(q[i+1 : i+4]); err == nil && n < 256 {
+				b = append(b, byte(n))
+				i += 3
+				continue
+			}
+		}
+
+		b = append(b, q[i])
+	}
+
+	return strings.ToValidUTF8(string(b), "")
+}
+
+// escape encodes the non-ASCII bytes in a name as \DDD, the reverse of unescape.
+func escape(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] < 0x80 {
+			b.WriteByte(name[i])
+			continue
+		}
+		fmt.Fprintf(&b, "\\%03d", name[i])
+	}
+
+	return b.String()
+}
+
+// hostIP returns the host of a host:port address, or the
+// address itself if it has no port.
+func hostIP(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+
+	return host
+}
+
+// filterType picks the records for a query type from a Service's answers.
+// Services that compute addresses may return A/AAAA records along with TXT.
+// A/AAAA queries get the address records if there are any and TXT otherwise,
+// and TXT queries get all but the address records.
+func filterType(rr []dns.RR, qtype uint16) []dns.RR {
+	var txt, addr []dns.RR
+	for _, r := range rr {
+		switch r.Header().Rrtype {
+		case dns.TypeA, dns.TypeAAAA:
+			if r.Header().Rrtype == qtype {
+				addr = append(addr, r)
+			}
+		default:
+			txt = append(txt, r)
+		}
+	}
+
+	if len(addr) > 0 {
+		return addr
+	}
+
+	return txt
 }
 
 // makeResp converts a []string of DNS responses to []dns.RR.
 func makeResp(ans []string) ([]dns.RR, error) {
 	out := make([]dns.RR, 0, len(ans))
 	for _, a := range ans {
+		// The owner is the first field. Names don't have spaces.
+		if i := strings.IndexByte(a, ' '); i > 0 && strings.ContainsAny(a[:i], "()") {
+			a = ownerEscaper.Replace(a[:i]) + a[i:]
+		}
+
 		r, err := dns.NewRR(a)
 		if err != nil {
 			return nil, err
 		}
 
+		// Names with (UTF-8) characters from the query, eg: café, can't be
+		// packed unless they're escaped back.
+		r.Header().Name = escape(r.Header().Name)
+
 		out = append(out, r)
 	}
 
@@ -0,0 +1,877 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/knadh/dns.toys/internal/distance"
+	"github.com/knadh/dns.toys/internal/errcode"
+	"github.com/knadh/dns.toys/internal/extra"
+	"github.com/knadh/dns.toys/internal/services/aerial"
+	"github.com/knadh/dns.toys/internal/services/cidr"
+	"github.com/knadh/dns.toys/internal/services/countdown"
+	"github.com/knadh/dns.toys/internal/services/diff"
+	"github.com/knadh/dns.toys/internal/services/emi"
+	"github.com/knadh/dns.toys/internal/services/flip"
+	"github.com/knadh/dns.toys/internal/services/holiday"
+	"github.com/knadh/dns.toys/internal/services/planets"
+	"github.com/knadh/dns.toys/internal/services/plural"
+	"github.com/knadh/dns.toys/internal/services/scramble"
+	"github.com/knadh/dns.toys/internal/services/spell"
+	"github.com/knadh/dns.toys/internal/services/sun"
+	"github.com/knadh/dns.toys/internal/services/textcase"
+	"github.com/knadh/dns.toys/internal/services/timer"
+	"github.com/knadh/dns.toys/internal/services/timezones"
+	"github.com/knadh/dns.toys/internal/services/tip"
+	"github.com/knadh/dns.toys/internal/services/weekday"
+)
+
+// testService is a Service backed by a function. It's a pointer so that
+// it can be a map key (eg: in handlers.budgets).
+type testService struct {
+	fn   func(ctx context.Context, q string) ([]string, error)
+	dump []byte
+}
+
+func svcFunc(fn func(ctx context.Context, q string) ([]string, error)) *testService {
+	return &testService{fn: fn}
+}
+
+func (s *testService) Query(ctx context.Context, q string) ([]string, error) {
+	return s.fn(ctx, q)
+}
+
+func (s *testService) Dump() ([]byte, error) {
+	return s.dump, nil
+}
+
+// testWriter is a dns.ResponseWriter that records the written message.
+type testWriter struct {
+	addr net.Addr
+	msg  *dns.Msg
+}
+
+func (w *testWriter) LocalAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 53}
+}
+
+func (w *testWriter) RemoteAddr() net.Addr {
+	if w.addr != nil {
+		return w.addr
+	}
+	return &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 5353}
+}
+
+func (w *testWriter) WriteMsg(m *dns.Msg) error {
+	w.msg = m
+	return nil
+}
+
+func (w *testWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w *testWriter) Close() error        { return nil }
+func (w *testWriter) TsigStatus() error   { return nil }
+func (w *testWriter) TsigTimersOnly(bool) {}
+func (w *testWriter) Hijack()             {}
+
+// newTestHandlers returns handlers with the defaults that main() sets.
+func newTestHandlers() *handlers {
+	return &handlers{
+		services:     make(map[string]Service),
+		domain:       "dns.toys",
+		queryTimeout: time.Second,
+		budgets:      make(map[Service]time.Duration),
+		shuffle:      make(map[Service]bool),
+		names:        make(map[string]string),
+	}
+}
+
+// exchange sends a query to a handler and returns the response.
+func exchange(t *testing.T, f dns.HandlerFunc, name string, qtype uint16) *dns.Msg {
+	t.Helper()
+	return exchangeFrom(t, f, nil, name, qtype)
+}
+
+// exchangeFrom sends a query from a client address to a handler and
+// returns the response.
+func exchangeFrom(t *testing.T, f dns.HandlerFunc, addr net.Addr, name string, qtype uint16) *dns.Msg {
+	t.Helper()
+
+	r := &dns.Msg{}
+	r.SetQuestion(name, qtype)
+
+	w := &testWriter{addr: addr}
+	f(w, r)
+	if w.msg == nil {
+		t.Fatalf("no response for %s", name)
+	}
+
+	return w.msg
+}
+
+func TestTTLJitterClamp(t *testing.T) {
+	h := newTestHandlers()
+	h.minTTL, h.maxTTL = 50, 60
+	h.ttlJitter = 0.5
+
+	s := svcFunc(func(ctx context.Context, q string) ([]string, error) {
+		return []string{q + " 55 TXT \"a\"", q + " 10 TXT \"b\"", q + " 3600 TXT \"c\""}, nil
+	})
+	f := h.handle("test", s)
+
+	// The 55s record is jittered within the range across responses.
+	seen := map[uint32]bool{}
+	for i := 0; i < 200; i++ {
+		m := exchange(t, f, "x.test.", dns.TypeTXT)
+		for _, rr := range m.Answer {
+			if ttl := rr.Header().Ttl; ttl < h.minTTL || ttl > h.maxTTL {
+				t.Fatalf("TTL %d outside of [%d, %d]", ttl, h.minTTL, h.maxTTL)
+			}
+		}
+		seen[m.Answer[0].Header().Ttl] = true
+	}
+	if len(seen) < 2 {
+		t.Fatalf("expected jittered TTLs across responses, got %v", seen)
+	}
+}
+
+func TestQueryDeadline(t *testing.T) {
+	h := newTestHandlers()
+	h.queryTimeout = time.Millisecond * 50
+
+	// A slow service that only returns when its context is cancelled.
+	cancelled := make(chan error, 1)
+	s := svcFunc(func(ctx context.Context, q string) ([]string, error) {
+		select {
+		case <-ctx.Done():
+			cancelled <- ctx.Err()
+		case <-time.After(time.Second * 5):
+			cancelled <- nil
+		}
+		return []string{q + " 1 TXT \"late\""}, nil
+	})
+	f := h.handle("slow", s)
+
+	start := time.Now()
+	m := exchange(t, f, "x.slow.", dns.TypeTXT)
+	if took := time.Since(start); took > time.Second {
+		t.Fatalf("query took %v, expected it to be cut off at the deadline", took)
+	}
+	if m.Rcode != dns.RcodeServerFailure || len(m.Answer) != 0 {
+		t.Fatalf("expected a SERVFAIL without answers, got %s with %d answers", dns.RcodeToString[m.Rcode], len(m.Answer))
+	}
+	if len(m.Extra) != 1 || !strings.Contains(m.Extra[0].String(), "E_TIMEOUT") {
+		t.Fatalf("expected a timeout error, got %v", m.Extra)
+	}
+
+	// The service sees the cancellation.
+	select {
+	case err := <-cancelled:
+		if err != context.DeadlineExceeded {
+			t.Fatalf("expected the service's context to exceed its deadline, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the service's context wasn't cancelled")
+	}
+}
+
+func TestComputeBudget(t *testing.T) {
+	// A service that takes as long as the query says.
+	s := svcFunc(func(ctx context.Context, q string) ([]string, error) {
+		d, _ := time.ParseDuration(q)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(d):
+		}
+		return []string{q + " 1 TXT \"done\""}, nil
+	})
+
+	tests := []struct {
+		name   string
+		budget time.Duration
+		q      string
+		code   string
+	}{
+		{"within the budget", time.Millisecond * 200, "1ms", ""},
+		{"exceeds the budget", time.Millisecond * 20, "5s", "E_LIMIT"},
+		{"no budget", 0, "5s", "E_TIMEOUT"},
+		{"budget beyond the query timeout", time.Second * 10, "5s", "E_TIMEOUT"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h := newTestHandlers()
+			h.queryTimeout = time.Millisecond * 100
+			if tc.budget > 0 {
+				h.budgets[s] = tc.budget
+			}
+
+			m := exchange(t, h.handle("slow", s), tc.q+".slow.", dns.TypeTXT)
+			if tc.code == "" {
+				if m.Rcode != dns.RcodeSuccess || len(m.Answer) != 1 {
+					t.Fatalf("unexpected response: %v", m)
+				}
+				return
+			}
+
+			if m.Rcode != dns.RcodeServerFailure || len(m.Extra) != 1 || !strings.Contains(m.Extra[0].String(), tc.code) {
+				t.Fatalf("expected %s, got %v", tc.code, m)
+			}
+			if tc.code == "E_LIMIT" && !strings.Contains(m.Extra[0].String(), "computation too expensive, lower the input.") {
+				t.Fatalf("unexpected message: %v", m.Extra[0])
+			}
+		})
+	}
+}
+
+func TestSlowQueryLog(t *testing.T) {
+	// A service that takes as long as the query says.
+	s := svcFunc(func(ctx context.Context, q string) ([]string, error) {
+		d, _ := time.ParseDuration(q)
+		time.Sleep(d)
+		return []string{q + " 1 TXT \"done\""}, nil
+	})
+
+	tests := []struct {
+		name      string
+		threshold time.Duration
+		q         string
+		logged    bool
+	}{
+		{"disabled", 0, "30ms", false},
+		{"fast", time.Millisecond * 20, "1ms", false},
+		{"slow", time.Millisecond * 20, "30ms", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			lo.SetOutput(buf)
+			defer lo.SetOutput(os.Stdout)
+
+			h := newTestHandlers()
+			h.slowThreshold = tc.threshold
+
+			m := exchange(t, h.handle("slow", s), tc.q+".slow.", dns.TypeTXT)
+			if m.Rcode != dns.RcodeSuccess || len(m.Answer) != 1 {
+				t.Fatalf("unexpected response: %v", m)
+			}
+
+			out := buf.String()
+			if !tc.logged {
+				if out != "" {
+					t.Fatalf("expected no logs, got %s", out)
+				}
+				return
+			}
+			if !strings.Contains(out, "slow query: slow "+tc.q+".slow. TXT took ") {
+				t.Fatalf("expected a slow query log with the service, name, and timing, got %s", out)
+			}
+		})
+	}
+}
+
+func TestShuffle(t *testing.T) {
+	s := svcFunc(func(ctx context.Context, q string) ([]string, error) {
+		out := make([]string, 10)
+		for i := range out {
+			out[i] = fmt.Sprintf("%s 1 TXT \"%d\"", q, i)
+		}
+		return out, nil
+	})
+
+	answers := func(m *dns.Msg) []string {
+		out := make([]string, 0, len(m.Answer))
+		for _, rr := range m.Answer {
+			out = append(out, rr.(*dns.TXT).Txt[0])
+		}
+		return out
+	}
+	stable := []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"}
+
+	for _, shuffle := range []bool{false, true} {
+		h := newTestHandlers()
+		h.shuffle[s] = shuffle
+		h.shuffleFn = rand.New(rand.NewSource(1)).Shuffle
+		f := h.handle("test", s)
+
+		reordered := 0
+		for i := 0; i < 20; i++ {
+			out := answers(exchange(t, f, "x.test.", dns.TypeTXT))
+			if !shuffle {
+				// The stable mode keeps the service's order.
+				if !reflect.DeepEqual(out, stable) {
+					t.Fatalf("expected the stable order %v, got %v", stable, out)
+				}
+				continue
+			}
+
+			// The shuffled mode has the same answers in a different order.
+			if !reflect.DeepEqual(out, stable) {
+				reordered++
+			}
+			sort.Strings(out)
+			if !reflect.DeepEqual(out, stable) {
+				t.Fatalf("expected the answers %v, got %v", stable, out)
+			}
+		}
+		if shuffle && reordered == 0 {
+			t.Fatal("expected the answers to be shuffled")
+		}
+	}
+}
+
+func TestMaxAnswers(t *testing.T) {
+	s := svcFunc(func(ctx context.Context, q string) ([]string, error) {
+		out := make([]string, 10)
+		for i := range out {
+			out[i] = fmt.Sprintf("%s 1 TXT \"%d\"", q, i)
+		}
+		return out, nil
+	})
+
+	tests := []struct {
+		max       int
+		answers   int
+		truncated bool
+	}{
+		{0, 10, false},
+		{20, 10, false},
+		{10, 10, false},
+		{3, 4, true},
+		{1, 2, true},
+	}
+
+	for _, tc := range tests {
+		h := newTestHandlers()
+		h.maxAnswers = tc.max
+
+		m := exchange(t, h.handle("test", s), "x.test.", dns.TypeTXT)
+		if len(m.Answer) != tc.answers {
+			t.Fatalf("max %d: expected %d answers, got %d", tc.max, tc.answers, len(m.Answer))
+		}
+
+		last := m.Answer[len(m.Answer)-1].(*dns.TXT).Txt[0]
+		if tc.truncated != strings.Contains(last, "results truncated") {
+			t.Fatalf("max %d: expected truncated=%v, got last answer %q", tc.max, tc.truncated, last)
+		}
+	}
+}
+
+func TestEchoIPTypes(t *testing.T) {
+	tests := []struct {
+		ip    string
+		qtype uint16
+		types []uint16
+	}{
+		{"192.0.2.1", dns.TypeANY, []uint16{dns.TypeA, dns.TypeTXT}},
+		{"2001:db8::1", dns.TypeANY, []uint16{dns.TypeAAAA, dns.TypeTXT}},
+		{"192.0.2.1", dns.TypeA, []uint16{dns.TypeA}},
+		{"192.0.2.1", dns.TypeAAAA, nil},
+		{"2001:db8::1", dns.TypeAAAA, []uint16{dns.TypeAAAA}},
+		{"2001:db8::1", dns.TypeA, nil},
+		{"192.0.2.1", dns.TypeTXT, []uint16{dns.TypeTXT}},
+		{"192.0.2.1", dns.TypeMX, nil},
+	}
+
+	h := newTestHandlers()
+	for _, tc := range tests {
+		addr := &net.UDPAddr{IP: net.ParseIP(tc.ip), Port: 5353}
+		m := exchangeFrom(t, h.handleEchoIP, addr, "ip.", tc.qtype)
+		if m.Rcode != dns.RcodeSuccess || len(m.Answer) != len(tc.types) {
+			t.Fatalf("%s %s: expected %d answers, got %v", tc.ip, dns.TypeToString[tc.qtype], len(tc.types), m.Answer)
+		}
+
+		for i, typ := range tc.types {
+			rr := m.Answer[i]
+			if rr.Header().Rrtype != typ {
+				t.Fatalf("%s %s: expected %s, got %v", tc.ip, dns.TypeToString[tc.qtype], dns.TypeToString[typ], rr)
+			}
+
+			var got string
+			switch v := rr.(type) {
+			case *dns.A:
+				got = v.A.String()
+			case *dns.AAAA:
+				got = v.AAAA.String()
+			case *dns.TXT:
+				got = v.Txt[0]
+			}
+			if got != tc.ip {
+				t.Fatalf("%s %s: unexpected answer: %v", tc.ip, dns.TypeToString[tc.qtype], rr)
+			}
+		}
+	}
+}
+
+func TestEchoIPJSON(t *testing.T) {
+	tests := []struct {
+		ip      string
+		family  string
+		reverse string
+	}{
+		{"192.0.2.1", "ipv4", "1.2.0.192.in-addr.arpa."},
+		{"2001:db8::1", "ipv6", "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa."},
+	}
+
+	h := newTestHandlers()
+	for _, tc := range tests {
+		addr := &net.UDPAddr{IP: net.ParseIP(tc.ip), Port: 5353}
+		m := exchangeFrom(t, h.handleEchoIP, addr, "json.ip.", dns.TypeTXT)
+		if len(m.Answer) != 1 {
+			t.Fatalf("%s: expected 1 answer, got %v", tc.ip, m.Answer)
+		}
+
+		// The TXT should be a single string that's valid JSON.
+		txt := m.Answer[0].(*dns.TXT).Txt
+		if len(txt) != 1 {
+			t.Fatalf("%s: expected a single string, got %q", tc.ip, txt)
+		}
+
+		var out struct {
+			IP      string `json:"ip"`
+			Family  string `json:"family"`
+			Reverse string `json:"reverse"`
+		}
+		if err := json.Unmarshal([]byte(txt[0]), &out); err != nil {
+			t.Fatalf("%s: invalid JSON %q: %v", tc.ip, txt[0], err)
+		}
+		if out.IP != tc.ip || out.Family != tc.family || out.Reverse != tc.reverse {
+			t.Fatalf("%s: unexpected JSON: %+v", tc.ip, out)
+		}
+
+		// The JSON should go on the wire as is, without escaping.
+		b, err := m.Pack()
+		if err != nil {
+			t.Fatalf("%s: error packing response: %v", tc.ip, err)
+		}
+		if !bytes.Contains(b, []byte(txt[0])) {
+			t.Fatalf("%s: JSON not found in the packed response", tc.ip)
+		}
+	}
+
+	// Plain ip. stays human readable.
+	m := exchange(t, h.handleEchoIP, "ip.", dns.TypeTXT)
+	if txt := m.Answer[0].(*dns.TXT).Txt; len(txt) != 1 || txt[0] != "192.0.2.1" {
+		t.Fatalf("unexpected ip. response: %q", txt)
+	}
+}
+
+func TestAddressQuery(t *testing.T) {
+	h := newTestHandlers()
+	f := h.handle("cidr", cidr.New())
+
+	tests := []struct {
+		name  string
+		qtype uint16
+		out   []string
+	}{
+		{"10.0.0.0/24.cidr.", dns.TypeA, []string{"10.0.0.0"}},
+		{"10.0.0.0/24.cidr.", dns.TypeTXT, []string{"10.0.0.1 10.0.0.254 256"}},
+
+		// No IPv6 address for an IPv4 prefix. The TXT is returned instead.
+		{"10.0.0.0/24.cidr.", dns.TypeAAAA, []string{"10.0.0.1 10.0.0.254 256"}},
+		{"2001:db8::/126.cidr.", dns.TypeAAAA, []string{"2001:db8::"}},
+	}
+
+	for _, tc := range tests {
+		m := exchange(t, f, tc.name, tc.qtype)
+		if m.Rcode != dns.RcodeSuccess {
+			t.Fatalf("%s %s: unexpected rcode %s", tc.name, dns.TypeToString[tc.qtype], dns.RcodeToString[m.Rcode])
+		}
+
+		var out []string
+		for _, rr := range m.Answer {
+			switch r := rr.(type) {
+			case *dns.A:
+				out = append(out, r.A.String())
+			case *dns.AAAA:
+				out = append(out, r.AAAA.String())
+			case *dns.TXT:
+				out = append(out, strings.Join(r.Txt, " "))
+			}
+		}
+		if !reflect.DeepEqual(out, tc.out) {
+			t.Fatalf("%s %s: expected %q, got %q", tc.name, dns.TypeToString[tc.qtype], tc.out, out)
+		}
+	}
+}
+
+func TestMultipleQuestions(t *testing.T) {
+	h := newTestHandlers()
+	s := svcFunc(func(ctx context.Context, q string) ([]string, error) {
+		return []string{q + " 1 TXT \"ok\""}, nil
+	})
+
+	// Responses to single and two-question messages.
+	tests := []struct {
+		name  string
+		f     dns.HandlerFunc
+		qs    []string
+		rcode int
+	}{
+		{"service", h.handle("test", s), []string{"a.test."}, dns.RcodeSuccess},
+		{"service", h.handle("test", s), []string{"a.test.", "b.test."}, dns.RcodeFormatError},
+		{"service", h.handle("test", s), []string{"a.test.", "berlin.weather."}, dns.RcodeFormatError},
+		{"ip", h.handleEchoIP, []string{"ip.", "ip."}, dns.RcodeFormatError},
+		{"echo", h.handleEcho, []string{"echo.", "a.test."}, dns.RcodeFormatError},
+	}
+
+	for _, tc := range tests {
+		r := &dns.Msg{}
+		r.SetQuestion(tc.qs[0], dns.TypeTXT)
+		for _, q := range tc.qs[1:] {
+			r.Question = append(r.Question, dns.Question{Name: q, Qtype: dns.TypeTXT, Qclass: dns.ClassINET})
+		}
+
+		w := &testWriter{}
+		tc.f(w, r)
+		if w.msg == nil {
+			t.Fatalf("%s %v: no response", tc.name, tc.qs)
+		}
+		if w.msg.Rcode != tc.rcode {
+			t.Fatalf("%s %v: expected %s, got %s", tc.name, tc.qs, dns.RcodeToString[tc.rcode], dns.RcodeToString[w.msg.Rcode])
+		}
+		if tc.rcode != dns.RcodeSuccess && len(w.msg.Answer) != 0 {
+			t.Fatalf("%s %v: unexpected answers: %v", tc.name, tc.qs, w.msg.Answer)
+		}
+	}
+}
+
+// TestOverDelimited checks that queries with more parts than a service
+// takes are rejected instead of being parsed oddly.
+func TestOverDelimited(t *testing.T) {
+	hol, err := holiday.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		suffix string
+		s      Service
+		q      string
+	}{
+		{"case", textcase.New(), "a/b/upper"},
+		{"plural", plural.New(), "child/singular/x"},
+		{"flip", flip.New(), "a/b/c"},
+		{"scramble", scramble.New(), "a/b/c"},
+		{"spell", spell.New(), "a/b/c"},
+		{"diff", diff.New(), "a/b/c"},
+		{"holiday", hol, "us/2022/x"},
+		{"countdown", countdown.New(), "a/b/c/d/e"},
+		{"weekday", weekday.New(nil), "a/b/c/d"},
+		{"sun", sun.New(nil), "berlin/de/x"},
+		{"planets", planets.New(nil), "a/b/c/d"},
+		{"timer", timer.New(nil), "a/b/c/d"},
+		{"aerial", aerial.New(distance.Both, nil), "a/b/km/x"},
+		{"timezones", timezones.New(timezones.Opt{}, nil), "a/b/c/d"},
+		{"tip", tip.New(tip.Opt{}), "100/15/2/x"},
+		{"emi", emi.New(emi.Opt{}), "1000/10/12/x"},
+	}
+
+	h := newTestHandlers()
+	for _, tc := range tests {
+		m := exchange(t, h.handle(tc.suffix, tc.s), tc.q+"."+tc.suffix+".", dns.TypeTXT)
+		if m.Rcode != dns.RcodeServerFailure || len(m.Extra) != 1 {
+			t.Errorf("%s: expected an error, got %s %v", tc.suffix, dns.RcodeToString[m.Rcode], m.Answer)
+			continue
+		}
+		// Some services respond with their usage instead.
+		if e := m.Extra[0].String(); !strings.Contains(e, "E_INVALID") {
+			t.Errorf("%s: unexpected error: %s", tc.suffix, e)
+		}
+	}
+}
+
+func TestExtraRecords(t *testing.T) {
+	h := newTestHandlers()
+	s := svcFunc(func(ctx context.Context, q string) ([]string, error) {
+		return []string{q + " 1 TXT \"answer\"", extra.Mark(q + " 1 TXT \"debug: cache hit, ttl 1h0m0s\"")}, nil
+	})
+
+	m := exchange(t, h.handle("test", s), "x.test.", dns.TypeTXT)
+	if len(m.Answer) != 1 || m.Answer[0].(*dns.TXT).Txt[0] != "answer" {
+		t.Fatalf("unexpected answers: %v", m.Answer)
+	}
+	if len(m.Extra) != 1 || !strings.HasPrefix(m.Extra[0].(*dns.TXT).Txt[0], "debug: ") {
+		t.Fatalf("expected the debug record in the additional section, got %v", m.Extra)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	// A large response, eg: a batch of conversions.
+	s := svcFunc(func(ctx context.Context, q string) ([]string, error) {
+		out := make([]string, 10)
+		for i := range out {
+			out[i] = fmt.Sprintf("%s 1 TXT \"%s\"", q, strings.Repeat("x", 100))
+		}
+		return out, nil
+	})
+	f := newTestHandlers().handle("test", s)
+
+	tests := []struct {
+		name string
+		addr net.Addr
+		edns uint16
+		tc   bool
+	}{
+		{"udp", &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 5353}, 0, true},
+		{"udp with a large edns buffer", &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 5353}, 4096, false},
+		{"tcp", &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 5353}, 0, false},
+	}
+
+	for _, tc := range tests {
+		r := &dns.Msg{}
+		r.SetQuestion("x.test.", dns.TypeTXT)
+		if tc.edns > 0 {
+			r.SetEdns0(tc.edns, false)
+		}
+
+		w := &testWriter{addr: tc.addr}
+		f(w, r)
+		if w.msg.Truncated != tc.tc {
+			t.Fatalf("%s: expected truncated=%v", tc.name, tc.tc)
+		}
+		if tc.tc && w.msg.Len() > dns.MinMsgSize {
+			t.Fatalf("%s: truncated response is %d bytes", tc.name, w.msg.Len())
+		}
+		if !tc.tc && len(w.msg.Answer) != 10 {
+			t.Fatalf("%s: expected 10 answers, got %d", tc.name, len(w.msg.Answer))
+		}
+	}
+}
+
+func TestErrorCodes(t *testing.T) {
+	tests := []struct {
+		err error
+		out string
+	}{
+		{errors.New("invalid query."), "error: E_INVALID invalid query."},
+		{errcode.New(errcode.NotFound, "unknown city."), "error: E_NOT_FOUND unknown city."},
+		{errcode.New(errcode.Limit, "too many."), "error: E_LIMIT too many."},
+		{errcode.New(errcode.Unavailable, "try later."), "error: E_UNAVAILABLE try later."},
+		{errcode.New(errcode.Disabled, "disabled."), "error: E_DISABLED disabled."},
+		{errcode.New(errcode.Internal, "oops."), "error: E_INTERNAL oops."},
+	}
+
+	h := newTestHandlers()
+	for _, tc := range tests {
+		err := tc.err
+		f := h.handle("test", svcFunc(func(ctx context.Context, q string) ([]string, error) {
+			return nil, err
+		}))
+
+		m := exchange(t, f, "x.test.", dns.TypeTXT)
+		if m.Rcode != dns.RcodeServerFailure || len(m.Extra) != 1 {
+			t.Fatalf("%v: unexpected response: %v", tc.err, m)
+		}
+		if txt := m.Extra[0].(*dns.TXT).Txt; len(txt) != 1 || txt[0] != tc.out {
+			t.Fatalf("%v: expected %q, got %q", tc.err, tc.out, txt)
+		}
+	}
+}
+
+func TestClampTTL(t *testing.T) {
+	s := svcFunc(func(ctx context.Context, q string) ([]string, error) {
+		return []string{q + " 1 TXT \"low\"", q + " 300 TXT \"mid\"", q + " 86400 TXT \"high\""}, nil
+	})
+
+	tests := []struct {
+		min, max uint32
+		out      []uint32
+	}{
+		{0, 0, []uint32{1, 300, 86400}},
+		{60, 0, []uint32{60, 300, 86400}},
+		{0, 3600, []uint32{1, 300, 3600}},
+		{60, 3600, []uint32{60, 300, 3600}},
+		{600, 600, []uint32{600, 600, 600}},
+	}
+
+	for _, tc := range tests {
+		h := newTestHandlers()
+		h.minTTL, h.maxTTL = tc.min, tc.max
+
+		m := exchange(t, h.handle("test", s), "x.test.", dns.TypeTXT)
+		var out []uint32
+		for _, rr := range m.Answer {
+			out = append(out, rr.Header().Ttl)
+		}
+		if !reflect.DeepEqual(out, tc.out) {
+			t.Fatalf("%d-%d: expected TTLs %v, got %v", tc.min, tc.max, tc.out, out)
+		}
+	}
+}
+
+func TestChaos(t *testing.T) {
+	query := func(h *handlers, name string, class uint16) *dns.Msg {
+		r := &dns.Msg{}
+		r.SetQuestion(name, dns.TypeTXT)
+		r.Question[0].Qclass = class
+
+		w := &testWriter{}
+		h.handleChaos(w, r)
+		return w.msg
+	}
+
+	// Refused by default.
+	h := newTestHandlers()
+	for _, n := range []string{"version.bind.", "hostname.bind.", "id.server."} {
+		if m := query(h, n, dns.ClassCHAOS); m.Rcode != dns.RcodeRefused || len(m.Answer) != 0 {
+			t.Fatalf("%s: expected REFUSED, got %s %v", n, dns.RcodeToString[m.Rcode], m.Answer)
+		}
+	}
+
+	// Answered with the configured values.
+	h.chaos = map[string]string{"version.bind.": "dns.toys v1", "hostname.bind.": "dns1", "id.server.": "dns1"}
+	for n, v := range map[string]string{"version.bind.": "dns.toys v1", "HOSTNAME.bind.": "dns1", "id.server.": "dns1"} {
+		m := query(h, n, dns.ClassCHAOS)
+		if m.Rcode != dns.RcodeSuccess || len(m.Answer) != 1 {
+			t.Fatalf("%s: unexpected response: %v", n, m)
+		}
+		if rr := m.Answer[0].(*dns.TXT); rr.Hdr.Class != dns.ClassCHAOS || rr.Txt[0] != v {
+			t.Fatalf("%s: unexpected answer: %v", n, rr)
+		}
+	}
+
+	// Other classes are unknown queries.
+	if m := query(h, "version.bind.", dns.ClassINET); m.Rcode != dns.RcodeServerFailure || len(m.Answer) != 0 {
+		t.Fatalf("expected an unknown query error for the IN class, got %v", m)
+	}
+}
+
+func TestParseTTLOverride(t *testing.T) {
+	tests := []struct {
+		in   string
+		name string
+		ttl  int
+	}{
+		{"berlin/ttl=60.weather.", "berlin.weather.", 60},
+		{"berlin/TTL=0.weather.", "berlin.weather.", 0},
+		{"berlin/summary/ttl=3600.weather.", "berlin/summary.weather.", 3600},
+		{"berlin/ttl=60/summary.weather.", "berlin/summary.weather.", 60},
+		{"berlin.weather.", "berlin.weather.", -1},
+		{"berlin/ttl=.weather.", "berlin/ttl=.weather.", -1},
+		{"berlin/ttl=-5.weather.", "berlin/ttl=-5.weather.", -1},
+	}
+
+	for _, tc := range tests {
+		name, ttl := parseTTLOverride(tc.in)
+		if name != tc.name || ttl != tc.ttl {
+			t.Errorf("%s: expected %s %d, got %s %d", tc.in, tc.name, tc.ttl, name, ttl)
+		}
+	}
+}
+
+func TestTTLOverride(t *testing.T) {
+	var got string
+	s := svcFunc(func(ctx context.Context, q string) ([]string, error) {
+		got = q
+		return []string{"x.test. 300 TXT \"hello\""}, nil
+	})
+
+	tests := []struct {
+		name     string
+		enabled  bool
+		min, max uint32
+		q        string
+		ttl      uint32
+	}{
+		{"disabled", false, 0, 0, "x/ttl=60.test.", 300},
+		{"enabled", true, 0, 0, "x/ttl=60.test.", 60},
+		{"enabled without override", true, 0, 0, "x.test.", 300},
+		{"clamped to the min", true, 30, 0, "x/ttl=5.test.", 30},
+		{"clamped to the max", true, 0, 3600, "x/ttl=86400.test.", 3600},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h := newTestHandlers()
+			h.allowTTLOverride = tc.enabled
+			h.minTTL, h.maxTTL = tc.min, tc.max
+
+			m := exchange(t, h.handle("test", s), tc.q, dns.TypeTXT)
+			if len(m.Answer) != 1 || m.Answer[0].Header().Ttl != tc.ttl {
+				t.Fatalf("expected TTL %d, got %v", tc.ttl, m.Answer)
+			}
+
+			// The override isn't part of the service's query.
+			if tc.enabled && got != "x" {
+				t.Fatalf("expected the query x, got %s", got)
+			}
+		})
+	}
+}
+
+func TestApex(t *testing.T) {
+	txts := func(m *dns.Msg) []string {
+		var out []string
+		for _, rr := range m.Answer {
+			out = append(out, strings.Join(rr.(*dns.TXT).Txt, " "))
+		}
+		return out
+	}
+
+	tests := []struct {
+		name  string
+		apex  []string
+		qname string
+		qtype uint16
+		out   []string
+	}{
+		{"default", nil, "dns.toys.", dns.TypeTXT,
+			[]string{"useful utilities over DNS. try: dig help @dns.toys", "services: calc, weather"}},
+		{"configured", []string{"a public dns.toys instance", "docs: https://example.com; \"quoted\""}, "DNS.toys.", dns.TypeTXT,
+			[]string{"a public dns.toys instance", "docs: https://example.com; \"quoted\"", "services: calc, weather"}},
+		{"ANY", []string{"desc"}, "dns.toys.", dns.TypeANY, []string{"desc", "services: calc, weather"}},
+		{"other types", []string{"desc"}, "dns.toys.", dns.TypeA, nil},
+		{"nameless NS", []string{"desc"}, ".", dns.TypeNS, []string{"desc", "services: calc, weather"}},
+		{"nameless A", []string{"desc"}, ".", dns.TypeA, []string{"desc", "services: calc, weather"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h := newTestHandlers()
+			h.apexTXT = tc.apex
+			h.services["weather"] = &testService{}
+			h.services["calc"] = &testService{}
+
+			m := exchange(t, h.handleApex, tc.qname, tc.qtype)
+			if m.Rcode != dns.RcodeSuccess || !reflect.DeepEqual(txts(m), tc.out) {
+				t.Fatalf("expected %q, got %v", tc.out, m)
+			}
+			for _, rr := range m.Answer {
+				if rr.Header().Name != tc.qname {
+					t.Fatalf("unexpected record name: %v", rr)
+				}
+			}
+		})
+	}
+
+	// Queries without a name get the apex response and subdomains are unknown.
+	h := newTestHandlers()
+	h.apexTXT = []string{"desc"}
+	for _, qtype := range []uint16{dns.TypeTXT, dns.TypeNS} {
+		if m := exchange(t, h.handleDefault, ".", qtype); !reflect.DeepEqual(txts(m), []string{"desc"}) {
+			t.Fatalf("expected the apex response for ., got %v", m)
+		}
+	}
+	if m := exchange(t, h.handleApex, "x.dns.toys.", dns.TypeTXT); m.Rcode != dns.RcodeServerFailure || len(m.Answer) != 0 {
+		t.Fatalf("expected an unknown query for a subdomain, got %v", m)
+	}
+}
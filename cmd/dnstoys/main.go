@@ -1,21 +1,70 @@
 package main
 
 import (
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math/rand"
+	"net"
 	"os"
 	"os/signal"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/knadh/dns.toys/internal/args"
+	"github.com/knadh/dns.toys/internal/distance"
 	"github.com/knadh/dns.toys/internal/geo"
+	"github.com/knadh/dns.toys/internal/i18n"
+	"github.com/knadh/dns.toys/internal/numfmt"
+	"github.com/knadh/dns.toys/internal/proxyproto"
+	"github.com/knadh/dns.toys/internal/services/acronym"
+	"github.com/knadh/dns.toys/internal/services/aerial"
+	"github.com/knadh/dns.toys/internal/services/aqi"
+	"github.com/knadh/dns.toys/internal/services/asn"
+	"github.com/knadh/dns.toys/internal/services/bin"
+	"github.com/knadh/dns.toys/internal/services/bmi"
+	"github.com/knadh/dns.toys/internal/services/calc"
+	"github.com/knadh/dns.toys/internal/services/cert"
 	"github.com/knadh/dns.toys/internal/services/cidr"
+	"github.com/knadh/dns.toys/internal/services/climate"
+	"github.com/knadh/dns.toys/internal/services/convert"
+	"github.com/knadh/dns.toys/internal/services/count"
+	"github.com/knadh/dns.toys/internal/services/countdown"
+	"github.com/knadh/dns.toys/internal/services/date"
+	"github.com/knadh/dns.toys/internal/services/diff"
+	"github.com/knadh/dns.toys/internal/services/emi"
+	"github.com/knadh/dns.toys/internal/services/flip"
 	"github.com/knadh/dns.toys/internal/services/fx"
+	"github.com/knadh/dns.toys/internal/services/holiday"
+	"github.com/knadh/dns.toys/internal/services/ipcalc"
+	"github.com/knadh/dns.toys/internal/services/luck"
 	"github.com/knadh/dns.toys/internal/services/num2words"
+	"github.com/knadh/dns.toys/internal/services/palindrome"
+	"github.com/knadh/dns.toys/internal/services/pct"
+	"github.com/knadh/dns.toys/internal/services/planets"
+	"github.com/knadh/dns.toys/internal/services/plural"
+	"github.com/knadh/dns.toys/internal/services/reverse"
+	"github.com/knadh/dns.toys/internal/services/scramble"
+	"github.com/knadh/dns.toys/internal/services/search"
+	"github.com/knadh/dns.toys/internal/services/slug"
+	"github.com/knadh/dns.toys/internal/services/spell"
+	"github.com/knadh/dns.toys/internal/services/sun"
+	"github.com/knadh/dns.toys/internal/services/temp"
+	"github.com/knadh/dns.toys/internal/services/textcase"
+	"github.com/knadh/dns.toys/internal/services/timer"
 	"github.com/knadh/dns.toys/internal/services/timezones"
+	"github.com/knadh/dns.toys/internal/services/tip"
+	"github.com/knadh/dns.toys/internal/services/tld"
 	"github.com/knadh/dns.toys/internal/services/units"
 	"github.com/knadh/dns.toys/internal/services/weather"
+	"github.com/knadh/dns.toys/internal/services/weekday"
+	"github.com/knadh/dns.toys/internal/services/whois"
 	"github.com/knadh/koanf"
 	"github.com/knadh/koanf/parsers/toml"
 	"github.com/knadh/koanf/providers/file"
@@ -30,6 +79,35 @@ var (
 
 	// Version of the build injected at build time.
 	buildString = "unknown"
+
+	// Placeholders in help examples, eg: {domain}.
+	reHelpPlaceholder = regexp.MustCompile(`\{[a-z]+\}`)
+
+	// Query suffixes of all the services. Queries for the ones that are
+	// disabled get a "disabled" message instead of the generic one.
+	knownServices = []string{
+		"acronym", "aerial", "aqi", "asn", "bin", "bmi", "calc", "case", "cert", "cidr",
+		"climate", "convert", "count", "countdown", "date", "diff", "emi", "flip", "fx",
+		"holiday", "ip", "ipcalc", "luck", "palindrome", "pct", "planets", "plural", "reverse",
+		"scramble", "search", "slug", "spell", "sun", "temp", "time", "timer", "tip",
+		"tld", "unit", "weather", "weekday", "whois", "words",
+	}
+
+	// CHAOS class names that are answered (or refused) for fingerprinting.
+	chaosNames = []string{"version.bind", "version.server", "hostname.bind", "id.server"}
+
+	// Names besides the services' that the server has handlers for, which
+	// can't be used as aliases. The CHAOS zones are included so that aliases
+	// don't catch the other CHAOS queries.
+	reservedNames = append([]string{"echo", "health", "help", "ip", "bind", "server"}, chaosNames...)
+
+	// Services whose answers are in a meaningful order, eg: forecasts by time,
+	// so they aren't shuffled with server.shuffle_answers.
+	orderedServices = map[string]bool{
+		"aqi": true, "asn": true, "cert": true, "cidr": true, "climate": true,
+		"countdown": true, "holiday": true, "ipcalc": true, "search": true,
+		"sun": true, "weather": true, "whois": true,
+	}
 )
 
 func initConfig() {
@@ -62,7 +140,7 @@ func initConfig() {
 }
 
 func saveSnapshot(h *handlers) {
-	interruptSignal := make(chan os.Signal)
+	interruptSignal := make(chan os.Signal, 1)
 	signal.Notify(interruptSignal,
 		syscall.SIGTERM,
 		syscall.SIGHUP,
@@ -78,32 +156,118 @@ func saveSnapshot(h *handlers) {
 		case i := <-interruptSignal:
 			lo.Printf("received SIGNAL: `%s`", i.String())
 
-			for name, s := range h.services {
-				if !ko.Bool(name+".enabled") || !ko.Bool(name+".snapshot_enabled") {
-					continue
-				}
+			// Before shutting down, keep answering queries for a while but
+			// report unhealthy so that load balancers stop sending traffic.
+			if d := ko.Duration("server.drain_delay"); d > 0 && i != syscall.SIGUNUSED {
+				lo.Printf("draining for %v before shutting down", d)
+				atomic.StoreInt32(&h.draining, 1)
+				time.Sleep(d)
+				lo.Println("draining complete")
+			}
 
-				b, err := s.Dump()
-				if err != nil {
-					lo.Printf("error generating %s snapshot: %v", name, err)
-				}
+			if i != syscall.SIGUNUSED {
+				stopServices(h)
+			}
 
-				if b == nil {
-					continue
-				}
+			writeSnapshots(h)
 
-				filePath := ko.MustString(name + ".snapshot_file")
-				lo.Printf("saving %s snapshot to %s", name, filePath)
-				if err := ioutil.WriteFile(filePath, b, 0644); err != nil {
-					lo.Printf("error writing weather snapshot: %v", err)
+			if i != syscall.SIGUNUSED {
+				// Deferred listener shutdowns don't run on exit.
+				if p := ko.String("server.unix_socket"); p != "" {
+					os.Remove(p)
 				}
+				os.Exit(0)
 			}
+		}
+	}
+}
 
-			if i != syscall.SIGUNUSED {
-				os.Exit(0)
+// stopServices stops the background tasks of the services that run them,
+// eg: the fx rates refresher.
+func stopServices(h *handlers) {
+	for name, s := range h.services {
+		if s, ok := s.(interface{ Stop() }); ok {
+			lo.Printf("stopping %s", name)
+			s.Stop()
+		}
+	}
+}
+
+// writeSnapshots dumps the snapshots of all the services that
+// have it enabled to the disk.
+func writeSnapshots(h *handlers) {
+	for name, s := range h.services {
+		if !ko.Bool(name+".enabled") || !ko.Bool(name+".snapshot_enabled") {
+			continue
+		}
+
+		if err := writeSnapshot(name, s); err != nil {
+			lo.Printf("error writing %s snapshot: %v", name, err)
+		}
+	}
+}
+
+// writeSnapshot dumps a service's snapshot to its file. The file is written
+// to a temp file and renamed so that a crash doesn't leave a partial snapshot.
+func writeSnapshot(name string, s Service) error {
+	b, err := s.Dump()
+	if err != nil {
+		return err
+	}
+
+	if b == nil {
+		return nil
+	}
+
+	filePath := ko.MustString(name + ".snapshot_file")
+	lo.Printf("saving %s snapshot to %s", name, filePath)
+	if err := ioutil.WriteFile(filePath+".tmp", b, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(filePath+".tmp", filePath)
+}
+
+// snapshotPeriodically dumps a service's snapshot at the configured
+// <service>.snapshot_interval so that a crash doesn't lose the whole cache.
+func snapshotPeriodically(name string, s Service) {
+	d := ko.Duration(name + ".snapshot_interval")
+	if !ko.Bool(name+".snapshot_enabled") || d <= 0 {
+		return
+	}
+
+	go func() {
+		for range time.Tick(d) {
+			if err := writeSnapshot(name, s); err != nil {
+				lo.Printf("error writing %s snapshot: %v", name, err)
 			}
 		}
+	}()
+}
+
+// cacheSize returns a service's <service>.cache_size and warns if
+// it's 0 (unlimited).
+func cacheSize(service string) int {
+	n := ko.Int(service + ".cache_size")
+	if n < 0 {
+		lo.Fatalf("invalid %s.cache_size: %d", service, n)
 	}
+	if n == 0 {
+		lo.Printf("WARNING: %s.cache_size is 0, the cache is unbounded", service)
+	}
+
+	return n
+}
+
+// splitter returns the splitter for a service's multi-part queries
+// with the optional <service>.separator.
+func splitter(service string) args.Splitter {
+	s, err := args.New(ko.String(service + ".separator"))
+	if err != nil {
+		lo.Fatalf("invalid %s.separator: %v", service, err)
+	}
+
+	return s
 }
 
 func loadSnapshot(service string) []byte {
@@ -118,7 +282,7 @@ func loadSnapshot(service string) []byte {
 		if _, ok := err.(*os.PathError); ok {
 			return nil
 		}
-		lo.Printf("error reading snapshot file %s: %v", err)
+		lo.Printf("error reading snapshot file %s: %v", filePath, err)
 		return nil
 	}
 
@@ -130,8 +294,13 @@ func main() {
 
 	var (
 		h = &handlers{
-			services: make(map[string]Service),
-			domain:   ko.MustString("server.domain"),
+			services:     make(map[string]Service),
+			domain:       ko.MustString("server.domain"),
+			queryTimeout: ko.Duration("server.query_timeout"),
+			maxAnswers:   ko.Int("server.max_answers"),
+			budgets:      make(map[Service]time.Duration),
+			shuffle:      make(map[Service]bool),
+			names:        make(map[string]string),
 		}
 		ge  *geo.Geo
 		mux = dns.NewServeMux()
@@ -139,12 +308,91 @@ func main() {
 		help = [][]string{}
 	)
 
+	// Max time a service has to answer a query. Configs from before the
+	// setting don't have it.
+	if h.queryTimeout < 0 {
+		lo.Fatalf("invalid server.query_timeout: %v", h.queryTimeout)
+	}
+	if h.queryTimeout == 0 {
+		h.queryTimeout = time.Second * 2
+	}
+
+	// TTL floor and ceiling.
+	minTTL, maxTTL, err := parseTTLRange(ko.Duration("server.min_ttl"), ko.Duration("server.max_ttl"))
+	if err != nil {
+		lo.Fatal(err)
+	}
+	h.minTTL, h.maxTTL = minTTL, maxTTL
+
+	// Slow query log.
+	h.slowThreshold = ko.Duration("log.slow_threshold")
+	if h.slowThreshold < 0 {
+		lo.Fatalf("invalid log.slow_threshold: %v", h.slowThreshold)
+	}
+
+	// Log malformed messages. They're always counted.
+	h.logMalformed = ko.Bool("log.malformed")
+
+	// TTL override in queries for testing resolvers.
+	h.allowTTLOverride = ko.Bool("server.allow_ttl_override")
+	if h.allowTTLOverride {
+		lo.Println("WARNING: TTL overrides in queries are enabled (server.allow_ttl_override)")
+	}
+
+	// TTL jitter in percent.
+	jitter := ko.Float64("server.ttl_jitter")
+	if jitter < 0 || jitter > 50 {
+		lo.Fatalf("invalid server.ttl_jitter: %v. Should be 0 - 50 (%%)", jitter)
+	}
+	h.ttlJitter = jitter / 100
+	rand.Seed(time.Now().UnixNano())
+
+	// DNS cookies.
+	if ko.Bool("server.cookies") {
+		c, err := newCookies(ko.String("server.cookie_secret"))
+		if err != nil {
+			lo.Fatalf("error initializing cookies: %v", err)
+		}
+		h.cookies = c
+	}
+
+	// EDNS padding for responses over TLS.
+	if n := ko.Int("server.padding"); n > 0 {
+		if n > dns.MaxMsgSize {
+			lo.Fatalf("invalid server.padding: %d", n)
+		}
+		h.padding = n
+	}
+
+	// Number format for numeric outputs.
+	nf, err := numfmt.New(ko.Bool("server.number_grouping"), ko.String("server.number_style"))
+	if err != nil {
+		lo.Fatalf("error initializing number format: %v", err)
+	}
+
+	// Default units for distances, eg: km. Empty for both km and mi.
+	distUnits, err := distance.ParseUnits(ko.String("server.distance_units"))
+	if err != nil {
+		lo.Fatalf("invalid server.distance_units: %v", err)
+	}
+
 	// Timezone service.
-	if ko.Bool("timezones.enabled") || ko.Bool("weather.enabled") {
+	if ko.Bool("timezones.enabled") || ko.Bool("weather.enabled") || ko.Bool("climate.enabled") || ko.Bool("aqi.enabled") || ko.Bool("sun.enabled") || ko.Bool("timer.enabled") || ko.Bool("search.enabled") ||
+		ko.Bool("weekday.enabled") || ko.Bool("planets.enabled") || ko.Bool("aerial.enabled") {
 		fPath := ko.MustString("timezones.geo_filepath")
 		lo.Printf("reading geo locations from %s", fPath)
 
-		g, err := geo.New(fPath)
+		// Column positions for files that aren't in the geonames.org format.
+		cols := geo.DefaultColumns
+		if m := ko.IntMap("timezones.geo_columns"); len(m) > 0 {
+			c, err := geo.ParseColumns(m)
+			if err != nil {
+				lo.Fatalf("invalid timezones.geo_columns: %v", err)
+			}
+			cols = c
+		}
+
+		g, err := geo.New(fPath, cols)
 		if err != nil {
 			lo.Fatalf("error loading geo locations: %v", err)
 		}
@@ -155,46 +403,124 @@ func main() {
 
 	// Timezone service.
 	if ko.Bool("timezones.enabled") {
-		tz := timezones.New(timezones.Opt{}, ge)
+		tz := timezones.New(timezones.Opt{
+			DefaultLang: i18n.Lang(ko.String("server.default_lang")),
+		}, ge)
 		h.register("time", tz, mux)
 
-		help = append(help, []string{"get time for a city", "dig mumbai.time @%s"})
+		help = append(help, []string{"get time for a city", "dig mumbai.time @{domain}", "dig berlin/lang-de.time @{domain}", "dig 3pm-london-in-tokyo.time @{domain}"})
 	}
 
 	// FX currency conversion.
 	if ko.Bool("fx.enabled") {
-		f := fx.New(fx.Opt{
+		f, err := fx.New(fx.Opt{
+			BaseURL:         ko.String("fx.base_url"),
+			RatesFile:       ko.String("fx.rates_file"),
 			RefreshInterval: ko.MustDuration("fx.refresh_interval"),
+			Debug:           ko.Bool("fx.debug"),
+			NumberFormat:    nf,
+			Args:            splitter("fx"),
 		})
+		if err != nil {
+			lo.Fatalf("error initializing fx: %v", err)
+		}
 
-		// Load snapshot?
-		if b := loadSnapshot("fx"); b != nil {
+		// Load snapshot? Static rates from a file take precedence.
+		if b := loadSnapshot("fx"); b != nil && ko.String("fx.rates_file") == "" {
 			if err := f.Load(b); err != nil {
-				lo.Printf("error reading weather snapshot: %v", err)
+				lo.Printf("error reading fx snapshot: %v", err)
 			}
 		}
+		snapshotPeriodically("fx", f)
 
 		h.register("fx", f, mux)
 
-		help = append(help, []string{"convert currency rates", "dig 99USD-INR.fx @%s"})
+		sep := ko.String("fx.separator")
+		if sep == "" {
+			sep = "-"
+		}
+		help = append(help, []string{"convert currency rates", "dig 99USD" + sep + "INR.fx @{domain}", "dig 1EUR" + sep + "GBP.fx @{domain}"})
+	}
+
+	// IP subnet calculator. It shares the ip. suffix with the IP echo
+	// for address/prefix queries, eg: 192.168.1.37/26.ip.
+	if ko.Bool("ipcalc.enabled") {
+		c := ipcalc.New()
+		h.register("ipcalc", c, mux)
+		h.ipcalc = h.handle("ip", c)
+		if !ko.Bool("ip.enabled") {
+			mux.HandleFunc("ip.", h.ipcalc)
+			h.names["ip"] = "ipcalc"
+		}
+
+		help = append(help, []string{"subnet facts (network, masks, broadcast, usable range, hosts) for an address.", "dig 192.168.1.37/26.ip @{domain}"})
 	}
 
 	// IP echo.
 	if ko.Bool("ip.enabled") {
 		mux.HandleFunc("ip.", h.handleEchoIP)
 
-		help = append(help, []string{"get your host's requesting IP.", "dig ip @%s"})
+		help = append(help, []string{"get your host's requesting IP (json.ip for JSON).", "dig ip @{domain}"})
+	}
+
+	// CHAOS class version.bind and hostname.bind queries.
+	switch ko.String("server.chaos_response") {
+	case "", "refuse":
+	case "respond":
+		var (
+			version  = ko.String("server.chaos_version")
+			hostname = ko.String("server.chaos_hostname")
+		)
+		if version == "" {
+			version = "dns.toys"
+		}
+		if hostname == "" {
+			hostname, _ = os.Hostname()
+		}
+
+		h.chaos = map[string]string{
+			"version.bind.":   version,
+			"version.server.": version,
+			"hostname.bind.":  hostname,
+			"id.server.":      hostname,
+		}
+	default:
+		lo.Fatalf("unknown server.chaos_response '%s'. Use refuse or respond.", ko.String("server.chaos_response"))
+	}
+	for _, n := range chaosNames {
+		mux.HandleFunc(n+".", h.handleChaos)
+	}
+
+	// Query echo for debugging clients. It reflects client info,
+	// so it's opt-in.
+	if ko.Bool("server.echo_query") {
+		mux.HandleFunc("echo.", h.handleEcho)
+
+		help = append(help, []string{"echo the query's metadata (qtype, client IP, protocol, EDNS).", "dig echo @{domain}"})
 	}
 
 	// Weather.
 	if ko.Bool("weather.enabled") {
-		w := weather.New(weather.Opt{
+		w, err := weather.New(weather.Opt{
+			BaseURL:          ko.String("weather.base_url"),
 			MaxEntries:       ko.MustInt("weather.max_entries"),
+			SummaryOnly:      ko.Bool("weather.summary_only"),
 			ForecastInterval: ko.MustDuration("weather.forecast_interval"),
+			CacheSize:        cacheSize("weather"),
 			CacheTTL:         ko.MustDuration("weather.cache_ttl"),
+			MinFetchInterval: ko.Duration("weather.min_fetch_interval"),
+			StaleGrace:       ko.Duration("weather.stale_grace"),
+			GeocodeURL:       ko.String("weather.geocode_url"),
+			GeocodeTTL:       ko.Duration("weather.geocode_ttl"),
 			ReqTimeout:       time.Second * 3,
 			UserAgent:        ko.MustString("server.domain"),
+			Debug:            ko.Bool("weather.debug"),
+			DefaultLang:      i18n.Lang(ko.String("server.default_lang")),
+			Conditions:       ko.StringMap("weather.conditions"),
 		}, ge)
+		if err != nil {
+			lo.Fatalf("error initializing weather: %v", err)
+		}
 
 		// Load snapshot?
 		if b := loadSnapshot("weather"); b != nil {
@@ -202,10 +528,16 @@ func main() {
 				lo.Printf("error reading weather snapshot: %v", err)
 			}
 		}
+		snapshotPeriodically("weather", w)
+
+		// Warm up the cache for popular cities in the background.
+		if c := ko.Strings("weather.warmup"); len(c) > 0 {
+			go w.Warmup(c)
+		}
 
 		h.register("weather", w, mux)
 
-		help = append(help, []string{"get weather forecast for a city.", "dig berlin.weather @%s"})
+		help = append(help, []string{"get weather forecast for a city.", "dig berlin.weather @{domain}", "dig berlin/lang-de.weather @{domain}", "dig london/paris/rome.weather @{domain}", "dig berlin/temp,wind.weather @{domain}"})
 	}
 
 	// Units.
@@ -216,7 +548,7 @@ func main() {
 		}
 		h.register("unit", u, mux)
 
-		help = append(help, []string{"convert between units.", "dig 42km-cm.unit @%s"})
+		help = append(help, []string{"convert between units.", "dig 42km-cm.unit @{domain}", "dig unit @{domain}"})
 	}
 
 	// Numbers to words.
@@ -224,7 +556,7 @@ func main() {
 		n := num2words.New()
 		h.register("words", n, mux)
 
-		help = append(help, []string{"convert numbers to words.", "dig 123456.words @%s"})
+		help = append(help, []string{"convert numbers to words.", "dig 123456.words @{domain}"})
 	}
 
 	// CIDR.
@@ -232,34 +564,793 @@ func main() {
 		n := cidr.New()
 		h.register("cidr", n, mux)
 
-		help = append(help, []string{"convert cidr to ip range.", "dig 10.100.0.0/24.cidr @%s"})
+		help = append(help, []string{"convert cidr to ip range.", "dig 10.100.0.0/24.cidr @{domain}"})
 	}
 
-	// Prepare the static help response for the `help` query.
-	for _, l := range help {
-		r, err := dns.NewRR(fmt.Sprintf("help. 1 TXT \"%s\" \"%s\"", l[0], fmt.Sprintf(l[1], h.domain)))
+	// Plural.
+	if ko.Bool("plural.enabled") {
+		p := plural.New()
+		h.register("plural", p, mux)
+
+		help = append(help, []string{"get the plural (or singular) of a noun.", "dig mouse.plural @{domain}"})
+	}
+
+	// Slug.
+	if ko.Bool("slug.enabled") {
+		s := slug.New()
+		h.register("slug", s, mux)
+
+		help = append(help, []string{"convert text to a URL slug. Use _ for spaces.", "dig Hello_World_2024.slug @{domain}"})
+	}
+
+	// Percentage.
+	if ko.Bool("pct.enabled") {
+		p := pct.New()
+		h.register("pct", p, mux)
+
+		help = append(help, []string{"percentages: X% of Y, X is what % of Y, % change from X to Y.", "dig 15of200.pct @{domain}"})
+	}
+
+	// Tip calculator.
+	if ko.Bool("tip.enabled") {
+		sp := splitter("tip")
+		t := tip.New(tip.Opt{
+			NumberFormat: nf,
+			Args:         sp,
+		})
+		h.register("tip", t, mux)
+
+		help = append(help, []string{sp.Example("calculate tip and split the bill (amount/tip%/people)."), sp.Example("dig 85.50/18/4.tip @{domain}")})
+	}
+
+	// Loan EMI calculator.
+	if ko.Bool("emi.enabled") {
+		sp := splitter("emi")
+		e := emi.New(emi.Opt{
+			NumberFormat: nf,
+			Args:         sp,
+		})
+		h.register("emi", e, mux)
+
+		help = append(help, []string{sp.Example("calculate loan EMI (principal/rate%/months)."), sp.Example("dig 500000/8.5/60.emi @{domain}")})
+	}
+
+	// BMI calculator.
+	if ko.Bool("bmi.enabled") {
+		b := bmi.New()
+		h.register("bmi", b, mux)
+
+		help = append(help, []string{"calculate BMI (weight in kg/lb, height in cm/m/ft/in).", "dig 70kg-175cm.bmi @{domain}"})
+	}
+
+	// Text case.
+	if ko.Bool("case.enabled") {
+		c := textcase.New()
+		h.register("case", c, mux)
+
+		help = append(help, []string{"change text case (upper, lower, title, camel, snake, kebab, shout). Use _ for spaces.", "dig hello_world/camel.case @{domain}"})
+	}
+
+	// Word scramble.
+	if ko.Bool("scramble.enabled") {
+		s := scramble.New()
+		h.register("scramble", s, mux)
+
+		help = append(help, []string{"scramble a word into a random anagram (word/sort for sorted letters).", "dig listen.scramble @{domain}"})
+	}
+
+	// Text statistics.
+	if ko.Bool("count.enabled") {
+		c := count.New()
+		h.register("count", c, mux)
+
+		help = append(help, []string{"count characters, words, and syllables in text. Use _ for spaces.", "dig hello_world.count @{domain}"})
+	}
+
+	// Climate normals.
+	if ko.Bool("climate.enabled") {
+		sp := splitter("climate")
+		c := climate.New(climate.Opt{
+			CacheSize:  cacheSize("climate"),
+			CacheTTL:   ko.MustDuration("climate.cache_ttl"),
+			ReqTimeout: time.Second * 10,
+			UserAgent:  ko.MustString("server.domain"),
+			Debug:      ko.Bool("climate.debug"),
+			Args:       sp,
+		}, ge)
+
+		// Load snapshot?
+		if b := loadSnapshot("climate"); b != nil {
+			if err := c.Load(b); err != nil {
+				lo.Printf("error reading climate snapshot: %v", err)
+			}
+		}
+
+		h.register("climate", c, mux)
+
+		help = append(help, []string{"get typical weather for a city in a month.", sp.Example("dig berlin/july.climate @{domain}")})
+	}
+
+	// Air quality.
+	if ko.Bool("aqi.enabled") {
+		a := aqi.New(aqi.Opt{
+			APIURL:     ko.MustString("aqi.api_url"),
+			CacheSize:  cacheSize("aqi"),
+			CacheTTL:   ko.MustDuration("aqi.cache_ttl"),
+			ReqTimeout: time.Second * 2,
+			UserAgent:  ko.MustString("server.domain"),
+			Debug:      ko.Bool("aqi.debug"),
+		}, ge)
+		h.register("aqi", a, mux)
+
+		help = append(help, []string{"get the air quality index for a city.", "dig delhi.aqi @{domain}"})
+	}
+
+	// Holidays.
+	if ko.Bool("holiday.enabled") {
+		hl, err := holiday.New()
 		if err != nil {
-			lo.Fatalf("error preparing: %v", err)
+			lo.Fatalf("error initializing holiday service: %v", err)
 		}
+		h.register("holiday", hl, mux)
 
-		h.help = append(h.help, r)
+		help = append(help, []string{"get public holidays for a country on a date or in a year.", "dig us/2024.holiday @{domain}"})
 	}
 
+	// Countdown.
+	if ko.Bool("countdown.enabled") {
+		c := countdown.New()
+		h.register("countdown", c, mux)
+
+		help = append(help, []string{"get the time remaining until a date (optionally /timezone).", "dig 2030-01-01T00:00:00Z.countdown @{domain}"})
+	}
+
+	// Sunrise, sunset, and twilights.
+	if ko.Bool("sun.enabled") {
+		s := sun.New(ge)
+		h.register("sun", s, mux)
+
+		help = append(help, []string{"sunrise, sunset, and twilight times for a city or lat,lon.", "dig berlin.sun @{domain}", "dig 52.52,13.40.sun @{domain}"})
+	}
+
+	// Domain registration facts.
+	if ko.Bool("whois.enabled") {
+		w := whois.New(whois.Opt{
+			APIURL:     ko.MustString("whois.api_url"),
+			CacheSize:  cacheSize("whois"),
+			CacheTTL:   ko.MustDuration("whois.cache_ttl"),
+			ReqTimeout: time.Second * 3,
+			UserAgent:  ko.MustString("server.domain"),
+		})
+		h.register("whois", w, mux)
+
+		help = append(help, []string{"domain registrar, creation, and expiry dates.", "dig example-com.whois @{domain}"})
+	}
+
+	// TLS certificate expiry.
+	if ko.Bool("cert.enabled") {
+		c := cert.New(cert.Opt{
+			Ports:       ko.Ints("cert.ports"),
+			CacheSize:   cacheSize("cert"),
+			CacheTTL:    ko.MustDuration("cert.cache_ttl"),
+			DialTimeout: ko.MustDuration("cert.dial_timeout"),
+		})
+		h.register("cert", c, mux)
+
+		help = append(help, []string{"TLS certificate issuer and expiry of a host (host-port).", "dig example-com-443.cert @{domain}"})
+	}
+
+	// Acronyms.
+	if ko.Bool("acronym.enabled") {
+		a, err := acronym.New()
+		if err != nil {
+			lo.Fatalf("error initializing acronym service: %v", err)
+		}
+		h.register("acronym", a, mux)
+
+		help = append(help, []string{"expand common acronyms.", "dig nasa.acronym @{domain}"})
+	}
+
+	// Bitwise calculator.
+	if ko.Bool("bin.enabled") {
+		b := bin.New()
+		h.register("bin", b, mux)
+
+		help = append(help, []string{"bitwise and, or, xor, not, shl, shr on unsigned 64 bit integers.", "dig 12and10.bin @{domain}"})
+	}
+
+	// Date arithmetic.
+	if ko.Bool("date.enabled") {
+		d := date.New()
+		h.register("date", d, mux)
+
+		help = append(help, []string{"add to or subtract from a date (d, w, m, y), or get the days between dates.", "dig 2024-01-15+90d.date @{domain}", "dig 2024-12-25-2024-01-01.date @{domain}"})
+	}
+
+	// Autonomous system lookups.
+	if ko.Bool("asn.enabled") {
+		a := asn.New(asn.Opt{
+			APIURL:     ko.MustString("asn.api_url"),
+			CacheSize:  cacheSize("asn"),
+			CacheTTL:   ko.MustDuration("asn.cache_ttl"),
+			ReqTimeout: time.Second * 3,
+			UserAgent:  ko.MustString("server.domain"),
+		})
+		h.register("asn", a, mux)
+
+		help = append(help, []string{"organization, country, and prefixes of an ASN, or the ASN of an IP.", "dig AS15169.asn @{domain}"})
+	}
+
+	// String reverse.
+	if ko.Bool("reverse.enabled") {
+		r := reverse.New()
+		h.register("reverse", r, mux)
+
+		help = append(help, []string{"reverse text. Use _ for spaces.", "dig hello.reverse @{domain}"})
+	}
+
+	// Timer end times.
+	if ko.Bool("timer.enabled") {
+		t := timer.New(ge)
+		h.register("timer", t, mux)
+
+		help = append(help, []string{"end time of a timer (eg: 25m, pomodoro) from now, optionally for a city.", "dig 25m/berlin.timer @{domain}", "dig pomodoro.timer @{domain}"})
+	}
+
+	// Magic 8-ball and fortune cookies.
+	if ko.Bool("luck.enabled") {
+		l, err := luck.New()
+		if err != nil {
+			lo.Fatalf("error initializing luck service: %v", err)
+		}
+		h.register("luck", l, mux)
+
+		help = append(help, []string{"a random magic 8-ball answer (8ball) or fortune cookie (fortune).", "dig 8ball.luck @{domain}"})
+	}
+
+	// Numbers in words in other languages.
+	if ko.Bool("spell.enabled") {
+		s := spell.New()
+		h.register("spell", s, mux)
+
+		help = append(help, []string{"spell a number in words in a language (en, es, de, fr).", "dig 1234/es.spell @{domain}"})
+	}
+
+	// Conversions routed to the unit or fx converters by their symbols.
+	if ko.Bool("temp.enabled") {
+		t := temp.New()
+		h.register("temp", t, mux)
+
+		help = append(help, []string{"convert temperatures between Celsius, Fahrenheit, Kelvin, and Rankine (c, f, k, r).", "dig 100c-f.temp @{domain}"})
+	}
+
+	if ko.Bool("convert.enabled") {
+		var routes []convert.Route
+		if s, ok := h.services["unit"].(convert.Converter); ok {
+			routes = append(routes, convert.Route{Name: "unit", Conv: s, Sep: "-"})
+		}
+		if s, ok := h.services["fx"].(convert.Converter); ok {
+			sep := ko.String("fx.separator")
+			if sep == "" {
+				sep = "-"
+			}
+			routes = append(routes, convert.Route{Name: "fx", Conv: s, Sep: sep})
+		}
+		if s, ok := h.services["temp"].(convert.Converter); ok {
+			routes = append(routes, convert.Route{Name: "temp", Conv: s, Sep: "-"})
+		}
+		if len(routes) == 0 {
+			lo.Fatal("convert requires the units, fx, or temp service to be enabled")
+		}
+
+		c := convert.New(routes)
+		h.register("convert", c, mux)
+
+		help = append(help, []string{"convert units or currencies without picking the service.", "dig 100km-mi.convert @{domain}", "dig 100usd-eur.convert @{domain}"})
+	}
+
+	// Arithmetic expressions.
+	if ko.Bool("calc.enabled") {
+		c := calc.New()
+		h.register("calc", c, mux)
+
+		help = append(help, []string{"evaluate an arithmetic expression (+ - * / ^ and parentheses).", "dig (3+4)*2.calc @{domain}"})
+	}
+
+	if ko.Bool("tld.enabled") {
+		t, err := tld.New()
+		if err != nil {
+			lo.Fatalf("error initializing tld service: %v", err)
+		}
+		h.register("tld", t, mux)
+
+		help = append(help, []string{"type and sponsor (or country) of a top-level domain.", "dig io.tld @{domain}"})
+	}
+
+	if ko.Bool("search.enabled") {
+		s := search.New(ge)
+		h.register("search", s, mux)
+
+		help = append(help, []string{"find the names of cities to query with a substring.", "dig berl.search @{domain}"})
+	}
+
+	if ko.Bool("flip.enabled") {
+		f := flip.New()
+		h.register("flip", f, mux)
+
+		help = append(help, []string{"pick a random item from a list (/shuffle to shuffle it).", "dig pizza,sushi,tacos.flip @{domain}"})
+	}
+
+	if ko.Bool("palindrome.enabled") {
+		p := palindrome.New()
+		h.register("palindrome", p, mux)
+
+		help = append(help, []string{"check if text is a palindrome or an isogram (_ for spaces).", "dig never_odd_or_even.palindrome @{domain}"})
+	}
+
+	if ko.Bool("diff.enabled") {
+		d := diff.New()
+		h.register("diff", d, mux)
+
+		help = append(help, []string{"edit (Levenshtein) distance between two strings.", "dig kitten/sitting.diff @{domain}"})
+	}
+
+	if ko.Bool("weekday.enabled") {
+		d := weekday.New(ge)
+		h.register("weekday", d, mux)
+
+		help = append(help, []string{"day of the week, ISO week, and day of the year of a date (or today/city).", "dig 2024-07-04.weekday @{domain}"})
+	}
+
+	if ko.Bool("planets.enabled") {
+		p := planets.New(ge)
+		h.register("planets", p, mux)
+
+		help = append(help, []string{"rise and set times and the position of the moon or a planet at a city.", "dig mars-berlin.planets @{domain}", "dig moon-paris/2024-07-04.planets @{domain}"})
+	}
+
+	if ko.Bool("aerial.enabled") {
+		a := aerial.New(distUnits, ge)
+		h.register("aerial", a, mux)
+
+		help = append(help, []string{"aerial distance between two cities or lat,lon points (/km, /mi, or /nm).", "dig berlin/paris.aerial @{domain}", "dig 52.52,13.40/48.85,2.35/nm.aerial @{domain}"})
+	}
+
+	// Optional compute budgets for services, eg: num2words.compute_budget.
+	// Services have to be registered above this to get their budgets and
+	// answer shuffling.
+	for name, s := range h.services {
+		d := ko.Duration(name + ".compute_budget")
+		if d < 0 {
+			lo.Fatalf("invalid %s.compute_budget: %v", name, d)
+		}
+		if d > 0 {
+			h.budgets[s] = d
+		}
+	}
+
+	// Shuffle the answers of the services whose records are equivalent.
+	if ko.Bool("server.shuffle_answers") {
+		for name, s := range h.services {
+			if !orderedServices[name] {
+				h.shuffle[s] = true
+			}
+		}
+	}
+
+	// Aliases for services, eg: forecast => weather.
+	aliases := map[string]bool{}
+	for alias, suffix := range ko.StringMap("server.aliases") {
+		alias = strings.ToLower(alias)
+		if err := checkAlias(alias, aliases, h.domain); err != nil {
+			lo.Fatalf("error registering alias: %v", err)
+		}
+		aliases[alias] = true
+
+		if err := h.registerAlias(alias, suffix, mux); err != nil {
+			lo.Fatalf("error registering alias: %v", err)
+		}
+		lo.Printf("registered alias %s for %s", alias, suffix)
+	}
+
+	// Stubs for the known services that are disabled.
+	h.disabledMsg = ko.String("server.disabled_message")
+	if h.disabledMsg == "" {
+		h.disabledMsg = "this service is disabled on this server."
+	}
+	for _, s := range disabledServices(h, aliases, ko.Bool("ip.enabled") || ko.Bool("ipcalc.enabled")) {
+		mux.HandleFunc(s+".", h.handleDisabled)
+	}
+
+	// Prepare the optional banner that's prepended to the help response.
+	if b := strings.TrimRight(ko.String("server.banner"), "\n"); b != "" {
+		for _, l := range strings.Split(b, "\n") {
+			if len(l) > 255 {
+				lo.Fatalf("banner line exceeds 255 chars: %s", l)
+			}
+
+			h.banner = append(h.banner, &dns.TXT{
+				Hdr: dns.RR_Header{Name: ".", Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 1},
+				Txt: []string{l},
+			})
+		}
+		h.bannerOnDefault = ko.Bool("server.banner_on_default")
+	}
+
+	// Prepare the static help response for the `help` query.
+	if len(help) == 0 {
+		lo.Println("WARNING: no services are enabled")
+	}
+	h.help, err = makeHelp(help, h.banner, h.domain)
+	if err != nil {
+		lo.Fatalf("error preparing help: %v", err)
+	}
+
+	// The banner and help should fit in a single (TCP) response.
+	if n := (&dns.Msg{Answer: h.help}).Len(); n > dns.MaxMsgSize {
+		lo.Fatalf("banner and help response size (%d bytes) exceeds %d bytes", n, dns.MaxMsgSize)
+	}
+
+	// Optional description for the domain apex.
+	if t := strings.TrimRight(ko.String("server.apex_txt"), "\n"); t != "" {
+		for _, l := range strings.Split(t, "\n") {
+			if len(l) > 255 {
+				lo.Fatalf("apex_txt line exceeds 255 chars: %s", l)
+			}
+			h.apexTXT = append(h.apexTXT, l)
+		}
+	}
+	mux.HandleFunc(dns.Fqdn(h.domain), h.handleApex)
+
 	mux.HandleFunc("help.", h.handleHelp)
+	mux.HandleFunc("health.", h.handleHealth)
 	mux.HandleFunc(".", (h.handleDefault))
 
 	// Start the snapshot listener.
 	go saveSnapshot(h)
 
-	// Start the server.
-	server := &dns.Server{
-		Addr:    ko.MustString("server.address"),
-		Net:     "udp",
-		Handler: mux,
-	}
-	lo.Println("listening on ", ko.String("server.address"))
-	if err := server.ListenAndServe(); err != nil {
+	// Start the servers.
+	nets, err := parseNet(ko.String("server.net"))
+	if err != nil {
 		lo.Fatalf("error starting server: %v", err)
 	}
-	defer server.Shutdown()
+
+	// DNS-over-TLS listener.
+	addrs := make([]string, len(nets))
+	for i := range nets {
+		addrs[i] = ko.MustString("server.address")
+	}
+	if a := ko.String("server.tls_address"); a != "" {
+		nets = append(nets, "tcp-tls")
+		addrs = append(addrs, a)
+	}
+
+	// Unix domain socket listener for local proxies and sidecars.
+	if p := ko.String("server.unix_socket"); p != "" {
+		nets = append(nets, "unix")
+		addrs = append(addrs, p)
+	}
+
+	// Services that are bound to additional listeners aren't answered on
+	// the other listeners.
+	listeners, bound := parseListeners(h)
+	handlers := make([]dns.Handler, len(nets))
+	for i := range nets {
+		handlers[i] = h.restrict(mux, bound)
+	}
+	for _, l := range listeners {
+		deny := l.deny(bound)
+		for _, n := range l.nets {
+			nets = append(nets, n)
+			addrs = append(addrs, l.address)
+			handlers = append(handlers, h.restrict(mux, deny))
+		}
+	}
+
+	errCh := make(chan error, len(nets))
+	for i, n := range nets {
+		server, err := newServer(n, addrs[i], handlers[i])
+		if err != nil {
+			lo.Fatalf("error starting %s server: %v", n, err)
+		}
+		h.watchMalformed(server)
+
+		go func(n, addr string, s *dns.Server) {
+			lo.Printf("listening on %s (%s)", addr, n)
+			if err := s.ActivateAndServe(); err != nil {
+				errCh <- fmt.Errorf("%s: %v", n, err)
+			}
+		}(n, addrs[i], server)
+		defer server.Shutdown()
+	}
+
+	lo.Fatalf("error starting server: %v", <-errCh)
+}
+
+// disabledServices returns the known services that aren't enabled and
+// whose names aren't taken by aliases. ip. is also served by ipcalc, so
+// ipEnabled is whether either of them is.
+func disabledServices(h *handlers, aliases map[string]bool, ipEnabled bool) []string {
+	var out []string
+	for _, s := range knownServices {
+		if _, ok := h.services[s]; ok || aliases[s] {
+			continue
+		}
+		if s == "ip" && ipEnabled {
+			continue
+		}
+
+		out = append(out, s)
+	}
+
+	return out
+}
+
+// makeHelp returns the help response with the banner followed by the help
+// lines of the enabled services. Each line is a description and one or more
+// examples.
+func makeHelp(lines [][]string, banner []dns.RR, domain string) ([]dns.RR, error) {
+	out := append([]dns.RR{}, banner...)
+	for _, l := range lines {
+		txt, err := helpLine(l, map[string]string{"domain": domain})
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, &dns.TXT{
+			Hdr: dns.RR_Header{Name: "help.", Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 1},
+			Txt: txt,
+		})
+	}
+
+	// Instead of an empty help response, say that there's nothing to query.
+	if len(lines) == 0 {
+		out = append(out, &dns.TXT{
+			Hdr: dns.RR_Header{Name: "help.", Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 1},
+			Txt: []string{"no services are enabled on this server."},
+		})
+	}
+
+	return out, nil
+}
+
+// helpLine prepares a help line, a description followed by one or more
+// examples, by replacing the {placeholders} in the examples with vals,
+// eg: {domain}. Unknown placeholders and examples without {domain} are errors.
+func helpLine(l []string, vals map[string]string) ([]string, error) {
+	if len(l) < 2 {
+		return nil, fmt.Errorf("help line '%s' has no examples", l[0])
+	}
+
+	out := make([]string, 0, len(l))
+	out = append(out, l[0])
+	for _, e := range l[1:] {
+		if !strings.Contains(e, "{domain}") {
+			return nil, fmt.Errorf("help example '%s' has no {domain}", e)
+		}
+
+		var err error
+		ex := reHelpPlaceholder.ReplaceAllStringFunc(e, func(p string) string {
+			v, ok := vals[p[1:len(p)-1]]
+			if !ok {
+				err = fmt.Errorf("unknown placeholder %s in help example '%s'", p, e)
+			}
+			return v
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(ex) > 255 {
+			return nil, fmt.Errorf("help example exceeds 255 chars: %s", ex)
+		}
+
+		out = append(out, ex)
+	}
+
+	return out, nil
+}
+
+// listener is an additional listener that the services bound to it are
+// answered on.
+type listener struct {
+	address  string
+	nets     []string
+	services map[string]bool
+}
+
+// deny returns the services bound to the other listeners, which aren't
+// answered on this one.
+func (l listener) deny(bound map[string]bool) map[string]bool {
+	out := make(map[string]bool, len(bound))
+	for s := range bound {
+		if !l.services[s] {
+			out[s] = true
+		}
+	}
+
+	return out
+}
+
+// parseListeners parses the optional [[listeners]] config and returns the
+// listeners and all the services that are bound to them.
+func parseListeners(h *handlers) ([]listener, map[string]bool) {
+	var (
+		out   []listener
+		bound = map[string]bool{}
+	)
+	for i, k := range ko.Slices("listeners") {
+		l := listener{
+			address:  k.String("address"),
+			services: map[string]bool{},
+		}
+		if l.address == "" {
+			lo.Fatalf("listeners[%d]: address is required", i)
+		}
+
+		nets, err := parseNet(k.String("net"))
+		if err != nil {
+			lo.Fatalf("listeners[%d]: %v", i, err)
+		}
+		l.nets = nets
+
+		svcs := k.Strings("services")
+		if len(svcs) == 0 {
+			lo.Fatalf("listeners[%d]: services are required", i)
+		}
+		for _, s := range svcs {
+			// Aliases bind the services behind them on all their names.
+			s = strings.ToLower(s)
+			if t, ok := h.names[s]; ok {
+				s = t
+			}
+			if _, ok := h.services[s]; !ok && s != "ip" && s != "echo" {
+				lo.Fatalf("listeners[%d]: unknown or disabled service '%s'", i, s)
+			}
+
+			l.services[s] = true
+			bound[s] = true
+		}
+
+		out = append(out, l)
+	}
+
+	return out, bound
+}
+
+// bindErr returns an actionable error if binding to a privileged
+// port (< 1024, eg: 53) failed for the lack of permissions.
+func bindErr(addr string, err error) error {
+	if !errors.Is(err, syscall.EACCES) {
+		return err
+	}
+
+	_, p, _ := net.SplitHostPort(addr)
+	if port, _ := strconv.Atoi(p); port <= 0 || port >= 1024 {
+		return err
+	}
+
+	return fmt.Errorf("%v: binding to the privileged port %s requires root or the CAP_NET_BIND_SERVICE capability. "+
+		"Grant it with `sudo setcap cap_net_bind_service=+ep %s` or use a port >= 1024 behind a port forward", err, p, os.Args[0])
+}
+
+// checkAlias checks that an alias doesn't collide with the suffix of a
+// service (enabled or not), another alias, or any other name that the
+// server answers, as the alias would silently replace or be replaced by it.
+func checkAlias(alias string, aliases map[string]bool, domain string) error {
+	if alias == "" || strings.HasSuffix(alias, ".") {
+		return fmt.Errorf("invalid alias '%s'", alias)
+	}
+
+	if aliases[alias] {
+		return fmt.Errorf("alias '%s' is defined more than once", alias)
+	}
+
+	for _, names := range [][]string{knownServices, reservedNames} {
+		for _, n := range names {
+			if alias == n {
+				return fmt.Errorf("alias '%s' conflicts with an existing service or name", alias)
+			}
+		}
+	}
+
+	if alias == strings.ToLower(strings.TrimSuffix(domain, ".")) {
+		return fmt.Errorf("alias '%s' conflicts with the server's domain", alias)
+	}
+
+	return nil
+}
+
+// parseNet parses the server.net config, eg: udp+tcp, into listener networks.
+// If it's not set, only UDP is used to preserve the old behaviour.
+func parseNet(s string) ([]string, error) {
+	switch s {
+	case "", "udp":
+		return []string{"udp"}, nil
+	case "tcp":
+		return []string{"tcp"}, nil
+	case "udp+tcp", "tcp+udp":
+		return []string{"udp", "tcp"}, nil
+	}
+
+	return nil, fmt.Errorf("unknown server.net '%s'. Use udp, tcp, or udp+tcp.", s)
+}
+
+// parseTTLRange validates the TTL floor and ceiling and returns them in
+// seconds. A ceiling of 0 is no ceiling.
+func parseTTLRange(min, max time.Duration) (uint32, uint32, error) {
+	if min < 0 || max < 0 || (max > 0 && min > max) {
+		return 0, 0, fmt.Errorf("invalid server.min_ttl (%v) and server.max_ttl (%v). min_ttl should be <= max_ttl", min, max)
+	}
+
+	return uint32(min.Seconds()), uint32(max.Seconds()), nil
+}
+
+// newServer creates a DNS server on a bound UDP, TCP, TLS (tcp-tls), or
+// Unix domain socket listener. TCP listeners are optionally wrapped to parse
+// PROXY protocol headers, which precede the TLS handshake.
+func newServer(network, addr string, handler dns.Handler) (*dns.Server, error) {
+	srv := &dns.Server{
+		Addr:    addr,
+		Net:     network,
+		Handler: handler,
+	}
+
+	// Unix sockets are stream sockets that carry messages like TCP.
+	if network == "unix" {
+		// Remove the socket file left behind by an unclean shutdown.
+		if fi, err := os.Stat(addr); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			os.Remove(addr)
+		}
+
+		l, err := net.Listen("unix", addr)
+		if err != nil {
+			return nil, err
+		}
+		srv.Listener = l
+
+		return srv, nil
+	}
+
+	if network == "udp" {
+		pc, err := net.ListenPacket(network, addr)
+		if err != nil {
+			return nil, bindErr(addr, err)
+		}
+		srv.PacketConn = pc
+
+		return srv, nil
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, bindErr(addr, err)
+	}
+
+	if ko.Bool("server.proxy_protocol") {
+		trusted, err := proxyproto.ParseCIDRs(ko.Strings("server.proxy_trusted"))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing server.proxy_trusted: %v", err)
+		}
+		if len(trusted) == 0 {
+			return nil, errors.New("server.proxy_trusted should have the networks of the proxies for server.proxy_protocol")
+		}
+
+		lo.Printf("expecting PROXY protocol headers on TCP connections from %v", ko.Strings("server.proxy_trusted"))
+		l = proxyproto.NewListener(l, trusted)
+	}
+
+	if network == "tcp-tls" {
+		cert, err := tls.LoadX509KeyPair(ko.MustString("server.tls_cert"), ko.MustString("server.tls_key"))
+		if err != nil {
+			return nil, err
+		}
+
+		l = tls.NewListener(l, &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		})
+	}
+	srv.Listener = l
+
+	return srv, nil
 }
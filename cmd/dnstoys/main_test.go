@@ -0,0 +1,412 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/knadh/koanf/providers/confmap"
+	"github.com/miekg/dns"
+)
+
+func TestCheckAlias(t *testing.T) {
+	existing := map[string]bool{"forecast": true}
+
+	tests := []struct {
+		alias string
+		ok    bool
+	}{
+		{"tz", true},
+		{"wx", true},
+		{"forecast", false},
+		{"weather", false},
+		{"words", false},
+		{"ip", false},
+		{"help", false},
+		{"health", false},
+		{"echo", false},
+		{"version.bind", false},
+		{"id.server", false},
+		{"bind", false},
+		{"dns.toys", false},
+		{"", false},
+		{"tz.", false},
+	}
+
+	for _, tc := range tests {
+		err := checkAlias(tc.alias, existing, "dns.toys")
+		if tc.ok && err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.alias, err)
+		}
+		if !tc.ok && err == nil {
+			t.Fatalf("%s: expected a collision error", tc.alias)
+		}
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	fPath := filepath.Join(t.TempDir(), "snap.gob")
+	ko.Load(confmap.Provider(map[string]interface{}{
+		"snaptest.enabled":          true,
+		"snaptest.snapshot_enabled": true,
+		"snaptest.snapshot_file":    fPath,
+	}, "."), nil)
+
+	// No snapshot yet.
+	if b := loadSnapshot("snaptest"); b != nil {
+		t.Fatalf("expected no snapshot, got %q", b)
+	}
+
+	s := &testService{dump: []byte("cache data")}
+	for i := 0; i < 2; i++ {
+		if err := writeSnapshot("snaptest", s); err != nil {
+			t.Fatal(err)
+		}
+		if b := loadSnapshot("snaptest"); !bytes.Equal(b, s.dump) {
+			t.Fatalf("expected %q, got %q", s.dump, b)
+		}
+		s.dump = []byte("newer cache data")
+	}
+
+	// The temp file is renamed to the snapshot.
+	if _, err := os.Stat(fPath + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("temp file left behind: %v", err)
+	}
+
+	// Services without a dump don't write empty snapshots.
+	if err := writeSnapshot("snaptest", &testService{}); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadFile(fPath); string(b) != "newer cache data" {
+		t.Fatalf("snapshot was overwritten: %q", b)
+	}
+}
+
+func TestMakeHelp(t *testing.T) {
+	banner := []dns.RR{&dns.TXT{
+		Hdr: dns.RR_Header{Name: ".", Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 1},
+		Txt: []string{"welcome"},
+	}}
+
+	tests := []struct {
+		name   string
+		lines  [][]string
+		banner []dns.RR
+		out    []string
+	}{
+		{"no services", nil, nil, []string{"no services are enabled on this server."}},
+		{"no services with banner", nil, banner, []string{"welcome", "no services are enabled on this server."}},
+		{"services", [][]string{{"get the time", "dig berlin.time @{domain}"}}, banner, []string{"welcome", "get the time dig berlin.time @dns.toys"}},
+	}
+
+	for _, tc := range tests {
+		rr, err := makeHelp(tc.lines, tc.banner, "dns.toys")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+
+		var out []string
+		for _, r := range rr {
+			out = append(out, strings.Join(r.(*dns.TXT).Txt, " "))
+		}
+		if !reflect.DeepEqual(out, tc.out) {
+			t.Fatalf("%s: expected %q, got %q", tc.name, tc.out, out)
+		}
+	}
+
+	// The help response is served as is.
+	h := newTestHandlers()
+	h.help, _ = makeHelp(nil, nil, "dns.toys")
+	m := exchange(t, h.handleHelp, "help.", dns.TypeTXT)
+	if len(m.Answer) != 1 || m.Answer[0].(*dns.TXT).Txt[0] != "no services are enabled on this server." {
+		t.Fatalf("unexpected help response: %v", m.Answer)
+	}
+
+	if _, err := makeHelp([][]string{{"no examples"}}, nil, "dns.toys"); err == nil {
+		t.Fatal("expected an error for a line without examples")
+	}
+}
+
+// stopService is a Service with a Stop() method.
+type stopService struct {
+	testService
+	stopped bool
+}
+
+func (s *stopService) Stop() {
+	s.stopped = true
+}
+
+func TestStopServices(t *testing.T) {
+	h := newTestHandlers()
+	s := &stopService{}
+	h.services["stoppable"] = s
+	h.services["other"] = &testService{}
+
+	stopServices(h)
+	if !s.stopped {
+		t.Fatal("service wasn't stopped")
+	}
+}
+
+func TestParseTTLRange(t *testing.T) {
+	tests := []struct {
+		min, max time.Duration
+		err      bool
+	}{
+		{0, 0, false},
+		{time.Minute, 0, false},
+		{time.Minute, time.Hour, false},
+		{time.Minute, time.Minute, false},
+		{time.Hour, time.Minute, true},
+		{-time.Second, 0, true},
+		{0, -time.Second, true},
+	}
+
+	for _, tc := range tests {
+		min, max, err := parseTTLRange(tc.min, tc.max)
+		if tc.err {
+			if err == nil {
+				t.Errorf("%v-%v: expected an error", tc.min, tc.max)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v-%v: unexpected error: %v", tc.min, tc.max, err)
+			continue
+		}
+		if min != uint32(tc.min.Seconds()) || max != uint32(tc.max.Seconds()) {
+			t.Errorf("%v-%v: got %d-%d", tc.min, tc.max, min, max)
+		}
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dns.sock")
+
+	// A socket file left behind by an unclean shutdown.
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+
+	h := newTestHandlers()
+	s := svcFunc(func(ctx context.Context, q string) ([]string, error) {
+		return []string{q + " 1 TXT \"hello\""}, nil
+	})
+	mux := dns.NewServeMux()
+	mux.HandleFunc("echo.", h.handle("echo", s))
+
+	srv, err := newServer("unix", path, mux)
+	if err != nil {
+		t.Fatalf("error starting the unix server: %v", err)
+	}
+	go srv.ActivateAndServe()
+
+	r := &dns.Msg{}
+	r.SetQuestion("x.echo.", dns.TypeTXT)
+
+	c := &dns.Client{Net: "unix", Timeout: time.Second}
+	m, _, err := c.Exchange(r, path)
+	if err != nil {
+		t.Fatalf("error querying over the unix socket: %v", err)
+	}
+	if m.Rcode != dns.RcodeSuccess || len(m.Answer) != 1 || m.Answer[0].(*dns.TXT).Txt[0] != "hello" {
+		t.Fatalf("unexpected response: %v", m)
+	}
+
+	// The socket file is removed on shutdown.
+	if err := srv.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected the socket file to be removed, got %v", err)
+	}
+}
+
+func TestDisabledServices(t *testing.T) {
+	h := newTestHandlers()
+	h.disabledMsg = "not here."
+	s := svcFunc(func(ctx context.Context, q string) ([]string, error) {
+		return []string{q + " 1 TXT \"ok\""}, nil
+	})
+	h.services["calc"] = s
+
+	// Everything but the enabled service, the alias, and ip.
+	dis := disabledServices(h, map[string]bool{"time": true}, true)
+	if len(dis) != len(knownServices)-3 {
+		t.Fatalf("expected %d disabled services, got %d: %v", len(knownServices)-3, len(dis), dis)
+	}
+	for _, n := range dis {
+		if n == "calc" || n == "time" || n == "ip" {
+			t.Fatalf("%s shouldn't be disabled", n)
+		}
+	}
+	if d := disabledServices(h, nil, false); len(d) != len(knownServices)-1 {
+		t.Fatalf("expected ip to be disabled, got %v", d)
+	}
+
+	mux := dns.NewServeMux()
+	mux.HandleFunc("calc.", h.handle("calc", s))
+	mux.HandleFunc(".", h.handleDefault)
+	for _, n := range dis {
+		mux.HandleFunc(n+".", h.handleDisabled)
+	}
+
+	tests := []struct {
+		name string
+		msg  string
+	}{
+		{"berlin.weather.", "error: E_DISABLED not here."},
+		{"usd-inr.fx.", "error: E_DISABLED not here."},
+		{"x.notaservice.", "E_NOT_FOUND"},
+	}
+	for _, tc := range tests {
+		m := exchange(t, mux.ServeDNS, tc.name, dns.TypeTXT)
+		if m.Rcode != dns.RcodeServerFailure || len(m.Extra) != 1 || !strings.Contains(m.Extra[0].String(), tc.msg) {
+			t.Fatalf("%s: expected %s, got %v", tc.name, tc.msg, m)
+		}
+	}
+
+	// Enabled services are still answered.
+	if m := exchange(t, mux.ServeDNS, "1.calc.", dns.TypeTXT); m.Rcode != dns.RcodeSuccess || len(m.Answer) != 1 {
+		t.Fatalf("unexpected response for an enabled service: %v", m)
+	}
+}
+
+func TestListeners(t *testing.T) {
+	ko.Load(confmap.Provider(map[string]interface{}{
+		"listeners": []interface{}{
+			map[string]interface{}{"address": "10.0.0.1:53", "net": "udp+tcp", "services": []interface{}{"Weather", "fx"}},
+			map[string]interface{}{"address": "10.0.0.2:53", "services": []interface{}{"fx", "tz"}},
+		},
+	}, "."), nil)
+	defer ko.Delete("listeners")
+
+	h := newTestHandlers()
+	mux := dns.NewServeMux()
+	mux.HandleFunc(".", h.handleDefault)
+	for _, n := range []string{"calc", "weather", "fx", "tz"} {
+		n := n
+		s := svcFunc(func(ctx context.Context, q string) ([]string, error) {
+			return []string{q + "." + n + ". 1 TXT \"" + n + "\""}, nil
+		})
+		h.services[n] = s
+		mux.HandleFunc(n+".", h.handle(n, s))
+	}
+
+	ls, bound := parseListeners(h)
+	if len(ls) != 2 {
+		t.Fatalf("expected 2 listeners, got %v", ls)
+	}
+	if !reflect.DeepEqual(ls[0].nets, []string{"udp", "tcp"}) || !reflect.DeepEqual(ls[1].nets, []string{"udp"}) {
+		t.Fatalf("unexpected listener nets: %v, %v", ls[0].nets, ls[1].nets)
+	}
+	if !reflect.DeepEqual(bound, map[string]bool{"weather": true, "fx": true, "tz": true}) {
+		t.Fatalf("unexpected bound services: %v", bound)
+	}
+
+	// Services bound to listeners are only answered on them, and the
+	// unbound ones on all the listeners.
+	var (
+		main = h.restrict(mux, bound)
+		l1   = h.restrict(mux, ls[0].deny(bound))
+		l2   = h.restrict(mux, ls[1].deny(bound))
+	)
+	tests := []struct {
+		name     string
+		answered [3]bool
+	}{
+		{"1.calc.", [3]bool{true, true, true}},
+		{"berlin.weather.", [3]bool{false, true, false}},
+		{"usd-inr.fx.", [3]bool{false, true, true}},
+		{"berlin.tz.", [3]bool{false, false, true}},
+	}
+	for _, tc := range tests {
+		for i, f := range []dns.HandlerFunc{main, l1, l2} {
+			m := exchange(t, f, tc.name, dns.TypeTXT)
+			if ok := m.Rcode == dns.RcodeSuccess && len(m.Answer) == 1; ok != tc.answered[i] {
+				t.Fatalf("%s on listener %d: expected answered=%v, got %v", tc.name, i, tc.answered[i], m)
+			}
+			if !tc.answered[i] && (len(m.Extra) != 1 || !strings.Contains(m.Extra[0].String(), "E_NOT_FOUND")) {
+				t.Fatalf("%s on listener %d: expected an unknown query, got %v", tc.name, i, m)
+			}
+		}
+	}
+}
+
+func TestListenersAliases(t *testing.T) {
+	tests := []struct {
+		name     string
+		services []interface{}
+		echoIP   bool
+		q        string
+		answered [2]bool
+	}{
+		// Bound services aren't answered elsewhere through their aliases.
+		{"alias", []interface{}{"weather"}, true, "berlin.forecast.", [2]bool{false, true}},
+		{"target", []interface{}{"weather"}, true, "berlin.weather.", [2]bool{false, true}},
+
+		// Binding an alias binds the service behind it.
+		{"bound by alias", []interface{}{"forecast"}, true, "berlin.weather.", [2]bool{false, true}},
+
+		// ipcalc answers address/prefix queries on ip.
+		{"ipcalc on ip", []interface{}{"ipcalc"}, true, "10.0.0.1/24.ip.", [2]bool{false, true}},
+		{"echo with ipcalc bound", []interface{}{"ipcalc"}, true, "ip.", [2]bool{true, true}},
+		{"echo bound", []interface{}{"ip"}, true, "10.0.0.1/24.ip.", [2]bool{true, true}},
+		{"ipcalc only on ip", []interface{}{"ipcalc"}, false, "10.0.0.1/24.ip.", [2]bool{false, true}},
+		{"ipcalc only bound as ip", []interface{}{"ip"}, false, "10.0.0.1/24.ip.", [2]bool{false, true}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ko.Load(confmap.Provider(map[string]interface{}{
+				"listeners": []interface{}{
+					map[string]interface{}{"address": "10.0.0.1:53", "services": tc.services},
+				},
+			}, "."), nil)
+			defer ko.Delete("listeners")
+
+			h := newTestHandlers()
+			mux := dns.NewServeMux()
+			mux.HandleFunc(".", h.handleDefault)
+
+			svc := func(n string) Service {
+				return svcFunc(func(ctx context.Context, q string) ([]string, error) {
+					return []string{"x. 1 TXT \"" + n + "\""}, nil
+				})
+			}
+			h.services["weather"] = svc("weather")
+			mux.HandleFunc("weather.", h.handle("weather", h.services["weather"]))
+			if err := h.registerAlias("forecast", "weather", mux); err != nil {
+				t.Fatal(err)
+			}
+
+			h.services["ipcalc"] = svc("ipcalc")
+			h.ipcalc = h.handle("ip", h.services["ipcalc"])
+			if tc.echoIP {
+				mux.HandleFunc("ip.", h.handleEchoIP)
+			} else {
+				mux.HandleFunc("ip.", h.ipcalc)
+				h.names["ip"] = "ipcalc"
+			}
+
+			ls, bound := parseListeners(h)
+			for i, f := range []dns.HandlerFunc{h.restrict(mux, bound), h.restrict(mux, ls[0].deny(bound))} {
+				m := exchange(t, f, tc.q, dns.TypeTXT)
+				if ok := m.Rcode == dns.RcodeSuccess && len(m.Answer) > 0; ok != tc.answered[i] {
+					t.Fatalf("%s on listener %d: expected answered=%v, got %v", tc.q, i, tc.answered[i], m)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Size of the DNS message header.
+const headerLen = 12
+
+// malformedReader is a dns.Reader that counts the messages that are too
+// short to have a header, which the server drops silently.
+type malformedReader struct {
+	dns.PacketConnReader
+
+	h *handlers
+}
+
+// malformedWriter is a dns.Writer that counts the FORMERR responses to
+// the messages that couldn't be parsed or were rejected as malformed.
+type malformedWriter struct {
+	dns.Writer
+
+	h *handlers
+}
+
+// watchMalformed hooks into a server's raw message reads and writes to
+// count malformed messages. The checks are on the message lengths and the
+// response code, so well-formed messages aren't parsed again.
+func (h *handlers) watchMalformed(srv *dns.Server) {
+	srv.DecorateReader = func(r dns.Reader) dns.Reader {
+		pr, ok := r.(dns.PacketConnReader)
+		if !ok {
+			return r
+		}
+		return &malformedReader{PacketConnReader: pr, h: h}
+	}
+
+	srv.DecorateWriter = func(w dns.Writer) dns.Writer {
+		return &malformedWriter{Writer: w, h: h}
+	}
+}
+
+// ReadTCP reads a message from a TCP connection.
+func (r *malformedReader) ReadTCP(conn net.Conn, timeout time.Duration) ([]byte, error) {
+	b, err := r.PacketConnReader.ReadTCP(conn, timeout)
+	if err == nil && len(b) < headerLen {
+		r.h.countMalformed(conn.RemoteAddr(), "short message")
+	}
+
+	return b, err
+}
+
+// ReadUDP reads a message from a UDP connection.
+func (r *malformedReader) ReadUDP(conn *net.UDPConn, timeout time.Duration) ([]byte, *dns.SessionUDP, error) {
+	b, s, err := r.PacketConnReader.ReadUDP(conn, timeout)
+	if err == nil && len(b) < headerLen {
+		r.h.countMalformed(s.RemoteAddr(), "short message")
+	}
+
+	return b, s, err
+}
+
+// ReadPacketConn reads a message from a generic packet connection.
+func (r *malformedReader) ReadPacketConn(conn net.PacketConn, timeout time.Duration) ([]byte, net.Addr, error) {
+	b, addr, err := r.PacketConnReader.ReadPacketConn(conn, timeout)
+	if err == nil && len(b) < headerLen {
+		r.h.countMalformed(addr, "short message")
+	}
+
+	return b, addr, err
+}
+
+// Write writes a response and counts it if it's a FORMERR.
+func (w *malformedWriter) Write(b []byte) (int, error) {
+	// The response code is in the low 4 bits of the header's 4th byte.
+	if len(b) >= headerLen && int(b[3]&0xf) == dns.RcodeFormatError {
+		var addr net.Addr
+		if rw, ok := w.Writer.(dns.ResponseWriter); ok {
+			addr = rw.RemoteAddr()
+		}
+		w.h.countMalformed(addr, "format error")
+	}
+
+	return w.Writer.Write(b)
+}
+
+// countMalformed counts a malformed message and optionally logs it.
+func (h *handlers) countMalformed(addr net.Addr, reason string) {
+	atomic.AddUint64(&h.malformed, 1)
+
+	if h.logMalformed {
+		lo.Printf("malformed message from %v: %s", addr, reason)
+	}
+}
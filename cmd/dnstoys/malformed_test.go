@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// logBuffer is a goroutine safe buffer for the logs of servers.
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func (b *logBuffer) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf.Reset()
+}
+
+func TestMalformed(t *testing.T) {
+	buf := &logBuffer{}
+	lo.SetOutput(buf)
+	defer lo.SetOutput(os.Stdout)
+
+	h := newTestHandlers()
+	h.logMalformed = true
+	s := svcFunc(func(ctx context.Context, q string) ([]string, error) {
+		return []string{q + " 1 TXT \"ok\""}, nil
+	})
+	mux := dns.NewServeMux()
+	mux.HandleFunc("echo.", h.handle("echo", s))
+
+	srv, err := newServer("udp", "127.0.0.1:0", mux)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.watchMalformed(srv)
+
+	started := make(chan struct{})
+	srv.NotifyStartedFunc = func() { close(started) }
+	go srv.ActivateAndServe()
+	defer srv.Shutdown()
+	<-started
+
+	conn, err := net.Dial("udp", srv.PacketConn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	valid := &dns.Msg{}
+	valid.SetQuestion("x.echo.", dns.TypeTXT)
+	vb, _ := valid.Pack()
+
+	tests := []struct {
+		name   string
+		pkt    []byte
+		rcode  int
+		count  uint64
+		reason string
+	}{
+		{"short", []byte{0xde, 0xad, 0xbe, 0xef}, -1, 1, "short message"},
+		// A header with a question count of 1 and garbage for the question.
+		{"garbage", []byte{0, 1, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 0xff}, dns.RcodeFormatError, 2, "format error"},
+		{"valid", vb, dns.RcodeSuccess, 2, ""},
+	}
+
+	for _, tc := range tests {
+		buf.Reset()
+		if _, err := conn.Write(tc.pkt); err != nil {
+			t.Fatal(err)
+		}
+
+		// Garbage that's too short doesn't get a response.
+		conn.SetReadDeadline(time.Now().Add(time.Millisecond * 200))
+		b := make([]byte, 512)
+		n, err := conn.Read(b)
+		if tc.rcode < 0 {
+			if err == nil {
+				t.Fatalf("%s: expected no response, got %d bytes", tc.name, n)
+			}
+		} else {
+			if err != nil {
+				t.Fatalf("%s: expected a response: %v", tc.name, err)
+			}
+			if rc := int(b[3] & 0xf); rc != tc.rcode {
+				t.Fatalf("%s: expected %s, got %s", tc.name, dns.RcodeToString[tc.rcode], dns.RcodeToString[rc])
+			}
+		}
+
+		if c := atomic.LoadUint64(&h.malformed); c != tc.count {
+			t.Fatalf("%s: expected the malformed count %d, got %d", tc.name, tc.count, c)
+		}
+		if tc.reason != "" && !strings.Contains(buf.String(), "malformed message from 127.0.0.1:") {
+			t.Fatalf("%s: expected a log for the malformed message, got %q", tc.name, buf.String())
+		}
+		if !strings.Contains(buf.String(), tc.reason) || (tc.reason == "" && buf.String() != "") {
+			t.Fatalf("%s: unexpected log: %q", tc.name, buf.String())
+		}
+	}
+}
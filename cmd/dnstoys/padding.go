@@ -0,0 +1,52 @@
+package main
+
+import (
+	"github.com/miekg/dns"
+)
+
+// Size of the EDNS padding option's header (code and length).
+const paddingHeaderLen = 4
+
+// paddingWriter is a dns.ResponseWriter that pads the responses with
+// the EDNS padding option (RFC 7830) to a multiple of the block size.
+type paddingWriter struct {
+	dns.ResponseWriter
+
+	block int
+}
+
+// wrapPadding returns a ResponseWriter that pads the responses if the query
+// is over an encrypted transport and has EDNS, as padding plaintext responses
+// is pointless (RFC 8467).
+func wrapPadding(w dns.ResponseWriter, r *dns.Msg, block int) dns.ResponseWriter {
+	if r.IsEdns0() == nil {
+		return w
+	}
+
+	cs, ok := w.(dns.ConnectionStater)
+	if !ok || cs.ConnectionState() == nil {
+		return w
+	}
+
+	return &paddingWriter{ResponseWriter: w, block: block}
+}
+
+// WriteMsg pads the response to a multiple of the block size and writes it.
+func (w *paddingWriter) WriteMsg(m *dns.Msg) error {
+	opt := m.IsEdns0()
+	if opt == nil {
+		m.SetEdns0(dns.DefaultMsgSize, false)
+		opt = m.IsEdns0()
+	}
+
+	n := w.block - (m.Len()+paddingHeaderLen)%w.block
+	if n == w.block {
+		n = 0
+	}
+
+	opt.Option = append(opt.Option, &dns.EDNS0_PADDING{
+		Padding: make([]byte, n),
+	})
+
+	return w.ResponseWriter.WriteMsg(m)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// tlsWriter is a testWriter for a query over TLS.
+type tlsWriter struct {
+	testWriter
+}
+
+func (w *tlsWriter) ConnectionState() *tls.ConnectionState {
+	return &tls.ConnectionState{}
+}
+
+func (w *tlsWriter) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 853}
+}
+
+func TestPadding(t *testing.T) {
+	// A service that returns q records.
+	s := svcFunc(func(ctx context.Context, q string) ([]string, error) {
+		var out []string
+		for i := 0; i < len(q); i++ {
+			out = append(out, fmt.Sprintf("%s 1 TXT \"record %d\"", q, i))
+		}
+		return out, nil
+	})
+
+	for _, block := range []int{128, 468} {
+		for _, withCookies := range []bool{false, true} {
+			h := newTestHandlers()
+			h.padding = block
+			if withCookies {
+				h.cookies, _ = newCookies("secret")
+			}
+			f := h.handle("test", s)
+
+			for _, q := range []string{"a", "aaaa", "aaaaaaaaaaaa"} {
+				r := &dns.Msg{}
+				r.SetQuestion(q+".test.", dns.TypeTXT)
+				r.SetEdns0(dns.DefaultMsgSize, false)
+				if withCookies {
+					opt := r.IsEdns0()
+					opt.Option = append(opt.Option, &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: "0102030405060708"})
+				}
+
+				w := &tlsWriter{}
+				f(w, r)
+				if w.msg == nil || len(w.msg.Answer) != len(q) {
+					t.Fatalf("block %d, %s: unexpected response: %v", block, q, w.msg)
+				}
+
+				b, err := w.msg.Pack()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(b)%block != 0 {
+					t.Fatalf("block %d, cookies %v, %s: response size %d isn't a multiple of the block", block, withCookies, q, len(b))
+				}
+			}
+		}
+	}
+}
+
+func TestPaddingPlaintext(t *testing.T) {
+	h := newTestHandlers()
+	h.padding = 468
+	f := h.handle("test", svcFunc(func(ctx context.Context, q string) ([]string, error) {
+		return []string{q + " 1 TXT \"ok\""}, nil
+	}))
+
+	// Queries without EDNS over TLS, and queries over plaintext, aren't padded.
+	tests := []struct {
+		name string
+		tls  bool
+		edns bool
+	}{
+		{"tls without edns", true, false},
+		{"plaintext", false, true},
+	}
+
+	for _, tc := range tests {
+		r := &dns.Msg{}
+		r.SetQuestion("x.test.", dns.TypeTXT)
+		if tc.edns {
+			r.SetEdns0(dns.DefaultMsgSize, false)
+		}
+
+		var m *dns.Msg
+		if tc.tls {
+			w := &tlsWriter{}
+			f(w, r)
+			m = w.msg
+		} else {
+			w := &testWriter{}
+			f(w, r)
+			m = w.msg
+		}
+
+		if opt := m.IsEdns0(); opt != nil {
+			for _, o := range opt.Option {
+				if _, ok := o.(*dns.EDNS0_PADDING); ok {
+					t.Fatalf("%s: unexpected padding", tc.name)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"github.com/miekg/dns"
+)
+
+// truncWriter is a dns.ResponseWriter that truncates UDP responses to
+// the client's advertised UDP size, setting the TC bit so that the client
+// retries over TCP.
+type truncWriter struct {
+	dns.ResponseWriter
+
+	size int
+}
+
+// wrapTruncate returns a ResponseWriter that truncates oversized responses
+// if the query is over UDP.
+func wrapTruncate(w dns.ResponseWriter, r *dns.Msg) dns.ResponseWriter {
+	if w.RemoteAddr().Network() != "udp" {
+		return w
+	}
+
+	size := dns.MinMsgSize
+	if opt := r.IsEdns0(); opt != nil {
+		size = int(opt.UDPSize())
+	}
+
+	return &truncWriter{ResponseWriter: w, size: size}
+}
+
+// WriteMsg truncates the response if it's larger than the size and writes it.
+func (w *truncWriter) WriteMsg(m *dns.Msg) error {
+	m.Truncate(w.size)
+	return w.ResponseWriter.WriteMsg(m)
+}
@@ -0,0 +1,63 @@
+// Package args splits multi-part service queries, eg: berlin/de/lang-de,
+// into their parts.
+package args
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Sep is the default separator between the parts of a query.
+const Sep = "/"
+
+// Seps are the characters that can be used as separators. Other
+// characters are stripped from queries or aren't valid in DNS names.
+const Seps = "/-,:_"
+
+// Splitter splits queries on a separator. The zero value uses Sep.
+type Splitter struct {
+	Sep string
+}
+
+// New returns a Splitter for a separator. An empty separator is Sep.
+func New(sep string) (Splitter, error) {
+	if sep == "" {
+		return Splitter{}, nil
+	}
+
+	if len(sep) != 1 || !strings.Contains(Seps, sep) {
+		return Splitter{}, fmt.Errorf("invalid separator '%s'. Should be one of %s", sep, Seps)
+	}
+
+	return Splitter{Sep: sep}, nil
+}
+
+// Split splits a query into its parts on Sep. See Splitter.Split.
+func Split(q string, max int) ([]string, error) {
+	return Splitter{}.Split(q, max)
+}
+
+// Split splits a query into its parts and returns an error if there are
+// more than max parts so that over-delimited queries aren't parsed oddly.
+func (s Splitter) Split(q string, max int) ([]string, error) {
+	parts := strings.Split(q, s.sep())
+	if len(parts) > max {
+		return nil, fmt.Errorf("too many parts in the query. Max %d.", max)
+	}
+
+	return parts, nil
+}
+
+// Example rewrites a help example written with the default Sep, eg:
+// 85.50/18/4, to use the Splitter's separator.
+func (s Splitter) Example(e string) string {
+	return strings.ReplaceAll(e, Sep, s.sep())
+}
+
+func (s Splitter) sep() string {
+	if s.Sep == "" {
+		return Sep
+	}
+
+	return s.Sep
+}
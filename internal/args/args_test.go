@@ -0,0 +1,64 @@
+package args
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		sep string
+		q   string
+		max int
+		out []string
+		err bool
+	}{
+		{"", "berlin", 2, []string{"berlin"}, false},
+		{"", "berlin/de", 2, []string{"berlin", "de"}, false},
+		{"", "berlin/de/x", 2, nil, true},
+		{"", "a/b/c/d", 4, []string{"a", "b", "c", "d"}, false},
+		{"", "a/b/c/d/e", 4, nil, true},
+
+		// Empty parts still count.
+		{"", "berlin//", 2, nil, true},
+		{"", "////////", 3, nil, true},
+		{"-", "85.50-18-4", 3, []string{"85.50", "18", "4"}, false},
+		{"-", "85.50-18-4-1", 3, nil, true},
+		{"-", "85.50/18/4", 3, []string{"85.50/18/4"}, false},
+	}
+
+	for _, tc := range tests {
+		out, err := Splitter{Sep: tc.sep}.Split(tc.q, tc.max)
+		if tc.err {
+			if err == nil {
+				t.Errorf("%s (max %d): expected an error, got %v", tc.q, tc.max, out)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s (max %d): unexpected error: %v", tc.q, tc.max, err)
+			continue
+		}
+		if !reflect.DeepEqual(out, tc.out) {
+			t.Errorf("%s (max %d): expected %q, got %q", tc.q, tc.max, tc.out, out)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, s := range []string{"", "/", "-", ",", ":", "_"} {
+		if _, err := New(s); err != nil {
+			t.Errorf("%q: unexpected error: %v", s, err)
+		}
+	}
+	for _, s := range []string{".", "+", "//", "x"} {
+		if _, err := New(s); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+
+	sp, _ := New("-")
+	if e := sp.Example("85.50/18/4"); e != "85.50-18-4" {
+		t.Errorf("unexpected example: %s", e)
+	}
+}
@@ -0,0 +1,82 @@
+// Package cache is a bounded, in-memory cache of entries that expire for
+// services that cache upstream responses.
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// Entry is a cached value with an expiry time.
+type Entry interface {
+	Expiry() time.Time
+}
+
+// Cache is a concurrency safe cache of entries keyed by strings. Expired
+// entries are kept (eg: to be served stale while they're refreshed) until
+// they're evicted to make room for new ones.
+type Cache struct {
+	size int
+	data map[string]Entry
+	mut  sync.RWMutex
+}
+
+// New returns a new Cache that holds up to size entries. 0 for no limit.
+func New(size int) *Cache {
+	return &Cache{
+		size: size,
+		data: make(map[string]Entry),
+	}
+}
+
+// Get returns the entry for a key, whether or not it has expired.
+func (c *Cache) Get(key string) (Entry, bool) {
+	c.mut.RLock()
+	e, ok := c.data[key]
+	c.mut.RUnlock()
+
+	return e, ok
+}
+
+// Set caches an entry. If the cache is full, the expired entries are evicted
+// first, and then arbitrary ones.
+func (c *Cache) Set(key string, e Entry) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if _, ok := c.data[key]; !ok && c.size > 0 && len(c.data) >= c.size {
+		now := time.Now()
+		for k, v := range c.data {
+			if v.Expiry().Before(now) {
+				delete(c.data, k)
+			}
+		}
+		for k := range c.data {
+			if len(c.data) < c.size {
+				break
+			}
+			delete(c.data, k)
+		}
+	}
+
+	c.data[key] = e
+}
+
+// Len returns the number of cached entries.
+func (c *Cache) Len() int {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+
+	return len(c.data)
+}
+
+// Range calls fn for every cached entry, eg: to take a snapshot. The cache
+// can't be modified from fn.
+func (c *Cache) Range(fn func(key string, e Entry)) {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+
+	for k, v := range c.data {
+		fn(k, v)
+	}
+}
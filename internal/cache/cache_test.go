@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+type entry time.Time
+
+func (e entry) Expiry() time.Time {
+	return time.Time(e)
+}
+
+func TestSetGet(t *testing.T) {
+	var (
+		c   = New(0)
+		exp = entry(time.Now().Add(-time.Minute))
+	)
+
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("expected a miss on an empty cache")
+	}
+
+	c.Set("a", exp)
+	e, ok := c.Get("a")
+	if !ok || e != exp {
+		t.Fatalf("expected the expired entry to be returned, got %v, %v", e, ok)
+	}
+}
+
+func TestEviction(t *testing.T) {
+	var (
+		now   = time.Now()
+		fresh = entry(now.Add(time.Hour))
+		stale = entry(now.Add(-time.Hour))
+	)
+
+	tests := []struct {
+		name string
+		set  map[string]entry
+		keep []string
+	}{
+		{"expired first", map[string]entry{"a": fresh, "b": stale, "c": fresh}, []string{"a", "c"}},
+		{"all expired", map[string]entry{"a": stale, "b": stale, "c": stale}, nil},
+		{"none expired", map[string]entry{"a": fresh, "b": fresh, "c": fresh}, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := New(3)
+			for k, v := range tc.set {
+				c.Set(k, v)
+			}
+
+			c.Set("new", fresh)
+			if c.Len() > 3 {
+				t.Fatalf("cache exceeded its size: %d", c.Len())
+			}
+			if _, ok := c.Get("new"); !ok {
+				t.Fatal("new entry wasn't cached")
+			}
+			for _, k := range tc.keep {
+				if _, ok := c.Get(k); !ok {
+					t.Fatalf("unexpired entry %s was evicted before the expired ones", k)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateFull(t *testing.T) {
+	c := New(2)
+	c.Set("a", entry(time.Now()))
+	c.Set("b", entry(time.Now()))
+
+	// Updating an existing key shouldn't evict anything.
+	c.Set("a", entry(time.Now().Add(time.Hour)))
+	if c.Len() != 2 {
+		t.Fatalf("expected 2 entries, got %d", c.Len())
+	}
+}
+
+func TestRange(t *testing.T) {
+	c := New(0)
+	for i := 0; i < 5; i++ {
+		c.Set(fmt.Sprint(i), entry(time.Now()))
+	}
+
+	n := 0
+	c.Range(func(k string, e Entry) { n++ })
+	if n != 5 {
+		t.Fatalf("expected 5 entries, got %d", n)
+	}
+}
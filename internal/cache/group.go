@@ -0,0 +1,45 @@
+package cache
+
+import "sync"
+
+// Group de-duplicates concurrent fetches for the same key so that a burst of
+// cache misses for a key makes a single upstream request. The zero value
+// is ready to use.
+type Group struct {
+	mut   sync.Mutex
+	calls map[string]*call
+}
+
+type call struct {
+	wg  sync.WaitGroup
+	e   Entry
+	err error
+}
+
+// Do calls fn for a key and returns its result. Calls for a key while one is
+// in progress wait for it and return its result instead of calling fn.
+func (g *Group) Do(key string, fn func() (Entry, error)) (Entry, error) {
+	g.mut.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mut.Unlock()
+		c.wg.Wait()
+		return c.e, c.err
+	}
+
+	c := &call{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mut.Unlock()
+
+	c.e, c.err = fn()
+	c.wg.Done()
+
+	g.mut.Lock()
+	delete(g.calls, key)
+	g.mut.Unlock()
+
+	return c.e, c.err
+}
@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroup(t *testing.T) {
+	var (
+		g       Group
+		calls   int32
+		release = make(chan struct{})
+		wg      sync.WaitGroup
+	)
+
+	fn := func() (Entry, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return entry(time.Now()), nil
+	}
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := g.Do("a", fn); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+
+	// Let the goroutines pile up on the in-flight call.
+	time.Sleep(time.Millisecond * 50)
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("expected 1 call for concurrent fetches, got %d", n)
+	}
+
+	// Once done, the next fetch for the key is a new call.
+	if _, err := g.Do("a", fn); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Fatalf("expected 2 calls, got %d", n)
+	}
+}
@@ -0,0 +1,74 @@
+// Package distance formats distances in the preferred units consistently
+// across services.
+package distance
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Units represents the unit(s) in which distances are presented.
+type Units string
+
+const (
+	KM   Units = "km"
+	MI   Units = "mi"
+	NM   Units = "nm"
+	Both Units = ""
+
+	kmPerMile         = 1.609344
+	kmPerNauticalMile = 1.852
+
+	// Mean radius of the earth.
+	earthRadiusKM = 6371.0088
+)
+
+// ParseUnits parses a units string (km, mi, nm). An empty string
+// shows both km and mi.
+func ParseUnits(s string) (Units, error) {
+	switch u := Units(strings.ToLower(s)); u {
+	case KM, MI, NM, Both:
+		return u, nil
+	}
+
+	return "", fmt.Errorf("unknown distance unit '%s'. Use km, mi, or nm.", s)
+}
+
+// Between returns the great-circle (haversine) distance in kilometers
+// between two coordinates.
+func Between(lat1, lon1, lat2, lon2 float64) float64 {
+	var (
+		p1   = lat1 * math.Pi / 180
+		p2   = lat2 * math.Pi / 180
+		dLat = (lat2 - lat1) * math.Pi / 180
+		dLon = (lon2 - lon1) * math.Pi / 180
+	)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(p1)*math.Cos(p2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKM * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// ToMiles converts kilometers to statute miles.
+func ToMiles(km float64) float64 {
+	return km / kmPerMile
+}
+
+// ToNauticalMiles converts kilometers to nautical miles.
+func ToNauticalMiles(km float64) float64 {
+	return km / kmPerNauticalMile
+}
+
+// Format formats a distance given in kilometers in the given units.
+func Format(km float64, u Units) string {
+	switch u {
+	case KM:
+		return fmt.Sprintf("%0.2f km", km)
+	case MI:
+		return fmt.Sprintf("%0.2f mi", ToMiles(km))
+	case NM:
+		return fmt.Sprintf("%0.2f nm", ToNauticalMiles(km))
+	}
+
+	return fmt.Sprintf("%0.2f km (%0.2f mi)", km, ToMiles(km))
+}
@@ -0,0 +1,68 @@
+package distance
+
+import (
+	"math"
+	"testing"
+)
+
+func TestToNauticalMiles(t *testing.T) {
+	tests := []struct {
+		km, nm float64
+	}{
+		{0, 0},
+		{1.852, 1},
+		{100, 53.9957},
+		{18520, 10000},
+	}
+
+	for _, tc := range tests {
+		if nm := ToNauticalMiles(tc.km); math.Abs(nm-tc.nm) > 0.0001 {
+			t.Fatalf("%v km: expected %v nm, got %v", tc.km, tc.nm, nm)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		units string
+		out   string
+	}{
+		{"km", "100.00 km"},
+		{"MI", "62.14 mi"},
+		{"nm", "54.00 nm"},
+		{"", "100.00 km (62.14 mi)"},
+	}
+
+	for _, tc := range tests {
+		u, err := ParseUnits(tc.units)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.units, err)
+		}
+		if out := Format(100, u); out != tc.out {
+			t.Fatalf("%s: expected %s, got %s", tc.units, tc.out, out)
+		}
+	}
+
+	if _, err := ParseUnits("ft"); err == nil {
+		t.Fatal("expected an error for an unknown unit")
+	}
+}
+
+func TestBetween(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lat1, lon1, lat2, lon2 float64
+		km                     float64
+	}{
+		{"same point", 52.52, 13.40, 52.52, 13.40, 0},
+		{"berlin-paris", 52.52, 13.40, 48.85, 2.35, 877},
+		{"antipodes", 0, 0, 0, 180, 20015},
+	}
+
+	for _, tc := range tests {
+		// Within 0.5% of the reference distances.
+		if km := Between(tc.lat1, tc.lon1, tc.lat2, tc.lon2); math.Abs(km-tc.km) > tc.km*0.005+0.001 {
+			t.Fatalf("%s: expected ~%v km, got %v", tc.name, tc.km, km)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// Package errcode has the stable, machine readable error codes that
+// prefix the error responses, eg: "error: E_NOT_FOUND unknown city."
+package errcode
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Code is a machine readable error category.
+type Code string
+
+const (
+	// Invalid is for malformed queries and out of range values. Errors
+	// without a code are considered invalid as most are.
+	Invalid Code = "E_INVALID"
+
+	// NotFound is for unknown cities, currencies, domains etc.
+	NotFound Code = "E_NOT_FOUND"
+
+	// Limit is for inputs that exceed a size or count limit.
+	Limit Code = "E_LIMIT"
+
+	// Unavailable is for data that's temporarily unavailable, eg: an
+	// upstream API that's down. The query may be retried later.
+	Unavailable Code = "E_UNAVAILABLE"
+
+	// Disabled is for queries for services that are disabled on the server.
+	Disabled Code = "E_DISABLED"
+
+	// Timeout is for queries that didn't complete in time.
+	Timeout Code = "E_TIMEOUT"
+
+	// Internal is for errors on the server's side.
+	Internal Code = "E_INTERNAL"
+)
+
+// Error is an error with a Code.
+type Error struct {
+	Code Code
+	Msg  string
+}
+
+// New returns an error with a code.
+func New(c Code, msg string) error {
+	return &Error{Code: c, Msg: msg}
+}
+
+// Errorf returns an error with a code and a formatted message.
+func Errorf(c Code, format string, a ...interface{}) error {
+	return &Error{Code: c, Msg: fmt.Sprintf(format, a...)}
+}
+
+// Error returns the error's message without the code.
+func (e *Error) Error() string {
+	return e.Msg
+}
+
+// Of returns the code of an error, or Invalid if it has none.
+func Of(err error) Code {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+
+	return Invalid
+}
@@ -0,0 +1,38 @@
+package errcode
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestOf(t *testing.T) {
+	tests := []struct {
+		err  error
+		code Code
+		msg  string
+	}{
+		{New(Invalid, "invalid query."), Invalid, "invalid query."},
+		{New(NotFound, "unknown city."), NotFound, "unknown city."},
+		{Errorf(Limit, "too many cities. Max %d.", 5), Limit, "too many cities. Max 5."},
+		{New(Unavailable, "try later."), Unavailable, "try later."},
+		{New(Disabled, "disabled."), Disabled, "disabled."},
+		{New(Timeout, "query timed out."), Timeout, "query timed out."},
+		{New(Internal, "error preparing response."), Internal, "error preparing response."},
+
+		// Wrapped errors keep their code.
+		{fmt.Errorf("lookup: %w", New(NotFound, "unknown city.")), NotFound, "lookup: unknown city."},
+
+		// Errors without a code are invalid input.
+		{errors.New("invalid number."), Invalid, "invalid number."},
+	}
+
+	for _, tc := range tests {
+		if c := Of(tc.err); c != tc.code {
+			t.Errorf("%v: expected %s, got %s", tc.err, tc.code, c)
+		}
+		if m := tc.err.Error(); m != tc.msg {
+			t.Errorf("expected message %q, got %q", tc.msg, m)
+		}
+	}
+}
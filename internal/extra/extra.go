@@ -0,0 +1,29 @@
+// Package extra marks the supplementary records in a service's response,
+// eg: cache debug info, that go in the additional section of the DNS
+// response instead of the answer section.
+package extra
+
+import "strings"
+
+// Records are marked with a prefix that can't start a record's text.
+const prefix = "+extra "
+
+// Mark marks a record as supplementary.
+func Mark(rr string) string {
+	return prefix + rr
+}
+
+// Split splits records into the answers and the marked supplementary
+// records with the markers removed.
+func Split(rr []string) ([]string, []string) {
+	var ans, extra []string
+	for _, r := range rr {
+		if strings.HasPrefix(r, prefix) {
+			extra = append(extra, strings.TrimPrefix(r, prefix))
+			continue
+		}
+		ans = append(ans, r)
+	}
+
+	return ans, extra
+}
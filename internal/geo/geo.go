@@ -2,8 +2,15 @@
 package geo
 
 import (
+	"bytes"
+	"crypto/rand"
+	_ "embed"
 	"encoding/csv"
+	"errors"
+	"fmt"
 	"io"
+	"math"
+	"math/big"
 	"os"
 	"regexp"
 	"sort"
@@ -11,10 +18,19 @@ import (
 	"strings"
 )
 
+// Embedded IATA airport codes.
+// Format: code,name,country,lat,lon,timezone
+//
+//go:embed iata.csv
+var iataFile []byte
+
 // Geo is the geolocation controller.
 type Geo struct {
 	locations []Location
 
+	// IATA code => airport.
+	airports map[string]Location
+
 	// { $keyword: { $timezone: $country_code }}
 	tzMap map[string][]Location
 
@@ -34,15 +50,101 @@ type Location struct {
 
 var (
 	reClean = regexp.MustCompile("[^a-z/]+")
+
+	// Coordinates (lat,lon), eg: 52.52,13.40.
+	reCoords = regexp.MustCompile(`^\-?[0-9\.]+,\-?[0-9\.]+$`)
+
+	compassPoints = []string{
+		"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE",
+		"S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW",
+	}
 )
 
-// New initiates a new geo location map.
-func New(filePath string) (*Geo, error) {
+// Columns are the (0 indexed) positions of a location's fields in the
+// rows of a tab separated geo location file. -1 for fields that aren't
+// in the file.
+type Columns struct {
+	ID         int
+	Name       int
+	Lat        int
+	Lon        int
+	Country    int
+	Timezone   int
+	Population int
+}
+
+// DefaultColumns are the columns of the geonames.org files.
+// http://download.geonames.org/export/dump/readme.txt
+var DefaultColumns = Columns{
+	ID:         0,
+	Name:       2,
+	Lat:        4,
+	Lon:        5,
+	Country:    8,
+	Population: 14,
+	Timezone:   17,
+}
+
+// ParseColumns parses a field name (id, name, lat, lon, country, timezone,
+// population) => column position map into Columns. name, lat, lon, and
+// timezone are required.
+func ParseColumns(m map[string]int) (Columns, error) {
+	c := Columns{ID: -1, Name: -1, Lat: -1, Lon: -1, Country: -1, Timezone: -1, Population: -1}
+
+	fields := map[string]*int{
+		"id":         &c.ID,
+		"name":       &c.Name,
+		"lat":        &c.Lat,
+		"lon":        &c.Lon,
+		"country":    &c.Country,
+		"timezone":   &c.Timezone,
+		"population": &c.Population,
+	}
+	for k, v := range m {
+		f, ok := fields[k]
+		if !ok {
+			return c, fmt.Errorf("unknown geo column '%s'", k)
+		}
+		if v < 0 {
+			return c, fmt.Errorf("invalid position %d for geo column '%s'", v, k)
+		}
+		*f = v
+	}
+
+	for _, k := range []string{"name", "lat", "lon", "timezone"} {
+		if *fields[k] < 0 {
+			return c, fmt.Errorf("required geo column '%s' is missing", k)
+		}
+	}
+
+	return c, nil
+}
+
+// max returns the highest column position.
+func (c Columns) max() int {
+	n := 0
+	for _, v := range []int{c.ID, c.Name, c.Lat, c.Lon, c.Country, c.Timezone, c.Population} {
+		if v > n {
+			n = v
+		}
+	}
+
+	return n
+}
+
+// New initiates a new geo location map from a tab separated file with the
+// given columns, eg: DefaultColumns for geonames.org files.
+func New(filePath string, cols Columns) (*Geo, error) {
 	g := &Geo{
-		tzMap: make(map[string][]Location),
+		tzMap:    make(map[string][]Location),
+		airports: make(map[string]Location),
+	}
+
+	if err := g.loadAirports(); err != nil {
+		return nil, err
 	}
 
-	locs, err := g.readFile(filePath)
+	locs, err := g.readFile(filePath, cols)
 	if err != nil {
 		return nil, err
 	}
@@ -63,15 +165,136 @@ func (g *Geo) Query(q string) []Location {
 	return zones
 }
 
+// Compass returns the 16-point compass direction (eg: NNE) for
+// a bearing in degrees.
+func Compass(deg float64) string {
+	deg = math.Mod(deg, 360)
+	if deg < 0 {
+		deg += 360
+	}
+
+	// Each point covers 22.5 degrees centered on it.
+	return compassPoints[int((deg+11.25)/22.5)%16]
+}
+
+// IsCoords checks if a query looks like coordinates (lat,lon), eg: 52.52,13.40,
+// instead of a city name.
+func IsCoords(q string) bool {
+	return reCoords.MatchString(q)
+}
+
+// ParseCoords parses coordinates (lat,lon), eg: 52.52,13.40, into a Location
+// that can be used in place of one from the dataset. Coordinates have no
+// name or timezone, so the shortened coordinates are the name and the
+// timezone is UTC.
+func ParseCoords(q string) (Location, error) {
+	c := strings.Split(q, ",")
+	if len(c) != 2 {
+		return Location{}, errors.New("invalid coordinates. Use lat,lon. eg: 52.52,13.40")
+	}
+
+	lat, err := strconv.ParseFloat(c[0], 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return Location{}, errors.New("invalid latitude. Should be between -90 and 90.")
+	}
+
+	lon, err := strconv.ParseFloat(c[1], 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return Location{}, errors.New("invalid longitude. Should be between -180 and 180.")
+	}
+
+	name := fmt.Sprintf("%0.2f,%0.2f", lat, lon)
+	return Location{
+		ID:       name,
+		Name:     name,
+		Lat:      lat,
+		Lon:      lon,
+		Timezone: "UTC",
+	}, nil
+}
+
+// Lookup looks up locations by a city name or an IATA airport code, eg: LHR.
+// Uppercase 3-letter queries are looked up as airports first, and other
+// 3-letter queries fall back to airports if there's no such city.
+func (g *Geo) Lookup(q string) []Location {
+	isCode := len(q) == 3
+	if isCode && q == strings.ToUpper(q) {
+		if a, ok := g.airports[q]; ok {
+			return []Location{a}
+		}
+	}
+
+	if locs := g.Query(q); locs != nil {
+		return locs
+	}
+
+	if isCode {
+		if a, ok := g.airports[strings.ToUpper(q)]; ok {
+			return []Location{a}
+		}
+	}
+
+	return nil
+}
+
+// Random returns the (queryable) name of a random location,
+// or an empty string if there are no locations.
+func (g *Geo) Random() string {
+	if len(g.locations) == 0 {
+		return ""
+	}
+
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(g.locations))))
+	if err != nil {
+		return ""
+	}
+
+	return Name(g.locations[n.Int64()])
+}
+
+// Search returns up to max locations, biggest first, whose (queryable)
+// names contain the substring q, and the total number of matches.
+func (g *Geo) Search(q string, max int) ([]Location, int) {
+	q = reClean.ReplaceAllString(strings.ToLower(q), "")
+	if q == "" {
+		return nil, 0
+	}
+
+	var out []Location
+	for _, l := range g.locations {
+		if strings.Contains(Name(l), q) {
+			out = append(out, l)
+		}
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Population > out[j].Population
+	})
+
+	total := len(out)
+	if max > 0 && total > max {
+		out = out[:max]
+	}
+
+	return out, total
+}
+
+// Name returns the name of a location as it's queried, eg: newyorkcity.
+func Name(l Location) string {
+	return reClean.ReplaceAllString(strings.ToLower(l.Name), "")
+}
+
 // Count returns the number of unique locations loaded.
 func (g *Geo) Count() int {
 	return g.count
 }
 
 func (g *Geo) load(locs []Location) {
+	g.locations = locs
+
 	for _, l := range locs {
 		// Add the city name.
-		name := reClean.ReplaceAllString(strings.ToLower(l.Name), "")
+		name := Name(l)
 
 		if _, ok := g.tzMap[name]; !ok {
 			g.tzMap[name] = []Location{}
@@ -99,9 +322,38 @@ func (g *Geo) load(locs []Location) {
 	}
 }
 
+// loadAirports loads the embedded IATA airport codes.
+func (g *Geo) loadAirports() error {
+	rd := csv.NewReader(bytes.NewReader(iataFile))
+	rd.FieldsPerRecord = 6
+
+	recs, err := rd.ReadAll()
+	if err != nil {
+		return err
+	}
+
+	for _, r := range recs {
+		var (
+			lat, _ = strconv.ParseFloat(r[3], 64)
+			lon, _ = strconv.ParseFloat(r[4], 64)
+		)
+
+		g.airports[r[0]] = Location{
+			ID:       "iata:" + r[0],
+			Name:     r[1] + " " + r[0],
+			Lat:      lat,
+			Lon:      lon,
+			Country:  r[2],
+			Timezone: r[5],
+		}
+	}
+
+	return nil
+}
+
 // readFile loads a geonames.org geolocation file and returns the list
 // of parses Locations.
-func (g *Geo) readFile(filePath string) ([]Location, error) {
+func (g *Geo) readFile(filePath string, cols Columns) ([]Location, error) {
 	f, err := os.Open(filePath)
 	if err != nil {
 		return nil, err
@@ -110,9 +362,13 @@ func (g *Geo) readFile(filePath string) ([]Location, error) {
 
 	rd := csv.NewReader(f)
 	rd.Comma = '\t'
+	rd.FieldsPerRecord = -1
 
-	out := []Location{}
-	for {
+	var (
+		out = []Location{}
+		n   = cols.max() + 1
+	)
+	for i := 0; ; i++ {
 		r, err := rd.Read()
 		if err != nil {
 			if err == io.EOF {
@@ -122,27 +378,41 @@ func (g *Geo) readFile(filePath string) ([]Location, error) {
 			return nil, err
 		}
 
-		if len(r) != 19 {
+		if len(r) < n {
 			continue
 		}
 
 		// Create the location record.
 		var (
-			lat, _ = strconv.ParseFloat(r[4], 32)
-			lon, _ = strconv.ParseFloat(r[5], 32)
-			pop, _ = strconv.Atoi(r[14])
+			lat, _ = strconv.ParseFloat(r[cols.Lat], 32)
+			lon, _ = strconv.ParseFloat(r[cols.Lon], 32)
+			name   = r[cols.Name]
+
+			// Rows are the IDs if the file doesn't have them.
+			id      = strconv.Itoa(i)
+			country = ""
+			pop     = 0
 		)
+		if cols.ID >= 0 {
+			id = r[cols.ID]
+		}
+		if cols.Country >= 0 {
+			country = r[cols.Country]
+		}
+		if cols.Population >= 0 {
+			pop, _ = strconv.Atoi(r[cols.Population])
+		}
 
 		// Remove values in brackets.
-		r[2] = strings.TrimSpace(strings.Split(r[2], "(")[0])
+		name = strings.TrimSpace(strings.Split(name, "(")[0])
 
 		out = append(out, Location{
-			ID:         r[0],
-			Name:       r[2],
+			ID:         id,
+			Name:       name,
 			Lat:        lat,
 			Lon:        lon,
-			Country:    r[8],
-			Timezone:   r[17],
+			Country:    country,
+			Timezone:   r[cols.Timezone],
 			Population: pop,
 		})
 	}
@@ -0,0 +1,219 @@
+package geo
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseCoords(t *testing.T) {
+	tests := []struct {
+		in       string
+		coords   bool
+		lat, lon float64
+		err      bool
+	}{
+		{"52.52,13.40", true, 52.52, 13.40, false},
+		{"-33.87,151.21", true, -33.87, 151.21, false},
+		{"90,-180", true, 90, -180, false},
+		{"90.1,0", true, 0, 0, true},
+		{"0,180.5", true, 0, 0, true},
+		{"1.2.3,4", true, 0, 0, true},
+		{"berlin", false, 0, 0, false},
+		{"52.52", false, 0, 0, false},
+	}
+
+	for _, tc := range tests {
+		if c := IsCoords(tc.in); c != tc.coords {
+			t.Fatalf("%s: expected IsCoords=%v, got %v", tc.in, tc.coords, c)
+		}
+		if !tc.coords {
+			continue
+		}
+
+		l, err := ParseCoords(tc.in)
+		if tc.err {
+			if err == nil {
+				t.Fatalf("%s: expected an error", tc.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.in, err)
+		}
+		if l.Lat != tc.lat || l.Lon != tc.lon || l.Timezone != "UTC" {
+			t.Fatalf("%s: unexpected location: %+v", tc.in, l)
+		}
+	}
+}
+
+func TestCompass(t *testing.T) {
+	tests := []struct {
+		deg float64
+		out string
+	}{
+		{0, "N"},
+		{11.24, "N"},
+		{11.25, "NNE"},
+		{45, "NE"},
+		{90, "E"},
+		{180, "S"},
+		{270, "W"},
+		{348.74, "NNW"},
+		{348.75, "N"},
+		{359.9, "N"},
+		{360, "N"},
+		{370, "N"},
+		{-22.5, "NNW"},
+		{-90, "W"},
+	}
+
+	for _, tc := range tests {
+		if out := Compass(tc.deg); out != tc.out {
+			t.Errorf("%v: expected %s, got %s", tc.deg, tc.out, out)
+		}
+	}
+}
+
+func TestParseColumns(t *testing.T) {
+	tests := []struct {
+		name string
+		in   map[string]int
+		out  Columns
+		err  string
+	}{
+		{"required only",
+			map[string]int{"name": 0, "lat": 1, "lon": 2, "timezone": 3},
+			Columns{ID: -1, Name: 0, Lat: 1, Lon: 2, Country: -1, Timezone: 3, Population: -1}, ""},
+		{"all",
+			map[string]int{"id": 6, "name": 5, "lat": 4, "lon": 3, "country": 2, "timezone": 1, "population": 0},
+			Columns{ID: 6, Name: 5, Lat: 4, Lon: 3, Country: 2, Timezone: 1, Population: 0}, ""},
+		{"missing name", map[string]int{"lat": 1, "lon": 2, "timezone": 3}, Columns{}, "'name' is missing"},
+		{"missing timezone", map[string]int{"name": 0, "lat": 1, "lon": 2}, Columns{}, "'timezone' is missing"},
+		{"unknown", map[string]int{"name": 0, "lat": 1, "lon": 2, "timezone": 3, "elevation": 4}, Columns{}, "unknown geo column 'elevation'"},
+		{"negative", map[string]int{"name": -1, "lat": 1, "lon": 2, "timezone": 3}, Columns{}, "invalid position -1"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := ParseColumns(tc.in)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected error %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out != tc.out {
+				t.Fatalf("expected %+v, got %+v", tc.out, out)
+			}
+		})
+	}
+}
+
+func TestNewColumns(t *testing.T) {
+	// Timezone, country, name, lon, lat without IDs and population.
+	rows := []string{
+		"Europe/Berlin\tDE\tBerlin\t13.40\t52.52",
+		"Asia/Tokyo\tJP\tTokyo (Tōkyō)\t139.69\t35.68",
+		"short\trow",
+	}
+	fPath := filepath.Join(t.TempDir(), "cities.tsv")
+	if err := ioutil.WriteFile(fPath, []byte(strings.Join(rows, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cols, err := ParseColumns(map[string]int{"timezone": 0, "country": 1, "name": 2, "lon": 3, "lat": 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := New(fPath, cols)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		q   string
+		exp Location
+	}{
+		{"berlin", Location{ID: "0", Name: "Berlin", Country: "DE", Timezone: "Europe/Berlin"}},
+		{"tokyo", Location{ID: "1", Name: "Tokyo", Country: "JP", Timezone: "Asia/Tokyo"}},
+	}
+	for _, tc := range tests {
+		locs := g.Query(tc.q)
+		if len(locs) != 1 {
+			t.Fatalf("%s: expected 1 location, got %v", tc.q, locs)
+		}
+
+		l := locs[0]
+		if l.ID != tc.exp.ID || l.Name != tc.exp.Name || l.Country != tc.exp.Country || l.Timezone != tc.exp.Timezone {
+			t.Fatalf("%s: expected %+v, got %+v", tc.q, tc.exp, l)
+		}
+		if l.Lat < 30 || l.Lat > 60 || l.Lon < 10 || l.Lon > 140 {
+			t.Fatalf("%s: lat and lon are swapped: %+v", tc.q, l)
+		}
+	}
+
+	// Rows without all the columns are skipped.
+	if c := g.Count(); c != 2 {
+		t.Fatalf("expected 2 locations, got %d", c)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	// Los, Sweden shares its name with the code of Lagos' airport.
+	rows := []string{
+		"1\tLondon\tLondon\t\t51.50\t-0.12\t\t\tGB\t\t\t\t\t\t8000000\t\t\tEurope/London\t",
+		"2\tLos\tLos\t\t61.73\t15.16\t\t\tSE\t\t\t\t\t\t400\t\t\tEurope/Stockholm\t",
+	}
+	fPath := filepath.Join(t.TempDir(), "cities.txt")
+	if err := ioutil.WriteFile(fPath, []byte(strings.Join(rows, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	g, err := New(fPath, DefaultColumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		q       string
+		id      string
+		country string
+		tz      string
+	}{
+		// Airport codes in either case.
+		{"LHR", "iata:LHR", "GB", "Europe/London"},
+		{"lhr", "iata:LHR", "GB", "Europe/London"},
+		{"Lhr", "iata:LHR", "GB", "Europe/London"},
+
+		// Exact city names win over codes unless the code is in uppercase.
+		{"los", "2", "SE", "Europe/Stockholm"},
+		{"Los", "2", "SE", "Europe/Stockholm"},
+		{"LOS", "iata:LOS", "NG", "Africa/Lagos"},
+
+		{"london", "1", "GB", "Europe/London"},
+	}
+	for _, tc := range tests {
+		locs := g.Lookup(tc.q)
+		if len(locs) != 1 {
+			t.Fatalf("%s: expected 1 location, got %v", tc.q, locs)
+		}
+		if l := locs[0]; l.ID != tc.id || l.Country != tc.country || l.Timezone != tc.tz {
+			t.Fatalf("%s: unexpected location: %+v", tc.q, l)
+		}
+	}
+
+	// London Heathrow is near London.
+	if l := g.Lookup("LHR")[0]; l.Lat < 51 || l.Lat > 52 || l.Lon < -1 || l.Lon > 0 || !strings.Contains(l.Name, "London") {
+		t.Fatalf("unexpected location for LHR: %+v", l)
+	}
+
+	for _, q := range []string{"XYZ", "xyz", "atlantis"} {
+		if locs := g.Lookup(q); locs != nil {
+			t.Fatalf("%s: expected no locations, got %v", q, locs)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+// Package i18n has small translation tables for localizing service output.
+package i18n
+
+import (
+	"strings"
+	"time"
+)
+
+// Default is the default (fallback) language.
+const Default = "en"
+
+// Short weekday names by language, starting from Sunday.
+var weekdays = map[string][7]string{
+	"en": {"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+	"de": {"So", "Mo", "Di", "Mi", "Do", "Fr", "Sa"},
+	"fr": {"dim", "lun", "mar", "mer", "jeu", "ven", "sam"},
+	"es": {"dom", "lun", "mar", "mié", "jue", "vie", "sáb"},
+}
+
+// Lang returns the given language code if it's supported or the
+// default language otherwise.
+func Lang(l string) string {
+	l = strings.ToLower(l)
+	if _, ok := weekdays[l]; ok {
+		return l
+	}
+
+	return Default
+}
+
+// Weekday returns the short name of a weekday in the given language.
+func Weekday(lang string, d time.Weekday) string {
+	return weekdays[Lang(lang)][d]
+}
+
+// ParseLang checks if a query token is a language selector (eg: lang-de)
+// and returns the language code.
+func ParseLang(s string) (string, bool) {
+	if !strings.HasPrefix(s, "lang-") {
+		return "", false
+	}
+
+	return Lang(strings.TrimPrefix(s, "lang-")), true
+}
@@ -0,0 +1,124 @@
+// Package numfmt formats numbers with optional digit grouping
+// (thousands separators) for numeric service outputs.
+package numfmt
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Format represents a number format. The zero value formats
+// numbers without any grouping.
+type Format struct {
+	// Group digits, eg: 1,234,567.89.
+	Grouping bool
+
+	thousands string
+	decimal   string
+	indian    bool
+}
+
+// Separator styles.
+var styles = map[string]Format{
+	// 1,234,567.89
+	"en": {thousands: ",", decimal: "."},
+
+	// 1.234.567,89
+	"eu": {thousands: ".", decimal: ","},
+
+	// 1 234 567.89
+	"si": {thousands: " ", decimal: "."},
+
+	// 12,34,567.89
+	"in": {thousands: ",", decimal: ".", indian: true},
+}
+
+// New returns a new Format. style is one of en, eu, si, in.
+func New(grouping bool, style string) (Format, error) {
+	if !grouping {
+		return Format{}, nil
+	}
+
+	f, ok := styles[style]
+	if !ok {
+		return Format{}, fmt.Errorf("unknown number style '%s'. Use en, eu, si, or in.", style)
+	}
+	f.Grouping = true
+
+	return f, nil
+}
+
+// Float formats a float with the given decimal precision.
+func (f Format) Float(v float64, prec int) string {
+	return f.Decimal(strconv.FormatFloat(v, 'f', prec, 64))
+}
+
+// Decimal formats a plain decimal string, eg: -1234567.89. This is useful
+// for numbers that are computed with arbitrary precision.
+func (f Format) Decimal(s string) string {
+	if !f.Grouping {
+		return s
+	}
+
+	// Split the sign, integer, and fraction parts.
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign = "-"
+		s = s[1:]
+	}
+
+	intPart, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, frac = s[:i], s[i+1:]
+	}
+
+	out := sign + f.group(intPart)
+	if frac != "" {
+		out += f.decimal + frac
+	}
+
+	return out
+}
+
+// Int formats an integer.
+func (f Format) Int(v int64) string {
+	s := strconv.FormatInt(v, 10)
+	if !f.Grouping {
+		return s
+	}
+
+	if strings.HasPrefix(s, "-") {
+		return "-" + f.group(s[1:])
+	}
+
+	return f.group(s)
+}
+
+// group inserts the thousands separator into a string of digits.
+func (f Format) group(s string) string {
+	if len(s) <= 3 {
+		return s
+	}
+
+	// The last group is always 3 digits. The rest are 3 digits, or 2
+	// in the Indian system.
+	var (
+		n     = 3
+		parts = []string{s[len(s)-3:]}
+	)
+	if f.indian {
+		n = 2
+	}
+
+	s = s[:len(s)-3]
+	for len(s) > n {
+		parts = append([]string{s[len(s)-n:]}, parts...)
+		s = s[:len(s)-n]
+	}
+	if s != "" {
+		parts = append([]string{s}, parts...)
+	}
+
+	return strings.Join(parts, f.thousands)
+}
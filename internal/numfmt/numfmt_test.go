@@ -0,0 +1,92 @@
+package numfmt
+
+import "testing"
+
+func TestInt(t *testing.T) {
+	tests := []struct {
+		style string
+		in    int64
+		out   string
+	}{
+		{"en", 0, "0"},
+		{"en", 999, "999"},
+		{"en", 1000, "1,000"},
+		{"en", 1234567, "1,234,567"},
+		{"en", -1234567, "-1,234,567"},
+		{"en", -100, "-100"},
+		{"eu", 1234567, "1.234.567"},
+		{"si", 1234567, "1 234 567"},
+		{"in", 1234567, "12,34,567"},
+		{"in", -123456789, "-12,34,56,789"},
+	}
+
+	for _, tc := range tests {
+		f, err := New(true, tc.style)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out := f.Int(tc.in); out != tc.out {
+			t.Errorf("%s %d: expected %s, got %s", tc.style, tc.in, tc.out, out)
+		}
+	}
+}
+
+func TestFloat(t *testing.T) {
+	tests := []struct {
+		style string
+		in    float64
+		prec  int
+		out   string
+	}{
+		{"en", 1234567.891, 2, "1,234,567.89"},
+		{"en", -1234567.891, 2, "-1,234,567.89"},
+		{"en", 0.5, 2, "0.50"},
+		{"en", -999.5, 1, "-999.5"},
+		{"en", 1234.5, 0, "1,234"},
+		{"eu", 1234567.891, 2, "1.234.567,89"},
+		{"si", -1234.5, 1, "-1 234.5"},
+		{"in", 1234567.891, 2, "12,34,567.89"},
+	}
+
+	for _, tc := range tests {
+		f, err := New(true, tc.style)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out := f.Float(tc.in, tc.prec); out != tc.out {
+			t.Errorf("%s %v: expected %s, got %s", tc.style, tc.in, tc.out, out)
+		}
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	f, _ := New(true, "en")
+	if out := f.Decimal("-123456789012345678901.25"); out != "-123,456,789,012,345,678,901.25" {
+		t.Errorf("unexpected output: %s", out)
+	}
+}
+
+func TestNoGrouping(t *testing.T) {
+	// Numbers are left as is for machine parsing by default.
+	f, err := New(false, "whatever")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := f.Int(-1234567); out != "-1234567" {
+		t.Errorf("expected -1234567, got %s", out)
+	}
+	if out := f.Float(1234567.891, 2); out != "1234567.89" {
+		t.Errorf("expected 1234567.89, got %s", out)
+	}
+
+	var z Format
+	if out := z.Decimal("1234.5"); out != "1234.5" {
+		t.Errorf("expected 1234.5, got %s", out)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(true, "xx"); err == nil {
+		t.Error("expected an error for an unknown style")
+	}
+}
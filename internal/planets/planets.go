@@ -0,0 +1,233 @@
+// Package planets computes the rough (within a degree or two) positions
+// of the moon and the planets in the sky and their rise and set times.
+// Algorithm: https://stjarnhimlen.se/comp/ppcomp.html
+package planets
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+const (
+	// Julian date of 2000-01-00 00:00 UTC, the epoch of the orbital elements.
+	epoch = 2451543.5
+
+	// Julian date of the Unix epoch.
+	jUnix = 2440587.5
+
+	// Altitudes (in degrees) at which bodies rise and set, accounting for
+	// refraction, and for the moon, its parallax and radius.
+	horizon     = -0.583
+	moonHorizon = 0.125
+
+	// Step for scanning the altitude over a day to find the crossings.
+	step = time.Minute * 10
+)
+
+// elements are the orbital elements of a body (in degrees), d days after
+// the epoch. The moon's are geocentric and the planets' heliocentric.
+type elements func(d float64) (n, i, w, a, e, m float64)
+
+var (
+	// ErrAlwaysAbove is returned when a body stays above the horizon
+	// for the whole day.
+	ErrAlwaysAbove = errors.New("body is always above the horizon")
+
+	// ErrAlwaysBelow is returned when a body stays below the horizon
+	// for the whole day.
+	ErrAlwaysBelow = errors.New("body is always below the horizon")
+
+	// ErrUnknown is returned for unknown bodies.
+	ErrUnknown = errors.New("unknown body")
+)
+
+// Bodies are the names of the bodies that can be computed.
+var Bodies = []string{"moon", "mercury", "venus", "mars", "jupiter", "saturn", "uranus", "neptune"}
+
+var bodies = map[string]elements{
+	"sun": func(d float64) (float64, float64, float64, float64, float64, float64) {
+		return 0, 0, 282.9404 + 4.70935e-5*d, 1, 0.016709 - 1.151e-9*d, 356.0470 + 0.9856002585*d
+	},
+	"moon": func(d float64) (float64, float64, float64, float64, float64, float64) {
+		return 125.1228 - 0.0529538083*d, 5.1454, 318.0634 + 0.1643573223*d, 60.2666, 0.054900, 115.3654 + 13.0649929509*d
+	},
+	"mercury": func(d float64) (float64, float64, float64, float64, float64, float64) {
+		return 48.3313 + 3.24587e-5*d, 7.0047 + 5.00e-8*d, 29.1241 + 1.01444e-5*d, 0.387098, 0.205635 + 5.59e-10*d, 168.6562 + 4.0923344368*d
+	},
+	"venus": func(d float64) (float64, float64, float64, float64, float64, float64) {
+		return 76.6799 + 2.46590e-5*d, 3.3946 + 2.75e-8*d, 54.8910 + 1.38374e-5*d, 0.723330, 0.006773 - 1.302e-9*d, 48.0052 + 1.6021302244*d
+	},
+	"mars": func(d float64) (float64, float64, float64, float64, float64, float64) {
+		return 49.5574 + 2.11081e-5*d, 1.8497 - 1.78e-8*d, 286.5016 + 2.92961e-5*d, 1.523688, 0.093405 + 2.516e-9*d, 18.6021 + 0.5240207766*d
+	},
+	"jupiter": func(d float64) (float64, float64, float64, float64, float64, float64) {
+		return 100.4542 + 2.76854e-5*d, 1.3030 - 1.557e-7*d, 273.8777 + 1.64505e-5*d, 5.20256, 0.048498 + 4.469e-9*d, 19.8950 + 0.0830853001*d
+	},
+	"saturn": func(d float64) (float64, float64, float64, float64, float64, float64) {
+		return 113.6634 + 2.38980e-5*d, 2.4886 - 1.081e-7*d, 339.3939 + 2.97661e-5*d, 9.55475, 0.055546 - 9.499e-9*d, 316.9670 + 0.0334442282*d
+	},
+	"uranus": func(d float64) (float64, float64, float64, float64, float64, float64) {
+		return 74.0005 + 1.3978e-5*d, 0.7733 + 1.9e-8*d, 96.6612 + 3.0565e-5*d, 19.18171 - 1.55e-8*d, 0.047318 + 7.45e-9*d, 142.5905 + 0.011725806*d
+	},
+	"neptune": func(d float64) (float64, float64, float64, float64, float64, float64) {
+		return 131.7806 + 3.0173e-5*d, 1.7700 - 2.55e-7*d, 272.8461 - 6.027e-6*d, 30.05826 + 3.313e-8*d, 0.008606 + 2.15e-9*d, 260.2471 + 0.005995147*d
+	},
+}
+
+// Position returns the altitude and the azimuth (degrees, clockwise from
+// north) of a body at a time at the given coordinates.
+func Position(body string, t time.Time, lat, lon float64) (float64, float64, error) {
+	if _, ok := bodies[body]; !ok || body == "sun" {
+		return 0, 0, ErrUnknown
+	}
+
+	alt, az := position(body, t, lat, lon)
+	return alt, az, nil
+}
+
+// RiseSet returns the times at which a body rises and sets on the date (in
+// its location) at the given coordinates. The times are in the date's
+// location. A body may rise or set only once on a day, eg: the moon, in
+// which case the other time is zero.
+func RiseSet(body string, date time.Time, lat, lon float64) (time.Time, time.Time, error) {
+	if _, ok := bodies[body]; !ok || body == "sun" {
+		return time.Time{}, time.Time{}, ErrUnknown
+	}
+
+	h0 := horizon
+	if body == "moon" {
+		h0 = moonHorizon
+	}
+
+	var (
+		y, m, d = date.Date()
+		start   = time.Date(y, m, d, 0, 0, 0, 0, date.Location())
+		end     = start.AddDate(0, 0, 1)
+
+		rise, set time.Time
+		above     = false
+	)
+
+	// Scan the altitude over the day and interpolate the crossings.
+	prevT := start
+	prev, _ := position(body, prevT, lat, lon)
+	prev -= h0
+	if prev > 0 {
+		above = true
+	}
+	for t := start.Add(step); !t.After(end); t = t.Add(step) {
+		cur, _ := position(body, t, lat, lon)
+		cur -= h0
+
+		if (prev <= 0) != (cur <= 0) {
+			at := prevT.Add(time.Duration(float64(step) * prev / (prev - cur)))
+			if cur > 0 && rise.IsZero() {
+				rise = at
+			} else if cur <= 0 && set.IsZero() {
+				set = at
+			}
+		}
+
+		prev, prevT = cur, t
+	}
+
+	if rise.IsZero() && set.IsZero() {
+		if above {
+			return rise, set, ErrAlwaysAbove
+		}
+		return rise, set, ErrAlwaysBelow
+	}
+
+	return rise, set, nil
+}
+
+// position returns the altitude and the azimuth of a body.
+func position(body string, t time.Time, lat, lon float64) (float64, float64) {
+	var (
+		d   = float64(t.Unix())/86400 + jUnix - epoch
+		ecl = 23.4393 - 3.563e-7*d
+	)
+
+	// The sun's geocentric position, which is the opposite of the earth's
+	// heliocentric position.
+	xs, ys, _, sunLon := ecliptic(bodies["sun"], d)
+
+	x, y, z, _ := ecliptic(bodies[body], d)
+	if body != "moon" {
+		x, y = x+xs, y+ys
+	}
+
+	// Equatorial coordinates.
+	var (
+		xe = x
+		ye = y*cos(ecl) - z*sin(ecl)
+		ze = y*sin(ecl) + z*cos(ecl)
+		ra = atan2(ye, xe)
+		dc = atan2(ze, math.Sqrt(xe*xe+ye*ye))
+	)
+
+	// Local sidereal time and the hour angle.
+	var (
+		ut  = float64(t.UTC().Hour()) + float64(t.UTC().Minute())/60 + float64(t.UTC().Second())/3600
+		lst = sunLon + 180 + ut*15 + lon
+		ha  = lst - ra
+	)
+
+	// Horizontal coordinates.
+	var (
+		hx = cos(ha)*cos(dc)*sin(lat) - sin(dc)*cos(lat)
+		hy = sin(ha) * cos(dc)
+		hz = cos(ha)*cos(dc)*cos(lat) + sin(dc)*sin(lat)
+	)
+
+	alt := math.Asin(hz) * 180 / math.Pi
+	az := math.Mod(atan2(hy, hx)+180, 360)
+
+	return alt, az
+}
+
+// ecliptic returns the rectangular ecliptic coordinates of a body from its
+// orbital elements, and for the sun, its mean longitude.
+func ecliptic(el elements, d float64) (float64, float64, float64, float64) {
+	n, i, w, a, e, m := el(d)
+	m = math.Mod(m, 360)
+
+	// Eccentric anomaly.
+	ea := m + e*(180/math.Pi)*sin(m)*(1+e*cos(m))
+	for k := 0; k < 10; k++ {
+		next := ea - (ea-e*(180/math.Pi)*sin(ea)-m)/(1-e*cos(ea))
+		if math.Abs(next-ea) < 0.001 {
+			ea = next
+			break
+		}
+		ea = next
+	}
+
+	// True anomaly and the distance.
+	var (
+		xv = a * (cos(ea) - e)
+		yv = a * math.Sqrt(1-e*e) * sin(ea)
+		v  = atan2(yv, xv)
+		r  = math.Sqrt(xv*xv + yv*yv)
+		vw = v + w
+	)
+
+	x := r * (cos(n)*cos(vw) - sin(n)*sin(vw)*cos(i))
+	y := r * (sin(n)*cos(vw) + cos(n)*sin(vw)*cos(i))
+	z := r * sin(vw) * sin(i)
+
+	return x, y, z, m + w
+}
+
+func sin(deg float64) float64 {
+	return math.Sin(deg * math.Pi / 180)
+}
+
+func cos(deg float64) float64 {
+	return math.Cos(deg * math.Pi / 180)
+}
+
+func atan2(y, x float64) float64 {
+	return math.Atan2(y, x) * 180 / math.Pi
+}
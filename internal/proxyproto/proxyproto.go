@@ -0,0 +1,299 @@
+// Package proxyproto implements a net.Listener that parses PROXY protocol
+// (v1 and v2) headers sent by proxies and load balancers so that the real
+// client address is visible to the server.
+// Spec: https://www.haproxy.org/download/2.8/doc/proxy-protocol.txt
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Max length of a v1 header including the CRLF.
+	maxV1Len = 107
+
+	// Time allowed for the proxy to send the header.
+	headerTimeout = 5 * time.Second
+)
+
+var (
+	sigV1 = []byte("PROXY ")
+	sigV2 = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+	// ErrInvalidHeader is returned when a connection has a malformed
+	// or missing PROXY header.
+	ErrInvalidHeader = errors.New("invalid PROXY protocol header")
+
+	// ErrUntrusted is returned when a connection from a peer that isn't
+	// a trusted proxy has a PROXY header.
+	ErrUntrusted = errors.New("PROXY protocol header from an untrusted peer")
+)
+
+// Listener wraps a net.Listener and parses the PROXY header on
+// the connections accepted from trusted proxies.
+type Listener struct {
+	net.Listener
+
+	// Networks of the proxies whose headers are trusted.
+	trusted []*net.IPNet
+}
+
+// Conn is a net.Conn whose RemoteAddr() is the client address
+// from the PROXY header.
+type Conn struct {
+	net.Conn
+
+	rd      *bufio.Reader
+	trusted bool
+	once    sync.Once
+	err     error
+	remote  net.Addr
+}
+
+// NewListener returns a new PROXY protocol Listener wrapping l. Connections
+// from the peers in trusted must have a PROXY header. Connections from other
+// peers are passed through as is, and are closed if they have a PROXY header
+// so that clients can't spoof their addresses.
+func NewListener(l net.Listener, trusted []*net.IPNet) *Listener {
+	return &Listener{Listener: l, trusted: trusted}
+}
+
+// ParseCIDRs parses a list of networks (eg: 10.0.0.0/8) and IPs.
+func ParseCIDRs(s []string) ([]*net.IPNet, error) {
+	out := make([]*net.IPNet, 0, len(s))
+	for _, c := range s {
+		if !strings.Contains(c, "/") {
+			ip := net.ParseIP(c)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP '%s'", c)
+			}
+
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			out = append(out, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network '%s'", c)
+		}
+		out = append(out, n)
+	}
+
+	return out, nil
+}
+
+// Accept accepts a connection. The PROXY header is parsed lazily
+// on the first Read() or RemoteAddr() call so that a slow client
+// doesn't block Accept().
+func (l *Listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Conn{
+		Conn:    c,
+		rd:      bufio.NewReaderSize(c, 256),
+		trusted: l.isTrusted(c.RemoteAddr()),
+	}, nil
+}
+
+// isTrusted checks if an address is in the trusted networks.
+func (l *Listener) isTrusted(addr net.Addr) bool {
+	a, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+
+	for _, n := range l.trusted {
+		if n.Contains(a.IP) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Read reads from the connection after the PROXY header.
+func (c *Conn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	return c.rd.Read(b)
+}
+
+// RemoteAddr returns the client address from the PROXY header, or
+// the address of the proxy if the header carries no address (LOCAL/UNKNOWN).
+func (c *Conn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.remote != nil {
+		return c.remote
+	}
+
+	return c.Conn.RemoteAddr()
+}
+
+// readHeader reads and parses the PROXY header. A malformed header, or a
+// header from an untrusted peer, closes the connection.
+func (c *Conn) readHeader() {
+	c.Conn.SetReadDeadline(time.Now().Add(headerTimeout))
+	defer c.Conn.SetReadDeadline(time.Time{})
+
+	if !c.trusted {
+		if hasSignature(c.rd) {
+			c.err = ErrUntrusted
+			c.Conn.Close()
+		}
+		return
+	}
+
+	addr, err := parseHeader(c.rd)
+	if err != nil {
+		c.err = err
+		c.Conn.Close()
+		return
+	}
+
+	c.remote = addr
+}
+
+// parseHeader parses a v1 or v2 PROXY header from the reader and returns
+// the source address. The address is nil for LOCAL and UNKNOWN headers.
+func parseHeader(rd *bufio.Reader) (net.Addr, error) {
+	b, err := rd.Peek(len(sigV2))
+	if err == nil && bytes.Equal(b, sigV2) {
+		return parseV2(rd)
+	}
+
+	b, err = rd.Peek(len(sigV1))
+	if err == nil && bytes.Equal(b, sigV1) {
+		return parseV1(rd)
+	}
+
+	return nil, ErrInvalidHeader
+}
+
+// hasSignature checks if the reader starts with a v1 or v2 PROXY header
+// signature. DNS messages over TCP (and TLS handshakes) are longer than
+// the signatures, so peeking doesn't block on them.
+func hasSignature(rd *bufio.Reader) bool {
+	if b, err := rd.Peek(len(sigV1)); err != nil || bytes.Equal(b, sigV1) {
+		return err == nil
+	}
+
+	b, err := rd.Peek(len(sigV2))
+	return err == nil && bytes.Equal(b, sigV2)
+}
+
+// parseV1 parses a text header. eg:
+// PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n
+func parseV1(rd *bufio.Reader) (net.Addr, error) {
+	var line []byte
+	for len(line) < maxV1Len {
+		c, err := rd.ReadByte()
+		if err != nil {
+			return nil, ErrInvalidHeader
+		}
+
+		line = append(line, c)
+		if c == '\n' {
+			break
+		}
+	}
+
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, ErrInvalidHeader
+	}
+
+	f := strings.Split(strings.TrimSuffix(string(line), "\r\n"), " ")
+	if len(f) >= 2 && f[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(f) != 6 || (f[1] != "TCP4" && f[1] != "TCP6") {
+		return nil, ErrInvalidHeader
+	}
+
+	ip := net.ParseIP(f[2])
+	if ip == nil || net.ParseIP(f[3]) == nil {
+		return nil, ErrInvalidHeader
+	}
+	if (f[1] == "TCP4") != (ip.To4() != nil) {
+		return nil, ErrInvalidHeader
+	}
+
+	port, err := strconv.Atoi(f[4])
+	if err != nil || port < 0 || port > 65535 {
+		return nil, ErrInvalidHeader
+	}
+	if p, err := strconv.Atoi(f[5]); err != nil || p < 0 || p > 65535 {
+		return nil, ErrInvalidHeader
+	}
+
+	return &net.TCPAddr{IP: ip, Port: port}, nil
+}
+
+// parseV2 parses a binary header.
+func parseV2(rd *bufio.Reader) (net.Addr, error) {
+	hdr := make([]byte, 16)
+	if _, err := io.ReadFull(rd, hdr); err != nil {
+		return nil, ErrInvalidHeader
+	}
+
+	var (
+		ver  = hdr[12] >> 4
+		cmd  = hdr[12] & 0x0f
+		fam  = hdr[13]
+		size = int(binary.BigEndian.Uint16(hdr[14:16]))
+	)
+	if ver != 2 || cmd > 1 {
+		return nil, ErrInvalidHeader
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(rd, body); err != nil {
+		return nil, ErrInvalidHeader
+	}
+
+	// LOCAL command (eg: proxy health checks) has no client address.
+	if cmd == 0 {
+		return nil, nil
+	}
+
+	switch fam {
+	// TCP over IPv4.
+	case 0x11:
+		if size < 12 {
+			return nil, ErrInvalidHeader
+		}
+		return &net.TCPAddr{IP: net.IP(body[0:4]), Port: int(binary.BigEndian.Uint16(body[8:10]))}, nil
+
+	// TCP over IPv6.
+	case 0x21:
+		if size < 36 {
+			return nil, ErrInvalidHeader
+		}
+		return &net.TCPAddr{IP: net.IP(body[0:16]), Port: int(binary.BigEndian.Uint16(body[32:34]))}, nil
+
+	// UNSPEC.
+	case 0x00:
+		return nil, nil
+	}
+
+	return nil, ErrInvalidHeader
+}
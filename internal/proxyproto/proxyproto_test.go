@@ -0,0 +1,195 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+)
+
+// v2 returns a binary header with the given version/command,
+// family, and body.
+func v2(verCmd, fam byte, body []byte) []byte {
+	b := append([]byte{}, sigV2...)
+	b = append(b, verCmd, fam, 0, 0)
+	binary.BigEndian.PutUint16(b[14:16], uint16(len(body)))
+	return append(b, body...)
+}
+
+// v2Body returns the address block for a source and destination.
+func v2Body(src, dst net.IP, srcPort, dstPort uint16) []byte {
+	ports := make([]byte, 4)
+	binary.BigEndian.PutUint16(ports[0:2], srcPort)
+	binary.BigEndian.PutUint16(ports[2:4], dstPort)
+
+	b := append(append([]byte{}, src...), dst...)
+	return append(b, ports...)
+}
+
+func TestParseHeader(t *testing.T) {
+	var (
+		ip4 = net.ParseIP("192.168.0.1").To4()
+		ip6 = net.ParseIP("2001:db8::1")
+	)
+
+	tests := []struct {
+		name string
+		in   []byte
+		addr string
+		err  bool
+	}{
+		{"v1 tcp4", []byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 53\r\n"), "192.168.0.1:56324", false},
+		{"v1 tcp6", []byte("PROXY TCP6 2001:db8::1 2001:db8::2 56324 53\r\n"), "[2001:db8::1]:56324", false},
+		{"v1 unknown", []byte("PROXY UNKNOWN\r\n"), "", false},
+		{"v1 no crlf", []byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 53\n"), "", true},
+		{"v1 truncated", []byte("PROXY TCP4 192.168.0.1"), "", true},
+		{"v1 bad ip", []byte("PROXY TCP4 192.168.0 192.168.0.11 56324 53\r\n"), "", true},
+		{"v1 family mismatch", []byte("PROXY TCP4 2001:db8::1 2001:db8::2 56324 53\r\n"), "", true},
+		{"v1 bad port", []byte("PROXY TCP4 192.168.0.1 192.168.0.11 65536 53\r\n"), "", true},
+		{"v1 missing field", []byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324\r\n"), "", true},
+		{"v1 bad protocol", []byte("PROXY UDP4 192.168.0.1 192.168.0.11 56324 53\r\n"), "", true},
+		{"v1 too long", append([]byte("PROXY TCP4 "), bytes.Repeat([]byte("1"), 120)...), "", true},
+
+		{"v2 tcp4", v2(0x21, 0x11, v2Body(ip4, ip4, 56324, 53)), "192.168.0.1:56324", false},
+		{"v2 tcp6", v2(0x21, 0x21, v2Body(ip6, ip6, 56324, 53)), "[2001:db8::1]:56324", false},
+		{"v2 local", v2(0x20, 0x00, nil), "", false},
+		{"v2 unspec", v2(0x21, 0x00, nil), "", false},
+		{"v2 bad version", v2(0x11, 0x11, v2Body(ip4, ip4, 56324, 53)), "", true},
+		{"v2 bad command", v2(0x22, 0x11, v2Body(ip4, ip4, 56324, 53)), "", true},
+		{"v2 short address", v2(0x21, 0x11, []byte{1, 2, 3, 4}), "", true},
+		{"v2 truncated", v2(0x21, 0x11, v2Body(ip4, ip4, 56324, 53))[:20], "", true},
+		{"v2 udp", v2(0x21, 0x12, v2Body(ip4, ip4, 56324, 53)), "", true},
+
+		{"no header", []byte("\x00\x1c\xab\xcd"), "", true},
+		{"empty", nil, "", true},
+	}
+
+	for _, tc := range tests {
+		addr, err := parseHeader(bufio.NewReader(bytes.NewReader(tc.in)))
+		if tc.err {
+			if err != ErrInvalidHeader {
+				t.Errorf("%s: expected ErrInvalidHeader, got %v (%v)", tc.name, err, addr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+
+		a := ""
+		if addr != nil {
+			a = addr.String()
+		}
+		if a != tc.addr {
+			t.Errorf("%s: expected address %q, got %q", tc.name, tc.addr, a)
+		}
+	}
+}
+
+// testListener is a net.Listener that accepts a given connection.
+type testListener struct {
+	net.Listener
+	conn net.Conn
+}
+
+func (l *testListener) Accept() (net.Conn, error) {
+	return l.conn, nil
+}
+
+// testConn is a pipe net.Conn with a given remote address.
+type testConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+func (c *testConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func TestListener(t *testing.T) {
+	var (
+		proxy   = &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 40000}
+		client  = &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 40000}
+		trusted = []*net.IPNet{{IP: net.IPv4(10, 0, 0, 0).To4(), Mask: net.CIDRMask(8, 32)}}
+		hdr     = "PROXY TCP4 198.51.100.7 10.0.0.2 56324 53\r\n"
+		msg     = "\x00\x0cdns message."
+	)
+
+	tests := []struct {
+		name   string
+		peer   net.Addr
+		in     string
+		remote string
+		err    error
+	}{
+		{"trusted peer with header", proxy, hdr + msg, "198.51.100.7:56324", nil},
+		{"trusted peer without header", proxy, msg, "", ErrInvalidHeader},
+		{"trusted peer with bad header", proxy, "PROXY TCP4 bad\r\n" + msg, "", ErrInvalidHeader},
+		{"untrusted peer with header", client, hdr + msg, "", ErrUntrusted},
+		{"untrusted peer with v2 header", client, string(v2(0x20, 0x00, nil)) + msg, "", ErrUntrusted},
+		{"untrusted peer without header", client, msg, client.String(), nil},
+	}
+
+	for _, tc := range tests {
+		r, w := net.Pipe()
+		go func() {
+			w.Write([]byte(tc.in))
+			w.Close()
+		}()
+
+		l := NewListener(&testListener{conn: &testConn{Conn: r, remote: tc.peer}}, trusted)
+		c, err := l.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := io.ReadAll(c)
+		if tc.err != nil {
+			if err != tc.err {
+				t.Errorf("%s: expected %v, got %v", tc.name, tc.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if string(b) != msg {
+			t.Errorf("%s: expected the message after the header, got %q", tc.name, b)
+		}
+		if a := c.RemoteAddr().String(); a != tc.remote {
+			t.Errorf("%s: expected remote address %s, got %s", tc.name, tc.remote, a)
+		}
+	}
+}
+
+func TestParseCIDRs(t *testing.T) {
+	n, err := ParseCIDRs([]string{"10.0.0.0/8", "192.0.2.1", "2001:db8::/32", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := &Listener{trusted: n}
+	for ip, ok := range map[string]bool{
+		"10.1.2.3":    true,
+		"192.0.2.1":   true,
+		"192.0.2.2":   false,
+		"2001:db8::5": true,
+		"::1":         true,
+		"::2":         false,
+		"11.0.0.1":    false,
+	} {
+		if l.isTrusted(&net.TCPAddr{IP: net.ParseIP(ip)}) != ok {
+			t.Errorf("%s: expected trusted=%v", ip, ok)
+		}
+	}
+
+	for _, c := range []string{"10.0.0.0/33", "10.0.0", "proxy"} {
+		if _, err := ParseCIDRs([]string{c}); err == nil {
+			t.Errorf("%s: expected an error", c)
+		}
+	}
+}
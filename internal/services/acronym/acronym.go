@@ -0,0 +1,56 @@
+// package acronym expands common acronyms.
+package acronym
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/knadh/dns.toys/internal/errcode"
+)
+
+//go:embed acronyms.json
+var dataB []byte
+
+// Acronym expands acronyms from an embedded list.
+type Acronym struct {
+	// Uppercase acronym => expansions, most common first.
+	data map[string][]string
+}
+
+// New returns a new instance of Acronym.
+func New() (*Acronym, error) {
+	a := &Acronym{}
+	if err := json.Unmarshal(dataB, &a.data); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// Query returns the expansions of an acronym, one per record.
+func (a *Acronym) Query(ctx context.Context, q string) ([]string, error) {
+	if q == "" {
+		return nil, errors.New("invalid acronym.")
+	}
+
+	exp, ok := a.data[strings.ToUpper(q)]
+	if !ok {
+		return nil, errcode.New(errcode.NotFound, "unknown acronym.")
+	}
+
+	out := make([]string, 0, len(exp))
+	for _, e := range exp {
+		out = append(out, fmt.Sprintf("%s 1 TXT \"%s\"", q, strings.ReplaceAll(e, "\"", "")))
+	}
+
+	return out, nil
+}
+
+// Dump is not implemented in this package.
+func (a *Acronym) Dump() ([]byte, error) {
+	return nil, nil
+}
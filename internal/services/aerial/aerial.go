@@ -0,0 +1,97 @@
+// package aerial returns the aerial (great-circle) distance between two
+// cities or coordinates.
+package aerial
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/knadh/dns.toys/internal/args"
+	"github.com/knadh/dns.toys/internal/distance"
+	"github.com/knadh/dns.toys/internal/errcode"
+	"github.com/knadh/dns.toys/internal/geo"
+)
+
+var errInvalid = errors.New("invalid query. Use from/to or from/to/unit. eg: berlin/paris, 52.52,13.40/48.85,2.35/nm")
+
+// Aerial computes distances between locations.
+type Aerial struct {
+	units distance.Units
+	geo   *geo.Geo
+}
+
+// New returns a new instance of Aerial that presents distances in the
+// given units by default.
+func New(u distance.Units, g *geo.Geo) *Aerial {
+	return &Aerial{
+		units: u,
+		geo:   g,
+	}
+}
+
+// Query returns the distance between two cities or coordinates in the
+// default units or the ones in the query.
+// Format: $from/$to or $from/$to/$unit, eg: berlin/paris, 52.52,13.40/48.85,2.35/nm.
+func (a *Aerial) Query(ctx context.Context, q string) ([]string, error) {
+	str, err := args.Split(strings.ToLower(q), 3)
+	if err != nil || len(str) < 2 {
+		return nil, errInvalid
+	}
+
+	u := a.units
+	if len(str) == 3 {
+		// An empty unit (both km and mi) isn't a valid suffix.
+		if str[2] == "" {
+			return nil, errInvalid
+		}
+
+		u, err = distance.ParseUnits(str[2])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	from, err := a.locate(str[0])
+	if err != nil {
+		return nil, err
+	}
+	to, err := a.locate(str[1])
+	if err != nil {
+		return nil, err
+	}
+
+	km := distance.Between(from.Lat, from.Lon, to.Lat, to.Lon)
+	return []string{fmt.Sprintf("%s 1 TXT \"%s - %s\" \"%s\"", q, name(from), name(to), distance.Format(km, u))}, nil
+}
+
+// Dump is not implemented in this package.
+func (a *Aerial) Dump() ([]byte, error) {
+	return nil, nil
+}
+
+// locate returns the location for coordinates, eg: 52.52,13.40, or the
+// first (most populous) location for a city.
+func (a *Aerial) locate(q string) (geo.Location, error) {
+	if geo.IsCoords(q) {
+		return geo.ParseCoords(q)
+	}
+
+	if a.geo != nil {
+		if locs := a.geo.Lookup(q); locs != nil {
+			return locs[0], nil
+		}
+	}
+
+	return geo.Location{}, errcode.Errorf(errcode.NotFound, "unknown city: %s.", q)
+}
+
+// name returns the display name of a location.
+func name(l geo.Location) string {
+	if l.Country == "" {
+		return l.Name
+	}
+
+	return fmt.Sprintf("%s (%s)", l.Name, l.Country)
+}
@@ -0,0 +1,57 @@
+package aerial
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/knadh/dns.toys/internal/distance"
+	"github.com/knadh/dns.toys/internal/errcode"
+)
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		units distance.Units
+		q     string
+		out   string
+	}{
+		{distance.Both, "52.52,13.40/48.85,2.35", " km ("},
+		{distance.KM, "52.52,13.40/48.85,2.35", " km\""},
+		{distance.Both, "52.52,13.40/48.85,2.35/nm", " nm\""},
+		{distance.NM, "52.52,13.40/48.85,2.35/mi", " mi\""},
+	}
+
+	for _, tc := range tests {
+		out, err := New(tc.units, nil).Query(context.Background(), tc.q)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.q, err)
+		}
+		if len(out) != 1 || !strings.Contains(out[0], tc.out) {
+			t.Fatalf("%s: expected %q in the response, got %v", tc.q, tc.out, out)
+		}
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	tests := []struct {
+		q    string
+		code errcode.Code
+	}{
+		{"52.52,13.40", errcode.Invalid},
+		{"52.52,13.40/48.85,2.35/ft", errcode.Invalid},
+		{"52.52,13.40/48.85,2.35/", errcode.Invalid},
+		{"52.52,13.40/95,2.35", errcode.Invalid},
+		{"52.52,13.40/atlantis", errcode.NotFound},
+	}
+
+	a := New(distance.Both, nil)
+	for _, tc := range tests {
+		_, err := a.Query(context.Background(), tc.q)
+		if err == nil {
+			t.Fatalf("%s: expected an error", tc.q)
+		}
+		if c := errcode.Of(err); c != tc.code {
+			t.Fatalf("%s: expected %s, got %s (%v)", tc.q, tc.code, c, err)
+		}
+	}
+}
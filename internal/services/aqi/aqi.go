@@ -0,0 +1,290 @@
+// package aqi returns the current air quality index for geographic locations.
+package aqi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/args"
+	"github.com/knadh/dns.toys/internal/cache"
+	"github.com/knadh/dns.toys/internal/errcode"
+	"github.com/knadh/dns.toys/internal/geo"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// Max requests/sec to the API.
+	apiRateLimit = 10
+
+	// Failed fetches are cached for this long to not flood the API with
+	// retries for the same location.
+	errTTL = time.Minute * 5
+)
+
+var errUnavailable = errcode.New(errcode.Unavailable, "air quality data is unavailable. Try again later.")
+
+// Pollutant sub-index fields in the API response and their names.
+var pollutants = []struct {
+	field string
+	name  string
+}{
+	{"us_aqi_pm2_5", "PM2.5"},
+	{"us_aqi_pm10", "PM10"},
+	{"us_aqi_ozone", "O3"},
+	{"us_aqi_nitrogen_dioxide", "NO2"},
+	{"us_aqi_sulphur_dioxide", "SO2"},
+	{"us_aqi_carbon_monoxide", "CO"},
+}
+
+// Opt contains config options for AQI.
+type Opt struct {
+	// API URL with two %f placeholders for latitude and longitude.
+	// The API should respond with Open-Meteo's air quality format.
+	APIURL string
+
+	// Max number of locations to cache. 0 for no limit.
+	CacheSize  int
+	CacheTTL   time.Duration
+	ReqTimeout time.Duration
+	UserAgent  string
+
+	// Log upstream requests, cache hits/misses, and timings.
+	Debug bool
+}
+
+type entry struct {
+	AQI       float64
+	Pollutant string
+	ExpiresAt time.Time
+
+	// False for failed fetches that are cached.
+	Valid bool
+}
+
+// Expiry returns the entry's expiry time for the cache.
+func (e entry) Expiry() time.Time {
+	return e.ExpiresAt
+}
+
+type apiData struct {
+	Current map[string]interface{} `json:"current"`
+}
+
+// AQI fetches the air quality index for a given geo location.
+type AQI struct {
+	data *cache.Cache
+
+	// In-flight fetches for locations.
+	fetches cache.Group
+	limiter *rate.Limiter
+
+	opt    Opt
+	geo    *geo.Geo
+	client *http.Client
+}
+
+// New returns a new instance of AQI.
+func New(o Opt, g *geo.Geo) *AQI {
+	return &AQI{
+		data:    cache.New(o.CacheSize),
+		limiter: rate.NewLimiter(apiRateLimit, apiRateLimit),
+		opt:     o,
+		geo:     g,
+		client: &http.Client{
+			Timeout: o.ReqTimeout,
+		},
+	}
+}
+
+// Query returns the air quality for a given location.
+func (a *AQI) Query(ctx context.Context, q string) ([]string, error) {
+	str, err := args.Split(q, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	country := ""
+
+	// Is there a /2-letter-country-code?
+	if len(str) == 2 && len(str[1]) == 2 {
+		q = str[0]
+		country = strings.ToUpper(str[1])
+	}
+	q = strings.ToLower(q)
+
+	locs := a.geo.Query(q)
+	if locs == nil {
+		return nil, errcode.New(errcode.NotFound, "unknown city.")
+	}
+
+	// Pick the first (most populous) location matching the country.
+	var loc *geo.Location
+	for _, l := range locs {
+		if country == "" || l.Country == country {
+			loc = &l
+			break
+		}
+	}
+	if loc == nil {
+		return nil, errcode.New(errcode.NotFound, "unknown city.")
+	}
+
+	e, err := a.get(ctx, *loc)
+	if err != nil {
+		return nil, err
+	}
+
+	out := []string{
+		fmt.Sprintf("%s 1 TXT \"%s (%s)\" \"aqi %0.0f\"", q, loc.Name, loc.Country, e.AQI),
+		fmt.Sprintf("%s 1 TXT \"dominant pollutant %s\"", q, e.Pollutant),
+		fmt.Sprintf("%s 1 TXT \"%s\"", q, category(e.AQI)),
+	}
+
+	return out, nil
+}
+
+// Dump is not implemented in this package.
+func (a *AQI) Dump() ([]byte, error) {
+	return nil, nil
+}
+
+// get returns the cached AQI for a location or fetches it from the API.
+// Concurrent misses for a location make a single fetch, and failed fetches
+// are cached for a while so that they aren't retried on every query.
+func (a *AQI) get(ctx context.Context, l geo.Location) (entry, error) {
+	e, ok := a.cached(l.ID)
+	if ok && e.ExpiresAt.After(time.Now()) {
+		a.debug("cache hit: %s (%s)", l.Name, l.ID)
+		if !e.Valid {
+			return entry{}, errUnavailable
+		}
+		return e, nil
+	}
+	a.debug("cache miss: %s (%s)", l.Name, l.ID)
+
+	v, err := a.fetches.Do(l.ID, func() (cache.Entry, error) {
+		if !a.limiter.Allow() {
+			log.Println("aqi API rate limit exceeded")
+			return entry{}, errUnavailable
+		}
+
+		e, err := a.fetchAPI(ctx, l.Lat, l.Lon)
+		if err != nil {
+			// Don't cache the failure if the query gave up.
+			if ctx.Err() != nil {
+				return entry{}, ctx.Err()
+			}
+
+			log.Printf("error fetching aqi API: %v", err)
+			e = entry{ExpiresAt: time.Now().Add(errTTL)}
+		}
+
+		a.data.Set(l.ID, e)
+		return e, nil
+	})
+	if err != nil {
+		return entry{}, err
+	}
+
+	if e := v.(entry); e.Valid {
+		return e, nil
+	}
+	return entry{}, errUnavailable
+}
+
+// cached returns the cached entry for a key, expired or not.
+func (a *AQI) cached(id string) (entry, bool) {
+	e, ok := a.data.Get(id)
+	if !ok {
+		return entry{}, false
+	}
+
+	return e.(entry), true
+}
+
+func (a *AQI) fetchAPI(ctx context.Context, lat, lon float64) (entry, error) {
+	u := fmt.Sprintf(a.opt.APIURL, lat, lon)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return entry{}, err
+	}
+	req.Header.Add("User-Agent", a.opt.UserAgent)
+
+	start := time.Now()
+	r, err := a.client.Do(req)
+	if err != nil {
+		return entry{}, err
+	}
+	defer r.Body.Close()
+	a.debug("fetched %s: %d in %v", u, r.StatusCode, time.Since(start))
+
+	if r.StatusCode != http.StatusOK {
+		return entry{}, fmt.Errorf("request failed: %v", r.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return entry{}, err
+	}
+
+	var data apiData
+	if err := json.Unmarshal(body, &data); err != nil {
+		return entry{}, err
+	}
+
+	aqi, ok := data.Current["us_aqi"].(float64)
+	if !ok {
+		return entry{}, errors.New("aqi missing in response")
+	}
+
+	// The dominant pollutant is the one with the highest sub-index.
+	var (
+		max  = -1.0
+		name = "unknown"
+	)
+	for _, p := range pollutants {
+		if v, ok := data.Current[p.field].(float64); ok && v > max {
+			max = v
+			name = p.name
+		}
+	}
+
+	return entry{
+		AQI:       aqi,
+		Pollutant: name,
+		ExpiresAt: time.Now().Add(a.opt.CacheTTL),
+		Valid:     true,
+	}, nil
+}
+
+// debug logs a message if debug logging is enabled.
+func (a *AQI) debug(format string, v ...interface{}) {
+	if a.opt.Debug {
+		log.Printf("aqi: "+format, v...)
+	}
+}
+
+// category returns the US EPA health category for an AQI value.
+func category(aqi float64) string {
+	switch {
+	case aqi <= 50:
+		return "good"
+	case aqi <= 100:
+		return "moderate"
+	case aqi <= 150:
+		return "unhealthy for sensitive groups"
+	case aqi <= 200:
+		return "unhealthy"
+	case aqi <= 300:
+		return "very unhealthy"
+	default:
+		return "hazardous"
+	}
+}
@@ -0,0 +1,103 @@
+package aqi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/errcode"
+	"github.com/knadh/dns.toys/internal/geo"
+	"golang.org/x/time/rate"
+)
+
+var loc = geo.Location{ID: "1", Name: "Delhi", Country: "IN", Lat: 28.65, Lon: 77.23}
+
+// newTest returns an AQI backed by a test API server that responds with
+// the status and counts the requests.
+func newTest(t *testing.T, status int, delay time.Duration) (*AQI, *int32) {
+	var n int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&n, 1)
+		time.Sleep(delay)
+		w.WriteHeader(status)
+		w.Write([]byte(`{"current": {"us_aqi": 160, "us_aqi_pm2_5": 160, "us_aqi_ozone": 40}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	a := New(Opt{
+		APIURL:     srv.URL + "?lat=%0.4f&lon=%0.4f",
+		CacheTTL:   time.Hour,
+		ReqTimeout: time.Second,
+	}, nil)
+
+	return a, &n
+}
+
+func TestGet(t *testing.T) {
+	a, n := newTest(t, http.StatusOK, 0)
+
+	for i := 0; i < 3; i++ {
+		e, err := a.get(context.Background(), loc)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if e.AQI != 160 || e.Pollutant != "PM2.5" {
+			t.Fatalf("unexpected entry: %+v", e)
+		}
+	}
+
+	if c := atomic.LoadInt32(n); c != 1 {
+		t.Fatalf("expected 1 API request for cached data, got %d", c)
+	}
+}
+
+func TestGetConcurrent(t *testing.T) {
+	a, n := newTest(t, http.StatusOK, time.Millisecond*100)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := a.get(context.Background(), loc); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if c := atomic.LoadInt32(n); c != 1 {
+		t.Fatalf("expected 1 API request for concurrent misses, got %d", c)
+	}
+}
+
+func TestGetFailureCached(t *testing.T) {
+	a, n := newTest(t, http.StatusInternalServerError, 0)
+
+	for i := 0; i < 3; i++ {
+		_, err := a.get(context.Background(), loc)
+		if errcode.Of(err) != errcode.Unavailable {
+			t.Fatalf("expected an unavailable error, got %v", err)
+		}
+	}
+
+	if c := atomic.LoadInt32(n); c != 1 {
+		t.Fatalf("expected the failure to be cached, got %d API requests", c)
+	}
+}
+
+func TestGetRateLimited(t *testing.T) {
+	a, n := newTest(t, http.StatusOK, 0)
+	a.limiter = rate.NewLimiter(0, 0)
+
+	if _, err := a.get(context.Background(), loc); errcode.Of(err) != errcode.Unavailable {
+		t.Fatalf("expected an unavailable error, got %v", err)
+	}
+	if c := atomic.LoadInt32(n); c != 0 {
+		t.Fatalf("expected no API requests when rate limited, got %d", c)
+	}
+}
@@ -0,0 +1,328 @@
+// package asn returns the organization, country, and announced prefixes
+// of an autonomous system, and the ASN that announces an IP, from RIPEstat.
+package asn
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/cache"
+	"github.com/knadh/dns.toys/internal/errcode"
+)
+
+var reASN = regexp.MustCompile(`^(?i)as([0-9]{1,10})$`)
+
+var (
+	errNotFound = errcode.New(errcode.NotFound, "unknown ASN or IP.")
+	errInvalid  = errors.New("invalid ASN or IP. eg: AS15169 or 8-8-8-8")
+)
+
+// Opt contains config options for ASN.
+type Opt struct {
+	// RIPEstat Data API compatible URL with %s placeholders for the
+	// data call (eg: as-overview) and the resource (ASN or IP).
+	APIURL string
+
+	// Max number of ASNs and IPs to cache. 0 for no limit.
+	CacheSize  int
+	CacheTTL   time.Duration
+	ReqTimeout time.Duration
+	UserAgent  string
+}
+
+type entry struct {
+	// False for ASNs and IPs that aren't known or announced.
+	Found bool
+
+	// For IPs, the ASN announcing it and the matching prefix.
+	ASN    uint32
+	Prefix string
+
+	// For ASNs.
+	Holder     string
+	Country    string
+	PrefixesV4 int
+	PrefixesV6 int
+
+	ExpiresAt time.Time
+}
+
+// Expiry returns the entry's expiry time for the cache.
+func (e entry) Expiry() time.Time {
+	return e.ExpiresAt
+}
+
+// API responses of the data calls. Only the fields that are used.
+type overviewData struct {
+	Data struct {
+		Holder string `json:"holder"`
+	} `json:"data"`
+}
+
+type routingData struct {
+	Data struct {
+		Announced struct {
+			V4 struct {
+				Prefixes int `json:"prefixes"`
+			} `json:"v4"`
+			V6 struct {
+				Prefixes int `json:"prefixes"`
+			} `json:"v6"`
+		} `json:"announced_space"`
+	} `json:"data"`
+}
+
+type countryData struct {
+	Data struct {
+		Resources []struct {
+			Location string `json:"location"`
+		} `json:"located_resources"`
+	} `json:"data"`
+}
+
+type networkData struct {
+	Data struct {
+		ASNs   []string `json:"asns"`
+		Prefix string   `json:"prefix"`
+	} `json:"data"`
+}
+
+// ASN looks up autonomous systems.
+type ASN struct {
+	data *cache.Cache
+
+	opt    Opt
+	client *http.Client
+}
+
+// New returns a new instance of ASN.
+func New(o Opt) *ASN {
+	return &ASN{
+		data: cache.New(o.CacheSize),
+		opt:  o,
+		client: &http.Client{
+			Timeout: o.ReqTimeout,
+		},
+	}
+}
+
+// Query returns the organization, country, and the number of announced
+// prefixes of an ASN, eg: AS15169. For an IP (IPv4 with dashes,
+// eg: 8-8-8-8), the ASN announcing it and the prefix are returned first.
+func (a *ASN) Query(ctx context.Context, q string) ([]string, error) {
+	if m := reASN.FindStringSubmatch(q); m != nil {
+		n, err := strconv.ParseUint(m[1], 10, 32)
+		if err != nil {
+			return nil, errInvalid
+		}
+		return a.queryASN(ctx, q, uint32(n))
+	}
+
+	s := q
+	if !strings.Contains(s, ":") {
+		s = strings.ReplaceAll(s, "-", ".")
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, errInvalid
+	}
+	if !isPublic(ip) {
+		return nil, errcode.New(errcode.NotFound, "not a public IP.")
+	}
+
+	e, err := a.get(ctx, ip.String(), a.fetchIP)
+	if err != nil {
+		return nil, a.wrapErr(err)
+	}
+
+	out := []string{fmt.Sprintf("%s 1 TXT \"%s\" \"AS%d\" \"%s\"", q, ip, e.ASN, e.Prefix)}
+	r, err := a.queryASN(ctx, q, e.ASN)
+	if err != nil {
+		// The IP's ASN is still useful without the details.
+		return out, nil
+	}
+
+	return append(out, r...), nil
+}
+
+// Dump is not implemented in this package.
+func (a *ASN) Dump() ([]byte, error) {
+	return nil, nil
+}
+
+func (a *ASN) queryASN(ctx context.Context, q string, n uint32) ([]string, error) {
+	e, err := a.get(ctx, fmt.Sprintf("AS%d", n), a.fetchASN)
+	if err != nil {
+		return nil, a.wrapErr(err)
+	}
+
+	out := []string{
+		fmt.Sprintf("%s 1 TXT \"AS%d\" \"%s\"", q, n, strings.ReplaceAll(e.Holder, "\"", "")),
+	}
+	if e.Country != "" {
+		out = append(out, fmt.Sprintf("%s 1 TXT \"country %s\"", q, e.Country))
+	}
+	out = append(out, fmt.Sprintf("%s 1 TXT \"prefixes %d IPv4\" \"%d IPv6\"", q, e.PrefixesV4, e.PrefixesV6))
+
+	return out, nil
+}
+
+// wrapErr logs and hides upstream errors.
+func (a *ASN) wrapErr(err error) error {
+	if err == errNotFound {
+		return err
+	}
+
+	log.Printf("error fetching asn: %v", err)
+	return errcode.New(errcode.Unavailable, "asn data is unavailable. Try again later.")
+}
+
+// get returns the cached entry for a resource or fetches it with fn.
+func (a *ASN) get(ctx context.Context, res string, fn func(context.Context, string) (entry, error)) (entry, error) {
+	e, ok := a.cached(res)
+	if ok && e.ExpiresAt.After(time.Now()) {
+		if !e.Found {
+			return entry{}, errNotFound
+		}
+		return e, nil
+	}
+
+	e, err := fn(ctx, res)
+	if err != nil {
+		return entry{}, err
+	}
+
+	// Cache unknown resources too so that they don't hit the API repeatedly.
+	e.ExpiresAt = time.Now().Add(a.opt.CacheTTL)
+	a.data.Set(res, e)
+
+	if !e.Found {
+		return entry{}, errNotFound
+	}
+	return e, nil
+}
+
+// cached returns the cached entry for a key, expired or not.
+func (a *ASN) cached(id string) (entry, bool) {
+	e, ok := a.data.Get(id)
+	if !ok {
+		return entry{}, false
+	}
+
+	return e.(entry), true
+}
+
+// fetchASN fetches the overview, routing status, and country of an ASN
+// concurrently.
+func (a *ASN) fetchASN(ctx context.Context, res string) (entry, error) {
+	var (
+		ov  overviewData
+		rt  routingData
+		ct  countryData
+		wg  sync.WaitGroup
+		mut sync.Mutex
+		err error
+	)
+
+	calls := map[string]interface{}{
+		"as-overview":       &ov,
+		"routing-status":    &rt,
+		"rir-stats-country": &ct,
+	}
+	for call, v := range calls {
+		wg.Add(1)
+		go func(call string, v interface{}) {
+			defer wg.Done()
+			if e := a.fetch(ctx, call, res, v); e != nil {
+				mut.Lock()
+				err = e
+				mut.Unlock()
+			}
+		}(call, v)
+	}
+	wg.Wait()
+
+	if err != nil {
+		return entry{}, err
+	}
+
+	// RIPEstat returns an empty holder for unallocated ASNs.
+	if ov.Data.Holder == "" {
+		return entry{}, nil
+	}
+
+	out := entry{
+		Found:      true,
+		Holder:     ov.Data.Holder,
+		PrefixesV4: rt.Data.Announced.V4.Prefixes,
+		PrefixesV6: rt.Data.Announced.V6.Prefixes,
+	}
+	if len(ct.Data.Resources) > 0 {
+		out.Country = ct.Data.Resources[0].Location
+	}
+
+	return out, nil
+}
+
+// fetchIP fetches the ASN announcing an IP.
+func (a *ASN) fetchIP(ctx context.Context, res string) (entry, error) {
+	var d networkData
+	if err := a.fetch(ctx, "network-info", res, &d); err != nil {
+		return entry{}, err
+	}
+
+	// Unannounced IPs have no ASNs.
+	if len(d.Data.ASNs) == 0 {
+		return entry{}, nil
+	}
+
+	n, err := strconv.ParseUint(d.Data.ASNs[0], 10, 32)
+	if err != nil {
+		return entry{}, fmt.Errorf("invalid asn in response: %s", d.Data.ASNs[0])
+	}
+
+	return entry{Found: true, ASN: uint32(n), Prefix: d.Data.Prefix}, nil
+}
+
+// fetch makes a data call to the API and decodes its response into out.
+func (a *ASN) fetch(ctx context.Context, call, res string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(a.opt.APIURL, call, res), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("User-Agent", a.opt.UserAgent)
+
+	r, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s request failed: %v", call, r.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(body, out)
+}
+
+// isPublic checks if an IP is routable on the internet.
+func isPublic(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast())
+}
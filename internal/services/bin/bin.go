@@ -0,0 +1,103 @@
+// package bin does bitwise operations on unsigned 64 bit integers.
+package bin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const num = `(0x[0-9a-f]+|0b[01]+|0o[0-7]+|[0-9]+)`
+
+var (
+	// 12and10, 0xffxor0x0f, 1shl4.
+	reOp = regexp.MustCompile(`^` + num + `(and|or|xor|shl|shr)` + num + `$`)
+
+	// not12.
+	reNot = regexp.MustCompile(`^not` + num + `$`)
+)
+
+// Bin does bitwise operations.
+type Bin struct{}
+
+// New returns a new instance of Bin.
+func New() *Bin {
+	return &Bin{}
+}
+
+// Query parses a bitwise expression and returns the result in decimal,
+// hex, and binary. Operands can be decimal or 0x, 0b, 0o prefixed.
+// Formats: 12and10, 12or10, 12xor10, not12, 1shl4, 256shr2.
+func (b *Bin) Query(ctx context.Context, q string) ([]string, error) {
+	q = strings.ToLower(q)
+
+	var res uint64
+	if m := reNot.FindStringSubmatch(q); m != nil {
+		a, err := parse(m[1])
+		if err != nil {
+			return nil, err
+		}
+		res = ^a
+	} else if m := reOp.FindStringSubmatch(q); m != nil {
+		a, err := parse(m[1])
+		if err != nil {
+			return nil, err
+		}
+		c, err := parse(m[3])
+		if err != nil {
+			return nil, err
+		}
+
+		switch m[2] {
+		case "and":
+			res = a & c
+		case "or":
+			res = a | c
+		case "xor":
+			res = a ^ c
+		case "shl", "shr":
+			if c > 63 {
+				return nil, errors.New("invalid shift. Should be between 0 and 63.")
+			}
+			if m[2] == "shl" {
+				res = a << c
+			} else {
+				res = a >> c
+			}
+		}
+	} else {
+		return nil, errors.New("invalid bin query. Try 12and10, 12or10, 12xor10, not12, 1shl4, or 256shr2.")
+	}
+
+	out := []string{
+		fmt.Sprintf("%s 1 TXT \"%d\"", q, res),
+		fmt.Sprintf("%s 1 TXT \"0x%x\"", q, res),
+		fmt.Sprintf("%s 1 TXT \"0b%b\"", q, res),
+	}
+
+	return out, nil
+}
+
+// Dump is not implemented in this package.
+func (b *Bin) Dump() ([]byte, error) {
+	return nil, nil
+}
+
+// parse parses an operand that's decimal or has a 0x, 0o, or 0b prefix.
+// Numbers with leading zeros are decimal and not octal, eg: 012 = 12.
+func parse(s string) (uint64, error) {
+	base := 10
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0o") || strings.HasPrefix(s, "0b") {
+		base = 0
+	}
+
+	n, err := strconv.ParseUint(s, base, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid operand '%s'. Should be an unsigned 64 bit integer.", s)
+	}
+
+	return n, nil
+}
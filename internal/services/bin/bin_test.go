@@ -0,0 +1,62 @@
+package bin
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		q   string
+		out string
+	}{
+		{"12and10", "8"},
+		{"12or10", "14"},
+		{"12xor10", "6"},
+		{"1shl4", "16"},
+		{"256shr2", "64"},
+		{"not0", "18446744073709551615"},
+		{"0xffand0x0f", "15"},
+		{"0XFFand0b1010", "10"},
+		{"0o17or0", "15"},
+
+		// Leading zeros are decimal.
+		{"012or0", "12"},
+		{"012and012", "12"},
+		{"not018446744073709551614", "1"},
+		{"08shl1", "16"},
+	}
+
+	b := New()
+	for _, tc := range tests {
+		out, err := b.Query(context.Background(), tc.q)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.q, err)
+		}
+		if exp := strings.ToLower(tc.q) + ` 1 TXT "` + tc.out + `"`; len(out) != 3 || out[0] != exp {
+			t.Fatalf("%s: expected %s, got %v", tc.q, exp, out)
+		}
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	tests := []struct {
+		q   string
+		err string
+	}{
+		{"1shl64", "invalid shift"},
+		{"18446744073709551616or0", "invalid operand"},
+		{"0x10000000000000000or0", "invalid operand"},
+		{"12plus10", "invalid bin query"},
+		{"0o8or0", "invalid bin query"},
+		{"", "invalid bin query"},
+	}
+
+	b := New()
+	for _, tc := range tests {
+		if _, err := b.Query(context.Background(), tc.q); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Fatalf("%s: expected %q, got %v", tc.q, tc.err, err)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// package bmi calculates the Body Mass Index.
+package bmi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	reParse  = regexp.MustCompile(`^([0-9\.]+)(kg|lbs|lb)\-([0-9a-z\.]+)$`)
+	reMetric = regexp.MustCompile(`^([0-9\.]+)(cm|m)$`)
+	reFeet   = regexp.MustCompile(`^([0-9]+)ft([0-9\.]+)?(?:in)?$`)
+	reInches = regexp.MustCompile(`^([0-9\.]+)in$`)
+)
+
+// BMI calculates the Body Mass Index.
+type BMI struct{}
+
+// New returns a new instance of BMI.
+func New() *BMI {
+	return &BMI{}
+}
+
+// Query calculates the BMI for a given weight and height.
+// Format: $weight(kg|lb)-$height(cm|m|ft|in), eg: 70kg-175cm, 154lb-5ft9.
+func (b *BMI) Query(ctx context.Context, q string) ([]string, error) {
+	q = strings.ToLower(q)
+
+	res := reParse.FindStringSubmatch(q)
+	if len(res) != 4 {
+		return nil, errors.New("invalid bmi query. eg: 70kg-175cm or 154lb-5ft9")
+	}
+
+	// Weight in kg.
+	weight, err := strconv.ParseFloat(res[1], 64)
+	if err != nil || weight <= 0 {
+		return nil, errors.New("invalid weight.")
+	}
+	if res[2] != "kg" {
+		weight *= 0.45359237
+	}
+
+	// Height in m.
+	height, err := parseHeight(res[3])
+	if err != nil {
+		return nil, err
+	}
+
+	bmi := weight / (height * height)
+	out := []string{
+		fmt.Sprintf("%s 1 TXT \"bmi = %0.1f\"", q, bmi),
+		fmt.Sprintf("%s 1 TXT \"%s\"", q, category(bmi)),
+	}
+
+	return out, nil
+}
+
+// Dump is not implemented in this package.
+func (b *BMI) Dump() ([]byte, error) {
+	return nil, nil
+}
+
+// parseHeight parses a height string into meters.
+func parseHeight(s string) (float64, error) {
+	var h float64
+
+	if m := reMetric.FindStringSubmatch(s); m != nil {
+		v, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			return 0, errors.New("invalid height.")
+		}
+
+		if m[2] == "cm" {
+			v /= 100
+		}
+		h = v
+	} else if m := reFeet.FindStringSubmatch(s); m != nil {
+		ft, _ := strconv.ParseFloat(m[1], 64)
+		in := 0.0
+		if m[2] != "" {
+			v, err := strconv.ParseFloat(m[2], 64)
+			if err != nil {
+				return 0, errors.New("invalid height.")
+			}
+			in = v
+		}
+		h = (ft*12 + in) * 0.0254
+	} else if m := reInches.FindStringSubmatch(s); m != nil {
+		v, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			return 0, errors.New("invalid height.")
+		}
+		h = v * 0.0254
+	} else {
+		return 0, errors.New("invalid height. Use cm, m, ft, or in.")
+	}
+
+	if h <= 0 {
+		return 0, errors.New("invalid height.")
+	}
+
+	return h, nil
+}
+
+// category returns the WHO BMI category.
+func category(bmi float64) string {
+	switch {
+	case bmi < 16:
+		return "underweight (severe thinness)"
+	case bmi < 17:
+		return "underweight (moderate thinness)"
+	case bmi < 18.5:
+		return "underweight (mild thinness)"
+	case bmi < 25:
+		return "normal"
+	case bmi < 30:
+		return "overweight (pre-obese)"
+	case bmi < 35:
+		return "obese (class I)"
+	case bmi < 40:
+		return "obese (class II)"
+	default:
+		return "obese (class III)"
+	}
+}
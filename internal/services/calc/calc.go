@@ -0,0 +1,240 @@
+// package calc evaluates arithmetic expressions, eg: (3+4)*2.
+package calc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/knadh/dns.toys/internal/errcode"
+)
+
+const (
+	maxLen = 64
+
+	// Max absolute exponent and size (bits) of results to keep
+	// exponentiation cheap.
+	maxExp  = 64
+	maxBits = 4096
+)
+
+var (
+	errInvalid  = errors.New("invalid expression. eg: (3+4)*2")
+	errDivZero  = errors.New("division by zero.")
+	errTooLarge = errcode.New(errcode.Limit, "number too large.")
+)
+
+// Calc evaluates arithmetic expressions.
+type Calc struct{}
+
+// New returns a new instance of Calc.
+func New() *Calc {
+	return &Calc{}
+}
+
+// Query evaluates an expression with +, -, *, /, ^ (power), and parentheses
+// with the usual precedence. The math is done on rationals so that
+// decimals don't lose precision, eg: 0.1+0.2 = 0.3.
+func (c *Calc) Query(ctx context.Context, q string) ([]string, error) {
+	if len(q) > maxLen {
+		return nil, errcode.Errorf(errcode.Limit, "expression is too long. Max %d chars.", maxLen)
+	}
+
+	p := &parser{s: q}
+	v, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.s) {
+		return nil, errInvalid
+	}
+
+	r := fmt.Sprintf("%s 1 TXT \"%s = %s\"", q, q, format(v))
+	return []string{r}, nil
+}
+
+// Dump is not implemented in this package.
+func (c *Calc) Dump() ([]byte, error) {
+	return nil, nil
+}
+
+// parser is a recursive descent parser that evaluates as it parses.
+//
+//	expr   = term {("+" | "-") term}
+//	term   = unary {("*" | "/") unary}
+//	unary  = "-" unary | power
+//	power  = atom ["^" unary]
+//	atom   = number | "(" expr ")"
+type parser struct {
+	s   string
+	pos int
+}
+
+func (p *parser) expr() (*big.Rat, error) {
+	v, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.pos < len(p.s) && (p.s[p.pos] == '+' || p.s[p.pos] == '-') {
+		op := p.s[p.pos]
+		p.pos++
+
+		r, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+
+		if op == '+' {
+			v.Add(v, r)
+		} else {
+			v.Sub(v, r)
+		}
+	}
+
+	return v, nil
+}
+
+func (p *parser) term() (*big.Rat, error) {
+	v, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.pos < len(p.s) && (p.s[p.pos] == '*' || p.s[p.pos] == '/') {
+		op := p.s[p.pos]
+		p.pos++
+
+		r, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+
+		if op == '*' {
+			v.Mul(v, r)
+		} else {
+			if r.Sign() == 0 {
+				return nil, errDivZero
+			}
+			v.Quo(v, r)
+		}
+
+		if tooLarge(v) {
+			return nil, errTooLarge
+		}
+	}
+
+	return v, nil
+}
+
+// power is right associative, eg: 2^3^2 = 2^9, and binds tighter than
+// the unary minus on its left, eg: -2^2 = -4.
+func (p *parser) power() (*big.Rat, error) {
+	v, err := p.atom()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.pos >= len(p.s) || p.s[p.pos] != '^' {
+		return v, nil
+	}
+	p.pos++
+
+	e, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	if !e.IsInt() || e.Num().CmpAbs(big.NewInt(maxExp)) > 0 {
+		return nil, errcode.Errorf(errcode.Limit, "exponent should be a whole number between -%d and %d.", maxExp, maxExp)
+	}
+
+	n := e.Num().Int64()
+	if n < 0 {
+		if v.Sign() == 0 {
+			return nil, errDivZero
+		}
+		v.Inv(v)
+		n = -n
+	}
+
+	out := new(big.Rat).SetInt64(1)
+	for i := int64(0); i < n; i++ {
+		out.Mul(out, v)
+		if tooLarge(out) {
+			return nil, errTooLarge
+		}
+	}
+
+	return out, nil
+}
+
+func (p *parser) unary() (*big.Rat, error) {
+	if p.pos < len(p.s) && p.s[p.pos] == '-' {
+		p.pos++
+		v, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return v.Neg(v), nil
+	}
+
+	return p.power()
+}
+
+func (p *parser) atom() (*big.Rat, error) {
+	if p.pos >= len(p.s) {
+		return nil, errInvalid
+	}
+
+	if p.s[p.pos] == '(' {
+		p.pos++
+		v, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if p.pos >= len(p.s) || p.s[p.pos] != ')' {
+			return nil, errors.New("unbalanced parentheses.")
+		}
+		p.pos++
+		return v, nil
+	}
+
+	// Number.
+	start := p.pos
+	for p.pos < len(p.s) && (p.s[p.pos] >= '0' && p.s[p.pos] <= '9' || p.s[p.pos] == '.') {
+		p.pos++
+	}
+	if start == p.pos {
+		return nil, errInvalid
+	}
+
+	v, ok := new(big.Rat).SetString(p.s[start:p.pos])
+	if !ok {
+		return nil, errInvalid
+	}
+
+	return v, nil
+}
+
+// tooLarge checks if a number's numerator or denominator is too large.
+func tooLarge(v *big.Rat) bool {
+	return v.Num().BitLen() > maxBits || v.Denom().BitLen() > maxBits
+}
+
+// format formats a number as an integer or as a decimal with up to
+// 10 places and no trailing zeros.
+func format(v *big.Rat) string {
+	if v.IsInt() {
+		return v.Num().String()
+	}
+
+	s := strings.TrimRight(v.FloatString(10), "0")
+	if s == "-0." || s == "0." {
+		return "0"
+	}
+
+	return strings.TrimSuffix(s, ".")
+}
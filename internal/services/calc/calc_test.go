@@ -0,0 +1,76 @@
+package calc
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/knadh/dns.toys/internal/errcode"
+)
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		q   string
+		out string
+	}{
+		{"3*4+2", "14"},
+		{"2+3*4", "14"},
+		{"(3+4)*2", "14"},
+		{"10-4-3", "3"},
+		{"8/4/2", "1"},
+		{"2^3^2", "512"},
+		{"2*3^2", "18"},
+		{"-2^2", "-4"},
+		{"(-2)^2", "4"},
+		{"2^-1", "0.5"},
+		{"-3*-2", "6"},
+		{"1-(2-(3-4))", "-2"},
+		{"0.1+0.2", "0.3"},
+		{"1/3", "0.3333333333"},
+		{"7/2", "3.5"},
+		{"0.5-0.5", "0"},
+	}
+
+	c := New()
+	for _, tc := range tests {
+		out, err := c.Query(context.Background(), tc.q)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.q, err)
+		}
+		if exp := tc.q + " 1 TXT \"" + tc.q + " = " + tc.out + "\""; len(out) != 1 || out[0] != exp {
+			t.Fatalf("%s: expected %s, got %v", tc.q, exp, out)
+		}
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	tests := []struct {
+		q    string
+		code errcode.Code
+		msg  string
+	}{
+		{"1/0", errcode.Invalid, "division by zero"},
+		{"1/(2-2)", errcode.Invalid, "division by zero"},
+		{"0^-1", errcode.Invalid, "division by zero"},
+		{"", errcode.Invalid, "invalid expression"},
+		{"1+", errcode.Invalid, "invalid expression"},
+		{"1+*2", errcode.Invalid, "invalid expression"},
+		{"1.2.3", errcode.Invalid, "invalid expression"},
+		{"2)", errcode.Invalid, "invalid expression"},
+		{"(1+2", errcode.Invalid, "unbalanced parentheses"},
+		{"2^0.5", errcode.Limit, "exponent should be a whole number"},
+		{"2^100", errcode.Limit, "exponent should be a whole number"},
+		{"9999999999^64^64", errcode.Limit, "exponent should be a whole number"},
+		{"(9999999999^64)^64", errcode.Limit, "number too large"},
+		{"9999999999^64*9999999999^64", errcode.Limit, "number too large"},
+		{strings.Repeat("1+", maxLen), errcode.Limit, "too long"},
+	}
+
+	c := New()
+	for _, tc := range tests {
+		_, err := c.Query(context.Background(), tc.q)
+		if err == nil || errcode.Of(err) != tc.code || !strings.Contains(err.Error(), tc.msg) {
+			t.Fatalf("%q: expected %s %q, got %v", tc.q, tc.code, tc.msg, err)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+// package cert returns the TLS certificate details and expiry of hosts.
+package cert
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/cache"
+	"github.com/knadh/dns.toys/internal/errcode"
+)
+
+var reHost = regexp.MustCompile(`^([a-z0-9]([a-z0-9\-]*[a-z0-9])?\.)+[a-z]{2,63}$`)
+
+// Opt contains config options for Cert.
+type Opt struct {
+	// Ports that are allowed to be queried.
+	Ports []int
+
+	// Max number of addresses to cache. 0 for no limit.
+	CacheSize   int
+	CacheTTL    time.Duration
+	DialTimeout time.Duration
+}
+
+type entry struct {
+	Subject   string
+	Issuer    string
+	NotAfter  time.Time
+	Verified  error
+	ExpiresAt time.Time
+}
+
+// Expiry returns the entry's expiry time for the cache.
+func (e entry) Expiry() time.Time {
+	return e.ExpiresAt
+}
+
+// Cert fetches TLS certificates from hosts.
+type Cert struct {
+	data *cache.Cache
+
+	opt   Opt
+	ports map[int]bool
+}
+
+// New returns a new instance of Cert.
+func New(o Opt) *Cert {
+	ports := make(map[int]bool, len(o.Ports))
+	for _, p := range o.Ports {
+		ports[p] = true
+	}
+
+	return &Cert{
+		data:  cache.New(o.CacheSize),
+		opt:   o,
+		ports: ports,
+	}
+}
+
+// Query returns the subject, issuer, and expiry of a host's certificate.
+// Format: $host-$port with the last dot of the host as a dash, or with dots.
+// eg: example-com-443, example.com-443, example-com (443).
+func (c *Cert) Query(ctx context.Context, q string) ([]string, error) {
+	host, port, err := c.parse(strings.ToLower(q))
+	if err != nil {
+		return nil, err
+	}
+
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	e, err := c.get(ctx, host, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		days   = int(time.Until(e.NotAfter).Hours() / 24)
+		status = "valid"
+	)
+	if e.Verified != nil {
+		status = "invalid: " + e.Verified.Error()
+	}
+
+	out := []string{
+		fmt.Sprintf("%s 1 TXT \"subject %s\"", q, clean(e.Subject)),
+		fmt.Sprintf("%s 1 TXT \"issuer %s\"", q, clean(e.Issuer)),
+		fmt.Sprintf("%s 1 TXT \"expires %s\" \"in %d days\"", q, e.NotAfter.UTC().Format("2006-01-02"), days),
+		fmt.Sprintf("%s 1 TXT \"%s\"", q, clean(status)),
+	}
+
+	return out, nil
+}
+
+// Dump is not implemented in this package.
+func (c *Cert) Dump() ([]byte, error) {
+	return nil, nil
+}
+
+// parse parses a query into a host and port.
+func (c *Cert) parse(q string) (string, int, error) {
+	port := 443
+
+	// Is there a -port at the end?
+	if i := strings.LastIndex(q, "-"); i > 0 {
+		if p, err := strconv.Atoi(q[i+1:]); err == nil {
+			port = p
+			q = q[:i]
+		}
+	}
+
+	if !c.ports[port] {
+		return "", 0, fmt.Errorf("port %d is not allowed.", port)
+	}
+
+	// Without dots, the last dash is the dot before the TLD.
+	if !strings.Contains(q, ".") {
+		i := strings.LastIndex(q, "-")
+		if i < 0 {
+			return "", 0, errors.New("invalid host. eg: example-com-443")
+		}
+		q = q[:i] + "." + q[i+1:]
+	}
+
+	if !reHost.MatchString(q) {
+		return "", 0, errors.New("invalid host. eg: example-com-443")
+	}
+
+	return q, port, nil
+}
+
+// get returns the cached certificate for an address or fetches it.
+func (c *Cert) get(ctx context.Context, host, addr string) (entry, error) {
+	e, ok := c.cached(addr)
+	if ok && e.ExpiresAt.After(time.Now()) {
+		return e, nil
+	}
+
+	e, err := c.fetch(ctx, host, addr)
+	if err != nil {
+		return entry{}, err
+	}
+
+	c.data.Set(addr, e)
+
+	return e, nil
+}
+
+// cached returns the cached entry for a key, expired or not.
+func (c *Cert) cached(id string) (entry, bool) {
+	e, ok := c.data.Get(id)
+	if !ok {
+		return entry{}, false
+	}
+
+	return e.(entry), true
+}
+
+// fetch connects to a host and reads its certificate chain.
+func (c *Cert) fetch(ctx context.Context, host, addr string) (entry, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.opt.DialTimeout)
+	defer cancel()
+
+	// Don't let the service be used to probe private networks.
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil || len(ips) == 0 {
+		return entry{}, errcode.New(errcode.NotFound, "unknown host.")
+	}
+	for _, ip := range ips {
+		if !isPublic(ip.IP) {
+			return entry{}, errors.New("host is not a public address.")
+		}
+	}
+
+	d := &net.Dialer{}
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(ips[0].IP.String(), addr[strings.LastIndex(addr, ":")+1:]))
+	if err != nil {
+		return entry{}, errcode.New(errcode.Unavailable, "host is unreachable.")
+	}
+	defer conn.Close()
+
+	// Verification is skipped during the handshake so that expired or
+	// otherwise invalid certificates can still be read. The chain is
+	// verified separately below to report its validity.
+	tc := tls.Client(conn, &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: true,
+	})
+	if dl, ok := ctx.Deadline(); ok {
+		tc.SetDeadline(dl)
+	}
+	if err := tc.Handshake(); err != nil {
+		return entry{}, errcode.New(errcode.Unavailable, "TLS handshake failed.")
+	}
+
+	certs := tc.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return entry{}, errcode.New(errcode.NotFound, "no certificate returned.")
+	}
+
+	inter := x509.NewCertPool()
+	for _, ct := range certs[1:] {
+		inter.AddCert(ct)
+	}
+	_, verr := certs[0].Verify(x509.VerifyOptions{
+		DNSName:       host,
+		Intermediates: inter,
+	})
+	if verr != nil {
+		verr = reason(verr)
+	}
+
+	return entry{
+		Subject:   certs[0].Subject.CommonName,
+		Issuer:    certs[0].Issuer.CommonName,
+		NotAfter:  certs[0].NotAfter,
+		Verified:  verr,
+		ExpiresAt: time.Now().Add(c.opt.CacheTTL),
+	}, nil
+}
+
+// reason returns a short reason for a certificate verification error.
+func reason(err error) error {
+	switch e := err.(type) {
+	case x509.CertificateInvalidError:
+		if e.Reason == x509.Expired {
+			return errors.New("expired or not yet valid")
+		}
+		return errors.New("invalid certificate")
+	case x509.HostnameError:
+		return errors.New("hostname mismatch")
+	case x509.UnknownAuthorityError:
+		return errors.New("unknown authority")
+	}
+
+	return errors.New("verification failed")
+}
+
+// isPublic checks if an IP is a public unicast address.
+func isPublic(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+
+	return true
+}
+
+// clean removes quotes that'd break the TXT record.
+func clean(s string) string {
+	return strings.ReplaceAll(s, "\"", "")
+}
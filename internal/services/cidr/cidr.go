@@ -2,12 +2,17 @@
 package cidr
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math/big"
 	"net"
+	"strings"
 )
 
+// Separator between the address and the prefix in a membership query.
+const inSep = "-in-"
+
 type CIDR struct{}
 
 // New returns a new instance of CIDR.
@@ -17,12 +22,55 @@ func New() *CIDR {
 
 // Query parses a given query string and returns the answer.
 // For the cidr package, the query is an IP Address Prefix (CIDR notation).
-func (c *CIDR) Query(q string) ([]string, error) {
-	ipAddr, network, err := net.ParseCIDR(q)
+// The network address is also returned as an A or AAAA record for
+// A/AAAA queries. The query can also check whether an address is in a
+// prefix, eg: 10.0.0.5-in-10.0.0.0/24.
+func (c *CIDR) Query(ctx context.Context, q string) ([]string, error) {
+	if strings.Contains(q, inSep) {
+		return c.contains(q)
+	}
+
+	return c.describe(q, q)
+}
+
+// contains checks if an address is within a prefix and returns the
+// answer and the prefix's range.
+func (c *CIDR) contains(q string) ([]string, error) {
+	parts := strings.SplitN(q, inSep, 2)
+
+	ip := net.ParseIP(parts[0])
+	if ip == nil {
+		return nil, errors.New("invalid ip address.")
+	}
+
+	_, network, err := net.ParseCIDR(parts[1])
+	if err != nil {
+		return nil, errors.New("invalid cidr notation.")
+	}
+
+	ans := "no"
+	if network.Contains(ip) {
+		ans = "yes"
+	}
+	r := fmt.Sprintf("%s 1 TXT \"%s\" \"%s in %s\"", q, ans, ip, network)
+
+	// Add the prefix's range.
+	out, err := c.describe(q, parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]string{r}, out...), nil
+}
+
+// describe returns the usable range of a prefix, s, as records with the name q.
+func (c *CIDR) describe(q, s string) ([]string, error) {
+	ipAddr, network, err := net.ParseCIDR(s)
 	if err != nil {
 		return nil, errors.New("invalid cidr notation.")
 	}
 	prefixLen, bits := network.Mask.Size()
+	base := network.IP.String()
 
 	switch {
 	// Handle ipv4.
@@ -53,7 +101,7 @@ func (c *CIDR) Query(q string) ([]string, error) {
 		size := 1 << (uint64(bits) - uint64(prefixLen))
 
 		r := fmt.Sprintf("%s 1 TXT \"%s\" \"%s\" \"%d\"", q, first, last, size)
-		return []string{r}, nil
+		return []string{r, fmt.Sprintf("%s 1 A %s", q, base)}, nil
 
 	// Handle ipv6.
 	case ipAddr.To16() != nil:
@@ -72,7 +120,7 @@ func (c *CIDR) Query(q string) ([]string, error) {
 		size = size.Lsh(size, uint(bits-prefixLen))
 
 		r := fmt.Sprintf("%s 1 TXT \"%s\" \"%s\" \"%d\"", q, first, last, size)
-		return []string{r}, nil
+		return []string{r, fmt.Sprintf("%s 1 AAAA %s", q, base)}, nil
 
 	default:
 		return nil, errors.New("unable to parse ip.")
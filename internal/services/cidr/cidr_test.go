@@ -0,0 +1,62 @@
+package cidr
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		q   string
+		out []string
+	}{
+		{"10.0.0.0/24", []string{
+			`10.0.0.0/24 1 TXT "10.0.0.1" "10.0.0.254" "256"`,
+			`10.0.0.0/24 1 A 10.0.0.0`,
+		}},
+		{"10.0.0.37/26", []string{
+			`10.0.0.37/26 1 TXT "10.0.0.1" "10.0.0.62" "64"`,
+			`10.0.0.37/26 1 A 10.0.0.0`,
+		}},
+		{"192.168.1.0/31", []string{
+			`192.168.1.0/31 1 TXT "192.168.1.0" "192.168.1.1" "2"`,
+			`192.168.1.0/31 1 A 192.168.1.0`,
+		}},
+		{"2001:db8::/126", []string{
+			`2001:db8::/126 1 TXT "2001:db8::" "2001:db8::3" "4"`,
+			`2001:db8::/126 1 AAAA 2001:db8::`,
+		}},
+		{"10.0.0.5-in-10.0.0.0/24", []string{
+			`10.0.0.5-in-10.0.0.0/24 1 TXT "yes" "10.0.0.5 in 10.0.0.0/24"`,
+			`10.0.0.5-in-10.0.0.0/24 1 TXT "10.0.0.1" "10.0.0.254" "256"`,
+			`10.0.0.5-in-10.0.0.0/24 1 A 10.0.0.0`,
+		}},
+		{"10.0.1.5-in-10.0.0.0/24", []string{
+			`10.0.1.5-in-10.0.0.0/24 1 TXT "no" "10.0.1.5 in 10.0.0.0/24"`,
+			`10.0.1.5-in-10.0.0.0/24 1 TXT "10.0.0.1" "10.0.0.254" "256"`,
+			`10.0.1.5-in-10.0.0.0/24 1 A 10.0.0.0`,
+		}},
+	}
+
+	c := New()
+	for _, tc := range tests {
+		out, err := c.Query(context.Background(), tc.q)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.q, err)
+			continue
+		}
+		if !reflect.DeepEqual(out, tc.out) {
+			t.Errorf("%s: expected %q, got %q", tc.q, tc.out, out)
+		}
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	c := New()
+	for _, q := range []string{"10.0.0.0", "10.0.0.0/33", "cidr", "10.0.0-in-10.0.0.0/24", "10.0.0.5-in-10.0.0.0"} {
+		if _, err := c.Query(context.Background(), q); err == nil {
+			t.Errorf("%s: expected an error", q)
+		}
+	}
+}
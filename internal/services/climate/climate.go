@@ -0,0 +1,373 @@
+// package climate returns climate normals (typical monthly conditions)
+// for geographic locations.
+package climate
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/args"
+	"github.com/knadh/dns.toys/internal/cache"
+	"github.com/knadh/dns.toys/internal/errcode"
+	"github.com/knadh/dns.toys/internal/geo"
+	"golang.org/x/time/rate"
+)
+
+const (
+	apiURL = "https://climate-api.open-meteo.com/v1/climate?latitude=%0.4f&longitude=%0.4f" +
+		"&start_date=1991-01-01&end_date=2020-12-31&models=EC_Earth3P_HR" +
+		"&daily=temperature_2m_max,temperature_2m_min,precipitation_sum"
+
+	// Number of years in the normals period above.
+	numYears = 30
+
+	// Max requests/sec to the API. Each request is for decades of daily data.
+	apiRateLimit = 2
+)
+
+var months = map[string]time.Month{
+	"jan": time.January, "january": time.January,
+	"feb": time.February, "february": time.February,
+	"mar": time.March, "march": time.March,
+	"apr": time.April, "april": time.April,
+	"may": time.May,
+	"jun": time.June, "june": time.June,
+	"jul": time.July, "july": time.July,
+	"aug": time.August, "august": time.August,
+	"sep": time.September, "september": time.September,
+	"oct": time.October, "october": time.October,
+	"nov": time.November, "november": time.November,
+	"dec": time.December, "december": time.December,
+}
+
+var errQueued = errors.New("data is queued.")
+
+// Opt contains config options for Climate.
+type Opt struct {
+	// Max number of locations to cache. 0 for no limit.
+	CacheSize  int
+	CacheTTL   time.Duration
+	ReqTimeout time.Duration
+	UserAgent  string
+
+	// Log upstream requests, cache hits/misses, and timings.
+	Debug bool
+
+	// Splits the query into its parts. Defaults to args.Sep.
+	Args args.Splitter
+}
+
+type entry struct {
+	// Normals for each month, Jan-Dec.
+	Months    [12]normal
+	ExpiresAt time.Time
+	Valid     bool
+}
+
+// Expiry returns the entry's expiry time for the cache.
+func (e entry) Expiry() time.Time {
+	return e.ExpiresAt
+}
+
+type normal struct {
+	HighC, LowC float64
+
+	// Average total precipitation in the month.
+	PrecipMM float64
+}
+
+type apiData struct {
+	Daily struct {
+		Time    []string   `json:"time"`
+		MaxTemp []*float64 `json:"temperature_2m_max"`
+		MinTemp []*float64 `json:"temperature_2m_min"`
+		Precip  []*float64 `json:"precipitation_sum"`
+	} `json:"daily"`
+}
+
+// Climate fetches climate normals for a given geo location.
+type Climate struct {
+	data *cache.Cache
+
+	// Queue for defering API fetch requests.
+	fetchQueue chan geo.Location
+
+	limiter *rate.Limiter
+
+	// API URL with the lat/lon placeholders. apiURL, except in tests.
+	url string
+
+	opt    Opt
+	geo    *geo.Geo
+	client *http.Client
+}
+
+// New returns a new instance of Climate.
+func New(o Opt, g *geo.Geo) *Climate {
+	c := &Climate{
+		data:       cache.New(o.CacheSize),
+		fetchQueue: make(chan geo.Location, 1000),
+		limiter:    rate.NewLimiter(apiRateLimit, 1),
+		url:        apiURL,
+		opt:        o,
+		geo:        g,
+		client: &http.Client{
+			Timeout: o.ReqTimeout,
+		},
+	}
+
+	go c.runFetchQueue()
+
+	return c
+}
+
+// Query returns the climate normals for a location in a month.
+// Format: $city/$month or $city/$country/$month, eg: berlin/july.
+func (c *Climate) Query(ctx context.Context, q string) ([]string, error) {
+	str, err := c.opt.Args.Split(strings.ToLower(q), 3)
+	if err != nil || len(str) < 2 {
+		return nil, errors.New(c.opt.Args.Example("invalid climate query. Use city/month. eg: berlin/july"))
+	}
+
+	month, ok := months[str[len(str)-1]]
+	if !ok {
+		return nil, errors.New("unknown month.")
+	}
+
+	country := ""
+	if len(str) == 3 {
+		country = strings.ToUpper(str[1])
+	}
+
+	locs := c.geo.Query(str[0])
+	if locs == nil {
+		return nil, errcode.New(errcode.NotFound, "unknown city.")
+	}
+
+	// Pick the first (most populous) location matching the country.
+	var loc *geo.Location
+	for _, l := range locs {
+		if country == "" || l.Country == country {
+			loc = &l
+			break
+		}
+	}
+	if loc == nil {
+		return nil, errcode.New(errcode.NotFound, "unknown city.")
+	}
+
+	data, err := c.get(*loc)
+	if err != nil {
+		if err == errQueued {
+			r := fmt.Sprintf("%s 1 TXT \"climate data is being fetched. Try again in a few seconds.\"", q)
+			return []string{r}, nil
+		}
+
+		return nil, err
+	}
+
+	n := data.Months[month-1]
+	out := []string{
+		fmt.Sprintf("%s 1 TXT \"%s (%s)\" \"%s\"", q, loc.Name, loc.Country, month),
+		fmt.Sprintf("%s 1 TXT \"avg. high %0.1fC (%0.1fF)\"", q, n.HighC, n.HighC*1.8+32),
+		fmt.Sprintf("%s 1 TXT \"avg. low %0.1fC (%0.1fF)\"", q, n.LowC, n.LowC*1.8+32),
+		fmt.Sprintf("%s 1 TXT \"avg. precipitation %0.1fmm\"", q, n.PrecipMM),
+	}
+
+	return out, nil
+}
+
+// Dump produces a gob dump of the cached data.
+func (c *Climate) Dump() ([]byte, error) {
+	data := make(map[string]entry, c.data.Len())
+	c.data.Range(func(id string, e cache.Entry) {
+		data[id] = e.(entry)
+	})
+
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(data); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Load loads a gob dump of cached data. Entries that have
+// already expired are discarded.
+func (c *Climate) Load(b []byte) error {
+	var (
+		buf  = bytes.NewBuffer(b)
+		data map[string]entry
+	)
+	if err := gob.NewDecoder(buf).Decode(&data); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for id, e := range data {
+		if e.ExpiresAt.After(now) {
+			c.data.Set(id, e)
+		}
+	}
+
+	return nil
+}
+
+func (c *Climate) get(l geo.Location) (entry, error) {
+	data, ok := c.cached(l.ID)
+	if ok && data.ExpiresAt.After(time.Now()) {
+		c.debug("cache hit: %s (%s)", l.Name, l.ID)
+	} else {
+		c.debug("cache miss: %s (%s)", l.Name, l.ID)
+
+		select {
+		case c.fetchQueue <- l:
+		default:
+		}
+
+		// Set the expiry date to the future to not send further
+		// requests for the same location until the fetch queue is processed.
+		data.ExpiresAt = time.Now().Add(time.Minute)
+		c.data.Set(l.ID, data)
+	}
+
+	if !ok {
+		return entry{}, errQueued
+	}
+
+	if !data.Valid {
+		return entry{}, errcode.New(errcode.NotFound, "climate data is unavailable for this location.")
+	}
+
+	return data, nil
+}
+
+// debug logs a message if debug logging is enabled.
+func (c *Climate) debug(format string, v ...interface{}) {
+	if c.opt.Debug {
+		log.Printf("climate: "+format, v...)
+	}
+}
+
+func (c *Climate) runFetchQueue() {
+	for l := range c.fetchQueue {
+		// The queries don't wait for the fetches, so each fetch has its
+		// own deadline that covers the wait for the rate limiter.
+		ctx, cancel := context.WithTimeout(context.Background(), c.opt.ReqTimeout)
+		if err := c.limiter.Wait(ctx); err != nil {
+			cancel()
+			log.Printf("climate API rate limit exceeded")
+			continue
+		}
+
+		res, err := c.fetchAPI(ctx, l.Lat, l.Lon)
+		cancel()
+
+		// Even if it's an error, cache to avoid flooding the service.
+		c.data.Set(l.ID, res)
+
+		if err != nil {
+			log.Printf("error fetching climate API: %v", err)
+		}
+	}
+}
+
+// cached returns the cached entry for a key, expired or not.
+func (c *Climate) cached(id string) (entry, bool) {
+	e, ok := c.data.Get(id)
+	if !ok {
+		return entry{}, false
+	}
+
+	return e.(entry), true
+}
+
+func (c *Climate) fetchAPI(ctx context.Context, lat, lon float64) (entry, error) {
+	// If the request fails, still cache the bad result with a TTL to avoid
+	// flooding the upstream with subsequent requests.
+	bad := entry{Valid: false, ExpiresAt: time.Now().Add(time.Minute * 10)}
+
+	u := fmt.Sprintf(c.url, lat, lon)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return bad, err
+	}
+	req.Header.Add("User-Agent", c.opt.UserAgent)
+
+	start := time.Now()
+	r, err := c.client.Do(req)
+	if err != nil {
+		return bad, err
+	}
+	defer r.Body.Close()
+	c.debug("fetched %s: %d in %v", u, r.StatusCode, time.Since(start))
+
+	if r.StatusCode != http.StatusOK {
+		return bad, fmt.Errorf("request failed: %v", r.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return bad, err
+	}
+
+	var data apiData
+	if err := json.Unmarshal(body, &data); err != nil {
+		return bad, err
+	}
+
+	// Aggregate the daily data into monthly averages.
+	var (
+		d      = data.Daily
+		high   [12]float64
+		low    [12]float64
+		precip [12]float64
+		days   [12]int
+	)
+	for i, t := range d.Time {
+		if i >= len(d.MaxTemp) || i >= len(d.MinTemp) || i >= len(d.Precip) {
+			break
+		}
+		if d.MaxTemp[i] == nil || d.MinTemp[i] == nil || d.Precip[i] == nil {
+			continue
+		}
+
+		date, err := time.Parse("2006-01-02", t)
+		if err != nil {
+			continue
+		}
+
+		m := date.Month() - 1
+		high[m] += *d.MaxTemp[i]
+		low[m] += *d.MinTemp[i]
+		precip[m] += *d.Precip[i]
+		days[m]++
+	}
+
+	out := entry{
+		ExpiresAt: time.Now().Add(c.opt.CacheTTL),
+		Valid:     true,
+	}
+	for m := 0; m < 12; m++ {
+		if days[m] == 0 {
+			return bad, errors.New("no climate data for location.")
+		}
+
+		out.Months[m] = normal{
+			HighC:    high[m] / float64(days[m]),
+			LowC:     low[m] / float64(days[m]),
+			PrecipMM: precip[m] / numYears,
+		}
+	}
+
+	return out, nil
+}
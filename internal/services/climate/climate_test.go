@@ -0,0 +1,110 @@
+package climate
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/geo"
+)
+
+var loc = geo.Location{ID: "1", Name: "Berlin", Country: "DE", Lat: 52.52, Lon: 13.40}
+
+// newTest returns a Climate backed by a test API server that responds with
+// one day of data per month and counts the requests.
+func newTest(t *testing.T) (*Climate, *int32) {
+	var (
+		n                       int32
+		days, high, low, precip []string
+	)
+	for m := 1; m <= 12; m++ {
+		days = append(days, fmt.Sprintf(`"2000-%02d-01"`, m))
+		high = append(high, fmt.Sprint(m+10))
+		low = append(low, fmt.Sprint(m))
+		precip = append(precip, "30")
+	}
+	body := fmt.Sprintf(`{"daily": {"time": [%s], "temperature_2m_max": [%s], "temperature_2m_min": [%s], "precipitation_sum": [%s]}}`,
+		strings.Join(days, ","), strings.Join(high, ","), strings.Join(low, ","), strings.Join(precip, ","))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&n, 1)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+
+	c := New(Opt{CacheTTL: time.Hour, ReqTimeout: time.Second}, nil)
+	c.url = srv.URL + "?latitude=%0.4f&longitude=%0.4f"
+
+	return c, &n
+}
+
+func TestGet(t *testing.T) {
+	c, n := newTest(t)
+
+	if _, err := c.get(loc); err != errQueued {
+		t.Fatalf("expected the first query to be queued, got %v", err)
+	}
+
+	// Wait for the queue to fetch the data.
+	var (
+		e   entry
+		err error
+	)
+	for i := 0; i < 100; i++ {
+		if v, ok := c.cached(loc.ID); ok && v.Valid {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	if e, err = c.get(loc); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if j := e.Months[time.July-1]; j.HighC != 17 || j.LowC != 7 || j.PrecipMM != 1 {
+		t.Fatalf("unexpected July normals: %+v", j)
+	}
+	if c := atomic.LoadInt32(n); c != 1 {
+		t.Fatalf("expected 1 API request, got %d", c)
+	}
+}
+
+func TestFetchContext(t *testing.T) {
+	c, n := newTest(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := c.fetchAPI(ctx, loc.Lat, loc.Lon); err == nil {
+		t.Fatal("expected an error for a cancelled context")
+	}
+	if c := atomic.LoadInt32(n); c != 0 {
+		t.Fatalf("expected no API requests for a cancelled context, got %d", c)
+	}
+}
+
+func TestLoadExpired(t *testing.T) {
+	c := New(Opt{}, nil)
+	c.data.Set("fresh", entry{Valid: true, ExpiresAt: time.Now().Add(time.Hour)})
+	c.data.Set("expired", entry{Valid: true, ExpiresAt: time.Now().Add(-time.Hour)})
+
+	b, err := c.Dump()
+	if err != nil {
+		t.Fatalf("error dumping: %v", err)
+	}
+
+	l := New(Opt{}, nil)
+	if err := l.Load(b); err != nil {
+		t.Fatalf("error loading: %v", err)
+	}
+	if _, ok := l.cached("fresh"); !ok {
+		t.Fatal("unexpired entry wasn't loaded")
+	}
+	if _, ok := l.cached("expired"); ok {
+		t.Fatal("expired entry was loaded")
+	}
+}
@@ -0,0 +1,98 @@
+// package convert routes conversion queries, eg: 100km-mi, 100usd-eur, to
+// the converter (units, fx, temp) that knows the symbols.
+package convert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/knadh/dns.toys/internal/errcode"
+)
+
+var reParse = regexp.MustCompile(`^([0-9\.]+)([a-zA-Z]{1,6})\-([a-zA-Z]{1,6})$`)
+
+// Converter is a service that converts between symbols, eg: units, currencies.
+type Converter interface {
+	Query(ctx context.Context, q string) ([]string, error)
+
+	// Knows checks if a symbol, eg: km, USD, can be converted.
+	Knows(sym string) bool
+}
+
+// Route is a converter and the format of its queries.
+type Route struct {
+	// Query suffix of the converter, eg: fx.
+	Name string
+	Conv Converter
+
+	// Separator between the from and to symbols in the converter's queries.
+	Sep string
+}
+
+// Convert dispatches conversions.
+type Convert struct {
+	routes []Route
+}
+
+// New returns a new instance of Convert.
+func New(routes []Route) *Convert {
+	return &Convert{
+		routes: routes,
+	}
+}
+
+// Query converts an amount between two symbols with the converter that
+// knows both. Format: $amount$from-$to, eg: 100km-mi, 100usd-eur.
+func (c *Convert) Query(ctx context.Context, q string) ([]string, error) {
+	res := reParse.FindStringSubmatch(q)
+	if res == nil {
+		return nil, errors.New("invalid conversion. eg: 100km-mi or 100usd-eur")
+	}
+
+	var (
+		amount, from, to = res[1], res[2], res[3]
+
+		match            []Route
+		knowFrom, knowTo string
+	)
+	for _, r := range c.routes {
+		f, t := r.Conv.Knows(from), r.Conv.Knows(to)
+		if f && t {
+			match = append(match, r)
+		}
+		if f {
+			knowFrom = r.Name
+		}
+		if t {
+			knowTo = r.Name
+		}
+	}
+
+	switch len(match) {
+	case 1:
+		r := match[0]
+		return r.Conv.Query(ctx, amount+from+r.Sep+to)
+	case 0:
+		// eg: usd-km.
+		if knowFrom != "" && knowTo != "" {
+			return nil, fmt.Errorf("cannot convert between %s (%s) and %s (%s).", from, knowFrom, to, knowTo)
+		}
+		return nil, errcode.Errorf(errcode.NotFound, "unknown units or currencies: %s, %s.", from, to)
+	}
+
+	// The symbols are genuinely ambiguous, eg: cup (unit) and CUP (Cuban peso).
+	hints := make([]string, 0, len(match))
+	for _, r := range match {
+		hints = append(hints, fmt.Sprintf("%s%s%s%s.%s", amount, from, r.Sep, to, r.Name))
+	}
+
+	return []string{fmt.Sprintf("%s 1 TXT \"ambiguous conversion. Use %s\"", q, strings.Join(hints, " or "))}, nil
+}
+
+// Dump is not implemented in this package.
+func (c *Convert) Dump() ([]byte, error) {
+	return nil, nil
+}
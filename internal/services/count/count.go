@@ -0,0 +1,99 @@
+// package count returns character, word, and syllable counts for text.
+package count
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/knadh/dns.toys/internal/errcode"
+)
+
+const maxLen = 255
+
+// Count returns text statistics.
+type Count struct{}
+
+// New returns a new instance of Count.
+func New() *Count {
+	return &Count{}
+}
+
+// Query returns the character, word, and approximate syllable counts of
+// the given text. As DNS labels can't have spaces, words in the query are
+// separated by underscores. eg: hello_world.count
+func (c *Count) Query(ctx context.Context, q string) ([]string, error) {
+	if len(q) > maxLen {
+		return nil, errcode.Errorf(errcode.Limit, "text is too long. Max %d chars.", maxLen)
+	}
+
+	words := strings.FieldsFunc(q, func(r rune) bool {
+		return r == '_'
+	})
+	if len(words) == 0 {
+		return nil, errors.New("invalid text.")
+	}
+
+	syl := 0
+	for _, w := range words {
+		syl += Syllables(w)
+	}
+
+	text := strings.Join(words, " ")
+	out := []string{
+		fmt.Sprintf("%s 1 TXT \"characters = %d\"", q, utf8.RuneCountInString(text)),
+		fmt.Sprintf("%s 1 TXT \"words = %d\"", q, len(words)),
+		fmt.Sprintf("%s 1 TXT \"syllables = %d\"", q, syl),
+	}
+
+	return out, nil
+}
+
+// Dump is not implemented in this package.
+func (c *Count) Dump() ([]byte, error) {
+	return nil, nil
+}
+
+// Syllables returns the approximate number of syllables in an English word
+// by counting groups of vowels.
+func Syllables(word string) int {
+	w := []rune(strings.ToLower(word))
+
+	var (
+		n       = 0
+		inVowel = false
+		letters = 0
+	)
+	for _, r := range w {
+		if !unicode.IsLetter(r) {
+			inVowel = false
+			continue
+		}
+		letters++
+
+		v := strings.ContainsRune("aeiouy", r)
+		if v && !inVowel {
+			n++
+		}
+		inVowel = v
+	}
+
+	if letters == 0 {
+		return 0
+	}
+
+	// Silent e at the end (eg: make), but not -le (eg: table).
+	l := len(w)
+	if n > 1 && w[l-1] == 'e' && !(l > 2 && w[l-2] == 'l' && !strings.ContainsRune("aeiouy", w[l-3])) {
+		n--
+	}
+
+	if n == 0 {
+		return 1
+	}
+
+	return n
+}
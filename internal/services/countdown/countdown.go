@@ -0,0 +1,179 @@
+// package countdown returns the time remaining until (or elapsed since)
+// a given instant.
+package countdown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/args"
+	"github.com/knadh/dns.toys/internal/errcode"
+)
+
+// Formats without a timezone that are interpreted in the given timezone.
+var localFormats = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// Countdown returns the time remaining until an instant.
+type Countdown struct{}
+
+// New returns a new instance of Countdown.
+func New() *Countdown {
+	return &Countdown{}
+}
+
+// Query returns the time remaining until the given instant.
+// Format: $datetime or $datetime/$timezone,
+// eg: 2025-01-01T00:00:00Z, 2025-01-01T00:00/Asia/Kolkata.
+func (c *Countdown) Query(ctx context.Context, q string) ([]string, error) {
+	// The timezone may have separators too, eg: America/Argentina/Buenos_Aires.
+	if _, err := args.Split(q, 4); err != nil {
+		return nil, err
+	}
+	str := strings.SplitN(q, args.Sep, 2)
+
+	loc := time.UTC
+	if len(str) == 2 {
+		l, err := loadLocation(str[1])
+		if err != nil {
+			return nil, errcode.New(errcode.NotFound, "unknown timezone.")
+		}
+		loc = l
+	}
+
+	target, err := parse(strings.ToUpper(str[0]), loc)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		now    = time.Now().In(target.Location())
+		suffix = "remaining"
+		from   = now
+		to     = target
+	)
+	if target.Before(now) {
+		from, to = target, now
+		suffix = "ago"
+	}
+
+	r := fmt.Sprintf("%s 1 TXT \"%s %s\" \"%s\"", q, Diff(from, to), suffix, target.Format(time.RFC1123Z))
+	return []string{r}, nil
+}
+
+// Dump is not implemented in this package.
+func (c *Countdown) Dump() ([]byte, error) {
+	return nil, nil
+}
+
+// Diff returns the calendar-aware difference between two instants
+// (from <= to) as years, months, days, hours, minutes and seconds.
+// Zero units are omitted.
+func Diff(from, to time.Time) string {
+	from = from.In(to.Location())
+
+	var (
+		y1, M1, d1 = from.Date()
+		y2, M2, d2 = to.Date()
+		h1, m1, s1 = from.Clock()
+		h2, m2, s2 = to.Clock()
+
+		years  = y2 - y1
+		months = int(M2 - M1)
+		days   = d2 - d1
+		hours  = h2 - h1
+		mins   = m2 - m1
+		secs   = s2 - s1
+	)
+
+	// Borrow from the larger units.
+	if secs < 0 {
+		secs += 60
+		mins--
+	}
+	if mins < 0 {
+		mins += 60
+		hours--
+	}
+	if hours < 0 {
+		hours += 24
+		days--
+	}
+	if days < 0 {
+		// Days in the month before the target's month.
+		days += time.Date(y2, M2, 0, 0, 0, 0, 0, time.UTC).Day()
+		months--
+	}
+	if months < 0 {
+		months += 12
+		years--
+	}
+
+	var (
+		parts = []string{}
+		units = []struct {
+			n    int
+			name string
+		}{
+			{years, "year"}, {months, "month"}, {days, "day"},
+			{hours, "hour"}, {mins, "minute"}, {secs, "second"},
+		}
+	)
+	for _, u := range units {
+		if u.n == 0 {
+			continue
+		}
+
+		p := fmt.Sprintf("%d %s", u.n, u.name)
+		if u.n > 1 {
+			p += "s"
+		}
+		parts = append(parts, p)
+	}
+
+	if len(parts) == 0 {
+		return "0 seconds"
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// parse parses a datetime string. If the string has no timezone, it's
+// interpreted in the given location.
+func parse(s string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	for _, f := range localFormats {
+		if t, err := time.ParseInLocation(f, s, loc); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, errors.New("invalid datetime. Use yyyy-mm-ddThh:mm:ssZ or yyyy-mm-dd.")
+}
+
+// loadLocation loads an IANA timezone. As DNS queries may not preserve
+// case, the name is retried in title case, eg: asia/kolkata => Asia/Kolkata.
+func loadLocation(name string) (*time.Location, error) {
+	if l, err := time.LoadLocation(name); err == nil {
+		return l, nil
+	}
+
+	parts := strings.Split(strings.ToLower(name), "/")
+	for i, p := range parts {
+		if p == "" {
+			continue
+		}
+		parts[i] = strings.ToUpper(p[:1]) + p[1:]
+	}
+
+	return time.LoadLocation(strings.Join(parts, "/"))
+}
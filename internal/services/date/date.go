@@ -0,0 +1,140 @@
+// package date does calendar-aware date arithmetic.
+package date
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/errcode"
+	"github.com/knadh/dns.toys/internal/services/countdown"
+)
+
+const (
+	layout = "2006-01-02"
+
+	// Max amount that can be added or subtracted.
+	maxAmount = 100000
+)
+
+var (
+	// 2024-01-15+90d, 2024-01-31-1m.
+	reAdd = regexp.MustCompile(`^([0-9]{4}-[0-9]{2}-[0-9]{2}|today)([\+\-])([0-9]+)([dwmy])$`)
+
+	// 2024-01-15-2023-01-15.
+	reDiff = regexp.MustCompile(`^([0-9]{4}-[0-9]{2}-[0-9]{2}|today)-([0-9]{4}-[0-9]{2}-[0-9]{2}|today)$`)
+)
+
+// Date does date arithmetic.
+type Date struct{}
+
+// New returns a new instance of Date.
+func New() *Date {
+	return &Date{}
+}
+
+// Query adds to or subtracts from a date, or returns the difference
+// between two dates. Dates are yyyy-mm-dd or today (UTC).
+// Formats: 2024-01-15+90d (d, w, m, y), 2024-01-15-2023-01-15.
+func (d *Date) Query(ctx context.Context, q string) ([]string, error) {
+	q = strings.ToLower(q)
+
+	if m := reAdd.FindStringSubmatch(q); m != nil {
+		t, err := parse(m[1])
+		if err != nil {
+			return nil, err
+		}
+
+		n, err := strconv.Atoi(m[3])
+		if err != nil || n > maxAmount {
+			return nil, errcode.Errorf(errcode.Limit, "amount too large. Max %d.", maxAmount)
+		}
+		if m[2] == "-" {
+			n = -n
+		}
+
+		res := add(t, n, m[4])
+		if res.Year() < 1 || res.Year() > 9999 {
+			return nil, errors.New("resulting date is out of range.")
+		}
+
+		r := fmt.Sprintf("%s 1 TXT \"%s\" \"%s\"", q, res.Format(layout), res.Weekday())
+		return []string{r}, nil
+	}
+
+	if m := reDiff.FindStringSubmatch(q); m != nil {
+		a, err := parse(m[1])
+		if err != nil {
+			return nil, err
+		}
+		b, err := parse(m[2])
+		if err != nil {
+			return nil, err
+		}
+
+		days := int(math.Round(a.Sub(b).Hours() / 24))
+		from, to := b, a
+		if a.Before(b) {
+			from, to = a, b
+		}
+
+		r := fmt.Sprintf("%s 1 TXT \"%d days\" \"%s\"", q, days, countdown.Diff(from, to))
+		return []string{r}, nil
+	}
+
+	return nil, errors.New("invalid date query. Try 2024-01-15+90d, 2024-01-31-1m, or 2024-01-15-2023-01-15.")
+}
+
+// Dump is not implemented in this package.
+func (d *Date) Dump() ([]byte, error) {
+	return nil, nil
+}
+
+// add adds n days, weeks, months, or years to a date. Adding months or
+// years clamps the day to the end of the month, eg: Jan 31 + 1m = Feb 28.
+func add(t time.Time, n int, unit string) time.Time {
+	switch unit {
+	case "d":
+		return t.AddDate(0, 0, n)
+	case "w":
+		return t.AddDate(0, 0, n*7)
+	}
+
+	months := n
+	if unit == "y" {
+		months = n * 12
+	}
+
+	// First of the target month.
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, 0, 0, 0, 0, time.UTC)
+
+	// Last day of the target month.
+	last := first.AddDate(0, 1, -1).Day()
+
+	day := t.Day()
+	if day > last {
+		day = last
+	}
+
+	return time.Date(first.Year(), first.Month(), day, 0, 0, 0, 0, time.UTC)
+}
+
+// parse parses a yyyy-mm-dd date or today.
+func parse(s string) (time.Time, error) {
+	if s == "today" {
+		y, m, d := time.Now().UTC().Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC), nil
+	}
+
+	t, err := time.Parse(layout, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date '%s'. Use yyyy-mm-dd.", s)
+	}
+
+	return t, nil
+}
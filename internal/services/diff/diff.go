@@ -0,0 +1,91 @@
+// package diff compares two strings and returns their Levenshtein
+// edit distance.
+package diff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/knadh/dns.toys/internal/args"
+	"github.com/knadh/dns.toys/internal/errcode"
+)
+
+// Max length of each string in characters to bound the distance matrix.
+const maxLen = 64
+
+// Diff compares strings.
+type Diff struct{}
+
+// New returns a new instance of Diff.
+func New() *Diff {
+	return &Diff{}
+}
+
+// Query compares the two strings in the query, eg: kitten/sitting, and
+// returns whether they're equal and their edit distance.
+func (d *Diff) Query(ctx context.Context, q string) ([]string, error) {
+	str, err := args.Split(q, 2)
+	if err != nil {
+		return nil, err
+	}
+	if len(str) != 2 {
+		return nil, errors.New("invalid query. Use string/string, eg: kitten/sitting.")
+	}
+
+	a, b := []rune(str[0]), []rune(str[1])
+	if len(a) > maxLen || len(b) > maxLen {
+		return nil, errcode.Errorf(errcode.Limit, "string is too long. Max %d chars.", maxLen)
+	}
+
+	eq := "no"
+	if str[0] == str[1] {
+		eq = "yes"
+	}
+
+	return []string{
+		fmt.Sprintf("%s 1 TXT \"distance\" \"%d\"", q, Distance(a, b)),
+		fmt.Sprintf("%s 1 TXT \"equal\" \"%s\"", q, eq),
+	}, nil
+}
+
+// Dump is not implemented in this package.
+func (d *Diff) Dump() ([]byte, error) {
+	return nil, nil
+}
+
+// Distance returns the Levenshtein distance between two strings: the
+// minimum number of insertions, deletions, and substitutions of runes
+// to turn a into b.
+func Distance(a, b []rune) int {
+	// Only the previous row of the matrix is needed.
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
+
+func min(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
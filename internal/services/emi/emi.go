@@ -0,0 +1,97 @@
+// package emi calculates loan EMIs (Equated Monthly Installments).
+package emi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/knadh/dns.toys/internal/args"
+	"github.com/knadh/dns.toys/internal/numfmt"
+)
+
+const (
+	maxPrincipal = 1e15
+	maxRate      = 100
+	maxMonths    = 1200
+)
+
+// EMI calculates loan installments.
+type EMI struct {
+	opt Opt
+}
+
+// Opt contains config options for EMI.
+type Opt struct {
+	// Format for the numbers in the output.
+	NumberFormat numfmt.Format
+
+	// Splits the query into its parts. Defaults to args.Sep.
+	Args args.Splitter
+}
+
+// New returns a new instance of EMI.
+func New(o Opt) *EMI {
+	return &EMI{
+		opt: o,
+	}
+}
+
+// Query calculates the monthly installment for a loan.
+// Format: $principal/$annualRatePercent/$months, eg: 500000/8.5/60.
+func (e *EMI) Query(ctx context.Context, q string) ([]string, error) {
+	str, err := e.opt.Args.Split(q, 3)
+	if err != nil || len(str) != 3 {
+		return nil, errors.New(e.opt.Args.Example("invalid emi query. Use principal/rate%/months. eg: 500000/8.5/60"))
+	}
+
+	p, err := strconv.ParseFloat(str[0], 64)
+	if err != nil || p <= 0 || p > maxPrincipal {
+		return nil, errors.New("invalid principal. Should be a positive number.")
+	}
+
+	rate, err := strconv.ParseFloat(str[1], 64)
+	if err != nil || rate < 0 || rate > maxRate {
+		return nil, fmt.Errorf("invalid interest rate. Should be between 0 and %d.", maxRate)
+	}
+
+	n, err := strconv.Atoi(str[2])
+	if err != nil || n <= 0 || n > maxMonths {
+		return nil, fmt.Errorf("invalid months. Should be between 1 and %d.", maxMonths)
+	}
+
+	var (
+		emi   = Calc(p, rate, n)
+		total = emi * float64(n)
+		nf    = e.opt.NumberFormat
+	)
+
+	out := []string{
+		fmt.Sprintf("%s 1 TXT \"emi = %s\"", q, nf.Float(emi, 2)),
+		fmt.Sprintf("%s 1 TXT \"total interest = %s\"", q, nf.Float(total-p, 2)),
+		fmt.Sprintf("%s 1 TXT \"total payment = %s\"", q, nf.Float(total, 2)),
+	}
+
+	return out, nil
+}
+
+// Calc returns the monthly installment for a principal at an annual
+// interest rate (percentage) over n months.
+func Calc(principal, rate float64, n int) float64 {
+	if rate == 0 {
+		return principal / float64(n)
+	}
+
+	// Monthly rate.
+	r := rate / 12 / 100
+	x := math.Pow(1+r, float64(n))
+
+	return principal * r * x / (x - 1)
+}
+
+// Dump is not implemented in this package.
+func (e *EMI) Dump() ([]byte, error) {
+	return nil, nil
+}
@@ -0,0 +1,87 @@
+// package flip picks a random item from a comma separated list, eg:
+// pizza,sushi,tacos, or shuffles the whole list.
+package flip
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/knadh/dns.toys/internal/args"
+	"github.com/knadh/dns.toys/internal/errcode"
+)
+
+const (
+	// Max items in a list.
+	maxItems = 20
+
+	// Max length of an item.
+	maxItemLen = 64
+)
+
+// Flip picks random items.
+type Flip struct{}
+
+// New returns a new instance of Flip.
+func New() *Flip {
+	return &Flip{}
+}
+
+// Query returns a random item from the list in the query, eg: pizza,sushi,tacos,
+// or the whole list in a random order with the shuffle mode, eg: a,b,c/shuffle.
+func (f *Flip) Query(ctx context.Context, q string) ([]string, error) {
+	str, err := args.Split(q, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	shuffle := false
+	if len(str) == 2 {
+		if str[1] != "shuffle" {
+			return nil, errors.New("unknown mode. Use shuffle.")
+		}
+		shuffle = true
+	}
+
+	var items []string
+	for _, s := range strings.Split(str[0], ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			items = append(items, s)
+		}
+	}
+	if len(items) < 2 {
+		return nil, errors.New("give at least two comma separated items, eg: pizza,sushi.")
+	}
+	if len(items) > maxItems {
+		return nil, errcode.Errorf(errcode.Limit, "too many items. Max is %d.", maxItems)
+	}
+	for _, s := range items {
+		if len(s) > maxItemLen {
+			return nil, errcode.Errorf(errcode.Limit, "item is too long. Max is %d characters.", maxItemLen)
+		}
+	}
+
+	// Fisher-Yates shuffle. A pick is the first item of the shuffled list.
+	for i := len(items) - 1; i > 0; i-- {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return nil, errcode.New(errcode.Internal, "error generating random number.")
+		}
+		j := n.Int64()
+		items[i], items[j] = items[j], items[i]
+	}
+
+	if !shuffle {
+		return []string{fmt.Sprintf("%s 1 TXT \"%s\"", q, items[0])}, nil
+	}
+
+	return []string{fmt.Sprintf("%s 1 TXT \"%s\"", q, strings.Join(items, "\" \""))}, nil
+}
+
+// Dump is not implemented in this package.
+func (f *Flip) Dump() ([]byte, error) {
+	return nil, nil
+}
@@ -3,126 +3,267 @@ package fx
 
 import (
 	"bytes"
+	"context"
 	"encoding/gob"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math/big"
 	"net/http"
+	"net/url"
 	"regexp"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/knadh/dns.toys/internal/args"
+	"github.com/knadh/dns.toys/internal/errcode"
+	"github.com/knadh/dns.toys/internal/extra"
+	"github.com/knadh/dns.toys/internal/numfmt"
 )
 
 const apiURL = "https://api.exchangerate.host/latest"
 
-var reParse = regexp.MustCompile("([0-9\\.]+)([A-Z]{3})\\-([A-Z]{3})")
+// Separator between multiple conversions in a query and the max
+// number of conversions.
+const (
+	batchSep = "+"
+	maxBatch = 10
+)
+
+// Rates are considered stale if they haven't been refreshed for
+// these many refresh intervals.
+const staleFactor = 2
+
+// Max amount that can be converted.
+var maxAmount = big.NewRat(1e15, 1)
+
+var (
+	reFrom = regexp.MustCompile("^([0-9\\.]+)([A-Z]{3})$")
+	reTo   = regexp.MustCompile("^[A-Z]{3}$")
+)
 
 // FX represents the currency coversion (Foreign Exchange) package.
 type FX struct {
 	opt  Opt
 	data data
 	mut  sync.RWMutex
+
+	// Time at which the rates are due for a refresh.
+	// Zero for static rates from a file.
+	expiresAt time.Time
+
+	// Cancels the background refresh loop, which closes done on exiting.
+	cancel context.CancelFunc
+	done   chan struct{}
 }
 
 type data struct {
 	Base  string             `json:"base"`
 	Date  string             `json:"date"`
 	Rates map[string]float64 `json:"rates"`
+
+	// Time of the last successful refresh from the API.
+	// Zero for static rates from a file.
+	RefreshedAt time.Time `json:"-"`
 }
 
 // Opt represents the config options for the FX converter.
 type Opt struct {
+	// Rates API URL. Defaults to exchangerate.host.
+	BaseURL string `json:"base_url"`
+
+	// Optional JSON file with a static rate table in the API's format
+	// ({"base": "EUR", "date": "2022-01-01", "rates": {"USD": 1.13 ...}}).
+	// If set, the rates are loaded from it and never refreshed from the API.
+	RatesFile string `json:"rates_file"`
+
 	RefreshInterval time.Duration `json:"refresh_interval"`
+
+	// Log upstream requests and timings.
+	Debug bool `json:"debug"`
+
+	// Format for the numbers in the output.
+	NumberFormat numfmt.Format `json:"-"`
+
+	// Splits the query into the from and to parts. Defaults to -.
+	Args args.Splitter `json:"-"`
 }
 
 // New returns an instace of the FX converter.
-func New(o Opt) *FX {
+func New(o Opt) (*FX, error) {
+	if o.BaseURL == "" {
+		o.BaseURL = apiURL
+	}
+	if u, err := url.Parse(o.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid base_url '%s'. Use an http(s) URL", o.BaseURL)
+	}
+	if o.Args.Sep == "" {
+		o.Args.Sep = "-"
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
 	fx := &FX{
-		opt: o,
+		opt:    o,
+		cancel: cancel,
+		done:   make(chan struct{}),
 	}
 
-	// Periodically fetch and refresh the rates.
-	go func() {
-		for {
-			log.Println("loading fx API")
-			d, err := fx.load(apiURL)
-			if err != nil {
-				log.Printf("error loading fx rates API: %v", err)
+	// Load static rates from the file and skip the API.
+	if o.RatesFile != "" {
+		b, err := ioutil.ReadFile(o.RatesFile)
+		if err != nil {
+			return nil, err
+		}
 
-				// HTTP fetch failed. Retry again in a minute.
-				time.Sleep(time.Minute)
-				continue
-			}
+		var d data
+		if err := json.Unmarshal(b, &d); err != nil {
+			return nil, fmt.Errorf("error parsing rates file: %v", err)
+		}
+		if err := d.validate(); err != nil {
+			return nil, fmt.Errorf("invalid rates file: %v", err)
+		}
+		log.Printf("%d fx currency pairs loaded from %s", len(d.Rates), o.RatesFile)
 
-			if _, ok := d.Rates[d.Base]; !ok {
-				log.Printf("base currency %s not found in rates", d.Base)
-				time.Sleep(time.Minute * 5)
-				continue
-			}
-			log.Printf("%d fx currency pairs loaded", len(d.Rates))
+		fx.data = d
+		close(fx.done)
+		return fx, nil
+	}
 
-			fx.mut.Lock()
-			fx.data = d
-			fx.mut.Unlock()
+	// Periodically fetch and refresh the rates.
+	go fx.refresh(ctx)
 
-			time.Sleep(o.RefreshInterval)
-		}
-	}()
+	return fx, nil
+}
 
-	return fx
+// Stop stops the background refreshing of rates and waits for it to exit.
+func (fx *FX) Stop() {
+	fx.cancel()
+	<-fx.done
 }
 
 // Query handles a currency rate conversion query.
-// Format: 100USD-INR.FX
-func (fx *FX) Query(q string) ([]string, error) {
+// Format: 100USD-INR.FX. The - is the configurable Args separator.
+// Multiple conversions are separated by +, eg: 25USD-EUR+100GBP-JPY.
+func (fx *FX) Query(ctx context.Context, q string) ([]string, error) {
 	if len(fx.data.Rates) == 0 {
-		return nil, errors.New("fx data unavailable. Please try later.")
+		return nil, errcode.New(errcode.Unavailable, "fx data unavailable. Please try later.")
 	}
 
 	q = strings.ToUpper(q)
 
-	res := reParse.FindStringSubmatch(q)
-	if len(res) != 4 {
-		return nil, errors.New("invalid fx query.")
+	// Multiple conversions, eg: 25USD-EUR+100GBP-JPY.
+	items := strings.Split(q, batchSep)
+	if len(items) > maxBatch {
+		return nil, errcode.Errorf(errcode.Limit, "too many conversions. Max %d.", maxBatch)
 	}
 
-	// Parse the numeric value.
-	val, err := strconv.ParseFloat(res[1], 32)
-	if err != nil {
-		return nil, errors.New("invalid number.")
+	var out []string
+	if len(items) == 1 {
+		r, err := fx.convert(q)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, fmt.Sprintf("%s TXT %s", q, r))
+	} else {
+		// Report the errors per conversion without failing the others.
+		for _, c := range items {
+			r, err := fx.convert(c)
+			if err != nil {
+				r = fmt.Sprintf("\"%s\" \"error: %s %s\"", c, errcode.Of(err), err)
+			}
+			out = append(out, fmt.Sprintf("%s TXT %s", q, r))
+		}
+	}
+
+	// Warn if the refreshes have been failing for a while, eg: a provider outage.
+	fx.mut.RLock()
+	at := fx.data.RefreshedAt
+	fx.mut.RUnlock()
+	if !at.IsZero() && time.Since(at) > fx.opt.RefreshInterval*staleFactor {
+		out = append(out, extra.Mark(fmt.Sprintf("%s TXT \"warning: rates may be stale. Last refreshed %s\"",
+			q, at.UTC().Format(time.RFC3339))))
+	}
+
+	// Show the cache status to debug caching from the client side. The rates
+	// are always served from the cache, which is refreshed in the background.
+	if fx.opt.Debug {
+		ttl := "static"
+		fx.mut.RLock()
+		if !fx.expiresAt.IsZero() {
+			ttl = time.Until(fx.expiresAt).Round(time.Second).String()
+		}
+		fx.mut.RUnlock()
+
+		out = append(out, extra.Mark(fmt.Sprintf("%s TXT \"debug: cache hit, ttl %s\"", q, ttl)))
+	}
+
+	return out, nil
+}
+
+// convert converts a single $value$from-$to query and returns the
+// TXT record's strings.
+func (fx *FX) convert(q string) (string, error) {
+	str, err := fx.opt.Args.Split(q, 2)
+	if err != nil || len(str) != 2 || !reTo.MatchString(str[1]) {
+		return "", errors.New("invalid fx query.")
+	}
+	res := reFrom.FindStringSubmatch(str[0])
+	if res == nil {
+		return "", errors.New("invalid fx query.")
+	}
+
+	// Parse the numeric value. The math is done on rationals so that
+	// large amounts don't lose precision.
+	val, ok := new(big.Rat).SetString(res[1])
+	if !ok {
+		return "", errors.New("invalid number.")
+	}
+	if val.Cmp(maxAmount) > 0 {
+		return "", errcode.Errorf(errcode.Limit, "amount too large. Should be less than %s.", maxAmount.FloatString(0))
 	}
 
 	var (
 		from = res[2]
-		to   = res[3]
+		to   = str[1]
 	)
 
 	// Validate the currency names.
 	fx.mut.RLock()
-	fromRate, ok := fx.data.Rates[from]
+	fromRate, okFrom := fx.data.Rates[from]
+	toRate, okTo := fx.data.Rates[to]
+	date := fx.data.Date
 	fx.mut.RUnlock()
 
-	if !ok {
-		return nil, fmt.Errorf("unknown from currency '%s'.", from)
+	if !okFrom {
+		return "", errcode.Errorf(errcode.NotFound, "unknown from currency '%s'.", from)
+	}
+	if !okTo {
+		return "", errcode.Errorf(errcode.NotFound, "unknown to currency '%s'.", to)
 	}
 
-	toRate, ok := fx.data.Rates[to]
-	if !ok {
-		return nil, fmt.Errorf("unknown to currency '%s'.", to)
+	if fromRate <= 0 || toRate <= 0 {
+		return "", errors.New("invalid rate for currency.")
 	}
 
-	baseRate := fx.data.Rates[fx.data.Base]
+	// Convert. (base / from) / (base / to) * val = val * to / from.
+	conv := new(big.Rat).Mul(val, new(big.Rat).SetFloat64(toRate))
+	conv.Quo(conv, new(big.Rat).SetFloat64(fromRate))
 
-	// Convert.
-	conv := (baseRate / fromRate) / (baseRate / toRate) * val
+	nf := fx.opt.NumberFormat
+	return fmt.Sprintf("\"%s %s = %s %s\" \"%s\"",
+		nf.Decimal(val.FloatString(2)), from, nf.Decimal(conv.FloatString(2)), to, date), nil
+}
 
-	r := fmt.Sprintf("%s TXT \"%0.2f %s = %0.2f %s\" \"%s\"", q, val, from, conv, to, fx.data.Date)
+// Knows checks if a currency code is known, eg: USD.
+func (fx *FX) Knows(sym string) bool {
+	fx.mut.RLock()
+	_, ok := fx.data.Rates[strings.ToUpper(sym)]
+	fx.mut.RUnlock()
 
-	return []string{r}, nil
+	return ok
 }
 
 // Dump produces a gob dump of the cached data.
@@ -142,23 +283,94 @@ func (fx *FX) Dump() ([]byte, error) {
 func (fx *FX) Load(b []byte) error {
 	buf := bytes.NewBuffer(b)
 
-	fx.mut.RLock()
-	defer fx.mut.RUnlock()
+	fx.mut.Lock()
+	defer fx.mut.Unlock()
 
 	err := gob.NewDecoder(buf).Decode(&fx.data)
 	return err
 }
 
-func (fx *FX) load(url string) (data, error) {
+// debug logs a message if debug logging is enabled.
+func (fx *FX) debug(format string, v ...interface{}) {
+	if fx.opt.Debug {
+		log.Printf("fx: "+format, v...)
+	}
+}
+
+// validate checks that the rate table has the base currency and only
+// positive rates.
+func (d data) validate() error {
+	if _, ok := d.Rates[d.Base]; !ok {
+		return fmt.Errorf("base currency %s not found in rates", d.Base)
+	}
+
+	for c, r := range d.Rates {
+		if r <= 0 {
+			return fmt.Errorf("invalid rate %v for %s", r, c)
+		}
+	}
+
+	return nil
+}
+
+// refresh fetches the rates from the API at the refresh interval
+// until the context is cancelled.
+func (fx *FX) refresh(ctx context.Context) {
+	defer close(fx.done)
+
+	for {
+		wait := fx.opt.RefreshInterval
+
+		log.Println("loading fx API")
+		d, err := fx.load(ctx, fx.opt.BaseURL)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			log.Printf("error loading fx rates API: %v", err)
+
+			// HTTP fetch failed. Retry again in a minute.
+			wait = time.Minute
+		} else if err := d.validate(); err != nil {
+			log.Printf("invalid fx rates: %v", err)
+			wait = time.Minute * 5
+		} else {
+			log.Printf("%d fx currency pairs loaded", len(d.Rates))
+			d.RefreshedAt = time.Now()
+
+			fx.mut.Lock()
+			fx.data = d
+			fx.expiresAt = time.Now().Add(fx.opt.RefreshInterval)
+			fx.mut.Unlock()
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (fx *FX) load(ctx context.Context, u string) (data, error) {
 	client := http.Client{
 		Timeout: 6 * time.Second,
 	}
 
-	req, _ := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return data{}, err
+	}
+	start := time.Now()
 	resp, err := client.Do(req)
 	if err != nil {
 		return data{}, err
 	}
+	defer resp.Body.Close()
+	fx.debug("fetched %s: %d in %v", u, resp.StatusCode, time.Since(start))
 
 	if resp.StatusCode != http.StatusOK {
 		return data{}, fmt.Errorf("request failed: %v", resp.StatusCode)
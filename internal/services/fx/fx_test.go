@@ -0,0 +1,422 @@
+package fx
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/args"
+	"github.com/knadh/dns.toys/internal/errcode"
+	"github.com/knadh/dns.toys/internal/extra"
+)
+
+// newTest returns an FX with a static rate table.
+func newTest(o Opt) *FX {
+	if o.Args.Sep == "" {
+		o.Args = args.Splitter{Sep: "-"}
+	}
+
+	return &FX{
+		opt: o,
+		data: data{
+			Base: "EUR",
+			Date: "2022-01-01",
+			Rates: map[string]float64{
+				"EUR": 1,
+				"USD": 2,
+				"JPY": 128,
+				"GBP": 0.5,
+			},
+		},
+		done: make(chan struct{}),
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		q   string
+		out string
+	}{
+		{"100EUR-USD", `"100.00 EUR = 200.00 USD" "2022-01-01"`},
+		{"1.5USD-JPY", `"1.50 USD = 96.00 JPY" "2022-01-01"`},
+		{"0.01GBP-EUR", `"0.01 GBP = 0.02 EUR" "2022-01-01"`},
+
+		// float64 can't represent these amounts exactly and would print
+		// 999999999999999.88 and 1000000000000000.00.
+		{"999999999999999.99EUR-EUR", `"999999999999999.99 EUR = 999999999999999.99 EUR" "2022-01-01"`},
+		{"499999999999999.98USD-EUR", `"499999999999999.98 USD = 249999999999999.99 EUR" "2022-01-01"`},
+		{"999999999999999.995EUR-GBP", `"1000000000000000.00 EUR = 500000000000000.00 GBP" "2022-01-01"`},
+	}
+
+	fx := newTest(Opt{})
+	for _, tc := range tests {
+		out, err := fx.convert(tc.q)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.q, err)
+			continue
+		}
+		if out != tc.out {
+			t.Errorf("%s: expected %s, got %s", tc.q, tc.out, out)
+		}
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	tests := []struct {
+		q    string
+		code errcode.Code
+	}{
+		{"1000000000000001EUR-USD", errcode.Limit},
+		{"99999999999999999999999EUR-USD", errcode.Limit},
+		{"1.2.3EUR-USD", errcode.Invalid},
+		{"EUR-USD", errcode.Invalid},
+		{"100EUR", errcode.Invalid},
+		{"100EUR-US", errcode.Invalid},
+		{"100XXX-USD", errcode.NotFound},
+		{"100EUR-XXX", errcode.NotFound},
+	}
+
+	fx := newTest(Opt{})
+	for _, tc := range tests {
+		_, err := fx.convert(tc.q)
+		if err == nil {
+			t.Errorf("%s: expected an error", tc.q)
+			continue
+		}
+		if c := errcode.Of(err); c != tc.code {
+			t.Errorf("%s: expected %s, got %s (%v)", tc.q, tc.code, c, err)
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	out, err := newTest(Opt{}).Query(context.Background(), "100eur-usd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || !strings.HasPrefix(out[0], `100EUR-USD TXT "100.00 EUR = 200.00 USD"`) {
+		t.Fatalf("unexpected response: %v", out)
+	}
+
+	// No rates yet.
+	if _, err := (&FX{}).Query(context.Background(), "100eur-usd"); errcode.Of(err) != errcode.Unavailable {
+		t.Fatalf("expected %s, got %v", errcode.Unavailable, err)
+	}
+}
+
+func TestRatesFile(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name string
+		body string
+		err  bool
+	}{
+		{"rates.json", `{"base": "EUR", "date": "2022-02-01", "rates": {"EUR": 1, "USD": 1.25, "INR": 80}}`, false},
+		{"nobase.json", `{"base": "EUR", "date": "2022-02-01", "rates": {"USD": 1.25}}`, true},
+		{"negative.json", `{"base": "EUR", "date": "2022-02-01", "rates": {"EUR": 1, "USD": -1}}`, true},
+		{"invalid.json", `{"base": "EUR", "rates": [1, 2]}`, true},
+		{"missing.json", "", true},
+	}
+
+	for _, tc := range tests {
+		fPath := filepath.Join(dir, tc.name)
+		if tc.body != "" {
+			if err := ioutil.WriteFile(fPath, []byte(tc.body), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		fx, err := New(Opt{RatesFile: fPath})
+		if tc.err {
+			if err == nil {
+				t.Errorf("%s: expected an error", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+
+		// The refresh loop isn't started for static rates.
+		select {
+		case <-fx.done:
+		default:
+			t.Fatalf("%s: refresh loop is running", tc.name)
+		}
+
+		out, err := fx.Query(context.Background(), "100USD-INR")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		exp := `100USD-INR TXT "100.00 USD = 6400.00 INR" "2022-02-01"`
+		if len(out) != 1 || out[0] != exp {
+			t.Fatalf("%s: expected %s, got %v", tc.name, exp, out)
+		}
+		fx.Stop()
+	}
+}
+
+// newAPI returns a test rates API server that responds with the
+// bodies in order, repeating the last one.
+func newAPI(t *testing.T, bodies ...string) *httptest.Server {
+	var n int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(atomic.AddInt32(&n, 1)) - 1
+		if i >= len(bodies) {
+			i = len(bodies) - 1
+		}
+		if bodies[i] == "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(bodies[i]))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+// waitRates waits for the rates to be loaded from the API.
+func waitRates(t *testing.T, fx *FX) {
+	t.Helper()
+
+	for i := 0; i < 100; i++ {
+		if fx.Knows("EUR") {
+			return
+		}
+		time.Sleep(time.Millisecond * 20)
+	}
+	t.Fatal("rates weren't loaded")
+}
+
+func TestBaseURL(t *testing.T) {
+	srv := newAPI(t, `{"base": "EUR", "date": "2022-03-01", "rates": {"EUR": 1, "USD": 2}}`)
+
+	fx, err := New(Opt{BaseURL: srv.URL, RefreshInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fx.Stop()
+	waitRates(t, fx)
+
+	out, err := fx.Query(context.Background(), "10EUR-USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := `10EUR-USD TXT "10.00 EUR = 20.00 USD" "2022-03-01"`; len(out) != 1 || out[0] != exp {
+		t.Fatalf("expected %s, got %v", exp, out)
+	}
+}
+
+func TestInvalidBaseURL(t *testing.T) {
+	for _, u := range []string{"http://[::1", "api.example.com/latest", "ftp://example.com", "http://", "%zz"} {
+		if _, err := New(Opt{BaseURL: u, RefreshInterval: time.Hour}); err == nil || !strings.Contains(err.Error(), "invalid base_url") {
+			t.Fatalf("%s: expected a config error, got %v", u, err)
+		}
+	}
+
+	// Bad URLs don't make it to the HTTP client.
+	fx := &FX{}
+	if _, err := fx.load(context.Background(), "http://[::1"); err == nil {
+		t.Fatal("expected an error for a malformed URL")
+	}
+}
+
+func TestDumpLoad(t *testing.T) {
+	b, err := newTest(Opt{}).Dump()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fx := &FX{opt: Opt{Args: args.Splitter{Sep: "-"}}}
+	if err := fx.Load(b); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := fx.Query(context.Background(), "100EUR-USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := `100EUR-USD TXT "100.00 EUR = 200.00 USD" "2022-01-01"`; len(out) != 1 || out[0] != exp {
+		t.Fatalf("expected %s, got %v", exp, out)
+	}
+}
+
+func TestDebugCache(t *testing.T) {
+	for _, debug := range []bool{false, true} {
+		fx := newTest(Opt{Debug: debug})
+		fx.expiresAt = time.Now().Add(time.Hour)
+
+		out, err := fx.Query(context.Background(), "100EUR-USD")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ans, ext := extra.Split(out)
+		if len(ans) != 1 {
+			t.Fatalf("debug=%v: unexpected answers: %v", debug, ans)
+		}
+		if !debug {
+			if len(ext) != 0 {
+				t.Fatalf("unexpected debug records: %v", ext)
+			}
+			continue
+		}
+		if len(ext) != 1 || !strings.Contains(ext[0], "debug: cache hit, ttl 1h0m0s") {
+			t.Fatalf("expected a cache hit debug record, got %v", ext)
+		}
+	}
+}
+
+func TestStop(t *testing.T) {
+	var n int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&n, 1)
+		w.Write([]byte(`{"base": "EUR", "date": "2022-03-01", "rates": {"EUR": 1, "USD": 2}}`))
+	}))
+	defer srv.Close()
+
+	fx, err := New(Opt{BaseURL: srv.URL, RefreshInterval: time.Millisecond * 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitRates(t, fx)
+
+	stopped := make(chan struct{})
+	go func() {
+		fx.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second * 5):
+		t.Fatal("Stop() didn't return")
+	}
+
+	// The refresh loop has exited and doesn't hit the API anymore.
+	select {
+	case <-fx.done:
+	default:
+		t.Fatal("refresh loop is still running")
+	}
+
+	// A request cancelled by Stop() may still reach the server, so let
+	// it land before counting.
+	time.Sleep(time.Millisecond * 20)
+	c := atomic.LoadInt32(&n)
+	time.Sleep(time.Millisecond * 50)
+	if c2 := atomic.LoadInt32(&n); c2 != c {
+		t.Fatalf("API requested %d times after Stop()", c2-c)
+	}
+}
+
+func TestStopInFlight(t *testing.T) {
+	// The API hangs until the request is cancelled.
+	reqs := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqs <- struct{}{}
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	fx, err := New(Opt{BaseURL: srv.URL, RefreshInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-reqs
+
+	start := time.Now()
+	fx.Stop()
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("Stop() waited %v for the in-flight fetch", d)
+	}
+}
+
+func TestStaleWarning(t *testing.T) {
+	// The first refresh succeeds and the following ones fail.
+	srv := newAPI(t, `{"base": "EUR", "date": "2022-03-01", "rates": {"EUR": 1, "USD": 2}}`, "")
+
+	interval := time.Millisecond * 50
+	fx, err := New(Opt{BaseURL: srv.URL, RefreshInterval: interval})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fx.Stop()
+	waitRates(t, fx)
+
+	// Fresh rates have no warning.
+	out, err := fx.Query(context.Background(), "10EUR-USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ext := extra.Split(out); len(ext) != 0 {
+		t.Fatalf("unexpected warning: %v", ext)
+	}
+
+	// After the refresh fails, the rates go stale and are still served,
+	// but with a warning.
+	time.Sleep(interval * (staleFactor + 1))
+	out, err = fx.Query(context.Background(), "10EUR-USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ans, ext := extra.Split(out)
+	if len(ans) != 1 || !strings.Contains(ans[0], "10.00 EUR = 20.00 USD") {
+		t.Fatalf("unexpected answers: %v", ans)
+	}
+	if len(ext) != 1 || !strings.Contains(ext[0], "warning: rates may be stale. Last refreshed ") {
+		t.Fatalf("expected a stale warning, got %v", ext)
+	}
+
+	// Static rates never go stale.
+	out, err = newTest(Opt{RefreshInterval: time.Nanosecond}).Query(context.Background(), "10EUR-USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ext := extra.Split(out); len(ext) != 0 {
+		t.Fatalf("unexpected warning for static rates: %v", ext)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	fx := newTest(Opt{})
+
+	q := "25EUR-USD+100XXX-USD+1.5GBP-EUR+abc"
+	out, err := fx.Query(context.Background(), q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{
+		`25EUR-USD+100XXX-USD+1.5GBP-EUR+ABC TXT "25.00 EUR = 50.00 USD" "2022-01-01"`,
+		`25EUR-USD+100XXX-USD+1.5GBP-EUR+ABC TXT "100XXX-USD" "error: E_NOT_FOUND unknown from currency 'XXX'."`,
+		`25EUR-USD+100XXX-USD+1.5GBP-EUR+ABC TXT "1.50 GBP = 3.00 EUR" "2022-01-01"`,
+		`25EUR-USD+100XXX-USD+1.5GBP-EUR+ABC TXT "ABC" "error: E_INVALID invalid fx query."`,
+	}
+	if !reflect.DeepEqual(out, exp) {
+		t.Fatalf("expected %q, got %q", exp, out)
+	}
+
+	// Too many conversions.
+	items := make([]string, maxBatch+1)
+	for i := range items {
+		items[i] = "1EUR-USD"
+	}
+	if _, err := fx.Query(context.Background(), strings.Join(items, batchSep)); errcode.Of(err) != errcode.Limit {
+		t.Fatalf("expected %s, got %v", errcode.Limit, err)
+	}
+
+	// A single invalid conversion fails the query.
+	if _, err := fx.Query(context.Background(), "100XXX-USD"); errcode.Of(err) != errcode.NotFound {
+		t.Fatalf("expected %s, got %v", errcode.NotFound, err)
+	}
+}
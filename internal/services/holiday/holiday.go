@@ -0,0 +1,215 @@
+// package holiday returns public holidays for countries.
+package holiday
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/args"
+	"github.com/knadh/dns.toys/internal/errcode"
+)
+
+// Max holidays to return for a year.
+const maxHolidays = 30
+
+//go:embed holidays.json
+var dataB []byte
+
+var (
+	// 01-26
+	reFixed = regexp.MustCompile(`^([0-9]{2})\-([0-9]{2})$`)
+
+	// 11-thu-4 (4th Thursday of November), 05-mon--1 (last Monday of May).
+	reNth = regexp.MustCompile(`^([0-9]{2})\-([a-z]{3})\-(\-?[1-5])$`)
+
+	// easter-2 (2 days before Easter Sunday).
+	reEaster = regexp.MustCompile(`^easter([\+\-][0-9]+)$`)
+
+	weekdays = map[string]time.Weekday{
+		"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+		"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+	}
+)
+
+type holiday struct {
+	Date string `json:"date"`
+	Name string `json:"name"`
+}
+
+// Holiday returns public holidays for countries.
+type Holiday struct {
+	// Country code => holiday rules.
+	data map[string][]holiday
+}
+
+// New returns a new instance of Holiday.
+func New() (*Holiday, error) {
+	h := &Holiday{}
+	if err := json.Unmarshal(dataB, &h.data); err != nil {
+		return nil, err
+	}
+
+	// Validate the date rules.
+	for c, hols := range h.data {
+		for _, hl := range hols {
+			if _, err := resolve(hl.Date, 2000); err != nil {
+				return nil, fmt.Errorf("invalid holiday date '%s' for %s: %v", hl.Date, c, err)
+			}
+		}
+	}
+
+	return h, nil
+}
+
+// Query returns the holiday on a date or the holidays in a year for a country.
+// Format: $country/$yyyy-mm-dd or $country/$yyyy, eg: in/2024-01-26, in/2024.
+func (h *Holiday) Query(ctx context.Context, q string) ([]string, error) {
+	str, err := args.Split(strings.ToLower(q), 2)
+	if err != nil || len(str) != 2 {
+		return nil, errors.New("invalid holiday query. Use country/yyyy-mm-dd or country/yyyy.")
+	}
+
+	hols, ok := h.data[str[0]]
+	if !ok {
+		return nil, errcode.Errorf(errcode.NotFound, "unknown country code '%s'.", str[0])
+	}
+
+	// A full year.
+	if len(str[1]) == 4 {
+		year, err := strconv.Atoi(str[1])
+		if err != nil || year < 1900 || year > 2200 {
+			return nil, errors.New("invalid year.")
+		}
+
+		list := h.list(hols, year)
+		if len(list) > maxHolidays {
+			list = list[:maxHolidays]
+		}
+
+		out := make([]string, 0, len(list))
+		for _, l := range list {
+			out = append(out, fmt.Sprintf("%s 1 TXT \"%s\" \"%s\"", q, l.date.Format("2006-01-02, Mon"), l.name))
+		}
+		return out, nil
+	}
+
+	// A specific date.
+	date, err := time.Parse("2006-01-02", str[1])
+	if err != nil {
+		return nil, errors.New("invalid date. Use yyyy-mm-dd.")
+	}
+
+	for _, l := range h.list(hols, date.Year()) {
+		if l.date.Equal(date) {
+			return []string{fmt.Sprintf("%s 1 TXT \"%s\" \"%s\"", q, l.date.Format("2006-01-02, Mon"), l.name)}, nil
+		}
+	}
+
+	return []string{fmt.Sprintf("%s 1 TXT \"%s\" \"no holiday\"", q, date.Format("2006-01-02, Mon"))}, nil
+}
+
+// Dump is not implemented in this package.
+func (h *Holiday) Dump() ([]byte, error) {
+	return nil, nil
+}
+
+type dated struct {
+	date time.Time
+	name string
+}
+
+// list returns the holidays for a year sorted by date.
+func (h *Holiday) list(hols []holiday, year int) []dated {
+	out := make([]dated, 0, len(hols))
+	for _, hl := range hols {
+		d, err := resolve(hl.Date, year)
+		if err != nil {
+			continue
+		}
+		out = append(out, dated{date: d, name: hl.Name})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].date.Before(out[j].date)
+	})
+
+	return out
+}
+
+// resolve resolves a holiday date rule to a date in the given year.
+func resolve(rule string, year int) (time.Time, error) {
+	if m := reFixed.FindStringSubmatch(rule); m != nil {
+		month, _ := strconv.Atoi(m[1])
+		day, _ := strconv.Atoi(m[2])
+		if month < 1 || month > 12 || day < 1 || day > 31 {
+			return time.Time{}, errors.New("invalid date")
+		}
+
+		return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC), nil
+	}
+
+	if m := reNth.FindStringSubmatch(rule); m != nil {
+		month, _ := strconv.Atoi(m[1])
+		n, _ := strconv.Atoi(m[3])
+		wd, ok := weekdays[m[2]]
+		if !ok || month < 1 || month > 12 || n == 0 {
+			return time.Time{}, errors.New("invalid weekday rule")
+		}
+
+		return nthWeekday(year, time.Month(month), wd, n), nil
+	}
+
+	if m := reEaster.FindStringSubmatch(rule); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return easter(year).AddDate(0, 0, n), nil
+	}
+
+	return time.Time{}, errors.New("unknown rule")
+}
+
+// nthWeekday returns the nth weekday of a month. A negative n counts
+// from the end of the month, eg: -1 is the last weekday.
+func nthWeekday(year int, month time.Month, wd time.Weekday, n int) time.Time {
+	if n > 0 {
+		d := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+		d = d.AddDate(0, 0, (int(wd)-int(d.Weekday())+7)%7)
+		return d.AddDate(0, 0, (n-1)*7)
+	}
+
+	// Last day of the month.
+	d := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC)
+	d = d.AddDate(0, 0, -((int(d.Weekday()) - int(wd) + 7) % 7))
+	return d.AddDate(0, 0, (n+1)*7)
+}
+
+// easter returns the date of (Western) Easter Sunday for a year using
+// the Anonymous Gregorian algorithm.
+func easter(year int) time.Time {
+	var (
+		a = year % 19
+		b = year / 100
+		c = year % 100
+		d = b / 4
+		e = b % 4
+		f = (b + 8) / 25
+		g = (b - f + 1) / 3
+		h = (19*a + b - d - g + 15) % 30
+		i = c / 4
+		k = c % 4
+		l = (32 + 2*e + 2*i - h - k) % 7
+		m = (a + 11*h + 22*l) / 451
+	)
+
+	month := (h + l - 7*m + 114) / 31
+	day := ((h + l - 7*m + 114) % 31) + 1
+
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,102 @@
+// package ipcalc returns the subnet facts for a host address with a prefix.
+package ipcalc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+)
+
+// IPCalc calculates subnets.
+type IPCalc struct{}
+
+// New returns a new instance of IPCalc.
+func New() *IPCalc {
+	return &IPCalc{}
+}
+
+// Query returns the network, masks, broadcast, usable range, and host
+// counts of the subnet of an address, eg: 192.168.1.37/26. IPv6 has no
+// broadcast or wildcard mask and all its addresses are usable.
+func (c *IPCalc) Query(ctx context.Context, q string) ([]string, error) {
+	ip, network, err := net.ParseCIDR(q)
+	if err != nil {
+		return nil, errors.New("invalid address. Use address/prefix, eg: 192.168.1.37/26.")
+	}
+
+	var (
+		ones, bits = network.Mask.Size()
+		v4         = ip.To4() != nil
+	)
+	if v4 {
+		ip = ip.To4()
+	}
+
+	var (
+		first = network.IP
+		last  = make(net.IP, len(first))
+
+		total = new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+
+		// Position of the host in the subnet.
+		host = new(big.Int).Sub(new(big.Int).SetBytes(ip), new(big.Int).SetBytes(first))
+	)
+	for i := range first {
+		last[i] = first[i] | ^network.Mask[i]
+	}
+
+	out := []string{
+		fmt.Sprintf("%s 1 TXT \"address\" \"%s\"", q, ip),
+		fmt.Sprintf("%s 1 TXT \"network\" \"%s\"", q, network),
+		fmt.Sprintf("%s 1 TXT \"host\" \"%s\"", q, host),
+	}
+
+	if !v4 {
+		out = append(out,
+			fmt.Sprintf("%s 1 TXT \"usable\" \"%s - %s\"", q, first, last),
+			fmt.Sprintf("%s 1 TXT \"hosts\" \"%s\"", q, total))
+		return out, nil
+	}
+
+	var (
+		usable        = new(big.Int).Set(total)
+		uFirst, uLast = dup(first), dup(last)
+		broadcast     = "none"
+	)
+
+	// The network and broadcast addresses are unusable except in /31
+	// point-to-point links (RFC 3021) and /32 single hosts.
+	if ones < 31 {
+		usable.Sub(usable, big.NewInt(2))
+		uFirst[3]++
+		uLast[3]--
+		broadcast = last.String()
+	}
+
+	wildcard := make(net.IP, len(network.Mask))
+	for i, b := range network.Mask {
+		wildcard[i] = ^b
+	}
+
+	out = append(out,
+		fmt.Sprintf("%s 1 TXT \"netmask\" \"%s\"", q, net.IP(network.Mask)),
+		fmt.Sprintf("%s 1 TXT \"wildcard\" \"%s\"", q, wildcard),
+		fmt.Sprintf("%s 1 TXT \"broadcast\" \"%s\"", q, broadcast),
+		fmt.Sprintf("%s 1 TXT \"usable\" \"%s - %s\"", q, uFirst, uLast),
+		fmt.Sprintf("%s 1 TXT \"hosts\" \"%s total\" \"%s usable\"", q, total, usable))
+
+	return out, nil
+}
+
+// Dump is not implemented in this package.
+func (c *IPCalc) Dump() ([]byte, error) {
+	return nil, nil
+}
+
+func dup(ip net.IP) net.IP {
+	out := make(net.IP, len(ip))
+	copy(out, ip)
+	return out
+}
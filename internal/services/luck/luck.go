@@ -0,0 +1,56 @@
+// package luck returns random magic 8-ball answers and fortune cookie
+// messages from embedded lists.
+package luck
+
+import (
+	"context"
+	"crypto/rand"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/knadh/dns.toys/internal/errcode"
+)
+
+//go:embed luck.json
+var dataB []byte
+
+// Luck picks random messages.
+type Luck struct {
+	// Kind (8ball, fortune) => messages.
+	data map[string][]string
+}
+
+// New returns a new instance of Luck.
+func New() (*Luck, error) {
+	l := &Luck{}
+	if err := json.Unmarshal(dataB, &l.data); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Query returns a random message of the kind in the query, eg: 8ball, fortune.
+func (l *Luck) Query(ctx context.Context, q string) ([]string, error) {
+	msgs, ok := l.data[strings.ToLower(q)]
+	if !ok || len(msgs) == 0 {
+		return nil, errors.New("unknown luck. Use 8ball or fortune.")
+	}
+
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(msgs))))
+	if err != nil {
+		return nil, errcode.New(errcode.Internal, "error generating random number.")
+	}
+
+	r := fmt.Sprintf("%s 1 TXT \"%s\"", q, msgs[n.Int64()])
+	return []string{r}, nil
+}
+
+// Dump is not implemented in this package.
+func (l *Luck) Dump() ([]byte, error) {
+	return nil, nil
+}
@@ -3,6 +3,7 @@
 package num2words
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math"
@@ -35,7 +36,7 @@ func New() *Num2Words {
 }
 
 // Query converts a number to words.
-func (n *Num2Words) Query(q string) ([]string, error) {
+func (n *Num2Words) Query(ctx context.Context, q string) ([]string, error) {
 	num, err := strconv.Atoi(q)
 	if err != nil {
 		return nil, errors.New("invalid number.")
@@ -51,6 +52,11 @@ func (n *Num2Words) Dump() ([]byte, error) {
 	return nil, nil
 }
 
+// Words returns a number (< 1 trillion) in English words.
+func Words(number int) string {
+	return convert(number, false)
+}
+
 func convert(number int, useAnd bool) string {
 	// Zero rule
 	if number == 0 {
@@ -0,0 +1,92 @@
+// package palindrome checks if text is a palindrome and other properties
+// of its letters, eg: if it's an isogram.
+package palindrome
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"unicode"
+
+	"github.com/knadh/dns.toys/internal/errcode"
+)
+
+const maxLen = 128
+
+// Palindrome checks text.
+type Palindrome struct{}
+
+// New returns a new instance of Palindrome.
+func New() *Palindrome {
+	return &Palindrome{}
+}
+
+// Query checks if the text in the query reads the same forwards and
+// backwards, ignoring case, spaces, and punctuation. As DNS labels can't
+// have spaces, words are separated by underscores, eg: never_odd_or_even.
+// It also checks if the text is an isogram (no repeating letters).
+func (p *Palindrome) Query(ctx context.Context, q string) ([]string, error) {
+	if len(q) > maxLen {
+		return nil, errcode.Errorf(errcode.Limit, "text is too long. Max %d chars.", maxLen)
+	}
+
+	s := normalize(q)
+	if len(s) == 0 {
+		return nil, errors.New("invalid text.")
+	}
+
+	return []string{
+		fmt.Sprintf("%s 1 TXT \"palindrome\" \"%s\" \"%s\"", q, yesNo(isPalindrome(s)), string(s)),
+		fmt.Sprintf("%s 1 TXT \"isogram\" \"%s\"", q, yesNo(isIsogram(s))),
+	}, nil
+}
+
+// Dump is not implemented in this package.
+func (p *Palindrome) Dump() ([]byte, error) {
+	return nil, nil
+}
+
+// normalize returns the lowercase letters and numbers in a string.
+func normalize(s string) []rune {
+	out := make([]rune, 0, len(s))
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			out = append(out, unicode.ToLower(r))
+		}
+	}
+
+	return out
+}
+
+func isPalindrome(s []rune) bool {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		if s[i] != s[j] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isIsogram checks if no letter repeats. Numbers are ignored.
+func isIsogram(s []rune) bool {
+	seen := make(map[rune]bool, len(s))
+	for _, r := range s {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		if seen[r] {
+			return false
+		}
+		seen[r] = true
+	}
+
+	return true
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,51 @@
+package palindrome
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/knadh/dns.toys/internal/errcode"
+)
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		q          string
+		palindrome string
+		norm       string
+		isogram    string
+	}{
+		{"racecar", "yes", "racecar", "no"},
+		{"RaceCar", "yes", "racecar", "no"},
+		{"never_odd_or_even", "yes", "neveroddoreven", "no"},
+		{"A_man_a_plan_a_canal_Panama", "yes", "amanaplanacanalpanama", "no"},
+		{"Was_it_a_car_or_a_cat_I_saw", "yes", "wasitacaroracatisaw", "no"},
+		{"12321", "yes", "12321", "yes"},
+		{"hello_world", "no", "helloworld", "no"},
+		{"Dermatoglyphics", "no", "dermatoglyphics", "yes"},
+		{"ab-ba", "yes", "abba", "no"},
+		{"Ésé", "yes", "ésé", "no"},
+	}
+
+	p := New()
+	for _, tc := range tests {
+		out, err := p.Query(context.Background(), tc.q)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.q, err)
+		}
+
+		exp := []string{
+			tc.q + ` 1 TXT "palindrome" "` + tc.palindrome + `" "` + tc.norm + `"`,
+			tc.q + ` 1 TXT "isogram" "` + tc.isogram + `"`,
+		}
+		if strings.Join(out, "\n") != strings.Join(exp, "\n") {
+			t.Fatalf("%s: expected %v, got %v", tc.q, exp, out)
+		}
+	}
+
+	for q, code := range map[string]errcode.Code{"": errcode.Invalid, "_-_": errcode.Invalid, strings.Repeat("a", maxLen+1): errcode.Limit} {
+		if _, err := p.Query(context.Background(), q); errcode.Of(err) != code {
+			t.Fatalf("%q: expected %s, got %v", q, code, err)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// package pct does percentage calculations.
+package pct
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// 15of200 = 15% of 200.
+	reOf = regexp.MustCompile(`^([0-9\.]+)of([0-9\.]+)$`)
+
+	// 45is-of-60 = 45 is what % of 60.
+	reIsOf = regexp.MustCompile(`^([0-9\.]+)is\-?of\-?([0-9\.]+)$`)
+
+	// 30-45 = % change from 30 to 45.
+	reChange = regexp.MustCompile(`^([0-9\.]+)\-([0-9\.]+)$`)
+)
+
+// Pct does percentage calculations.
+type Pct struct{}
+
+// New returns a new instance of Pct.
+func New() *Pct {
+	return &Pct{}
+}
+
+// Query parses a percentage query and returns the result.
+// Formats:
+// 15of200 (15% of 200), 45is-of-60 (45 is what % of 60),
+// 30-45 (% change from 30 to 45).
+func (p *Pct) Query(ctx context.Context, q string) ([]string, error) {
+	q = strings.ToLower(q)
+
+	var res string
+	if m := reOf.FindStringSubmatch(q); m != nil {
+		a, b, err := parseNums(m[1], m[2])
+		if err != nil {
+			return nil, err
+		}
+
+		res = fmt.Sprintf("%s%% of %s = %s", format(a), format(b), format(a*b/100))
+	} else if m := reIsOf.FindStringSubmatch(q); m != nil {
+		a, b, err := parseNums(m[1], m[2])
+		if err != nil {
+			return nil, err
+		}
+		if b == 0 {
+			return nil, errors.New("division by zero.")
+		}
+
+		res = fmt.Sprintf("%s is %s%% of %s", format(a), format(a/b*100), format(b))
+	} else if m := reChange.FindStringSubmatch(q); m != nil {
+		a, b, err := parseNums(m[1], m[2])
+		if err != nil {
+			return nil, err
+		}
+		if a == 0 {
+			return nil, errors.New("division by zero.")
+		}
+
+		res = fmt.Sprintf("%s to %s = %s%% change", format(a), format(b), format((b-a)/a*100))
+	} else {
+		return nil, errors.New("invalid pct query. Try 15of200, 45is-of-60, or 30-45.")
+	}
+
+	r := fmt.Sprintf("%s 1 TXT \"%s\"", q, res)
+	return []string{r}, nil
+}
+
+// Dump is not implemented in this package.
+func (p *Pct) Dump() ([]byte, error) {
+	return nil, nil
+}
+
+func parseNums(a, b string) (float64, float64, error) {
+	x, err := strconv.ParseFloat(a, 64)
+	if err != nil {
+		return 0, 0, errors.New("invalid number.")
+	}
+
+	y, err := strconv.ParseFloat(b, 64)
+	if err != nil {
+		return 0, 0, errors.New("invalid number.")
+	}
+
+	return x, y, nil
+}
+
+// format formats a number to 4 decimal places at most, trimming
+// trailing zeroes.
+func format(n float64) string {
+	s := strconv.FormatFloat(n, 'f', 4, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" {
+		return "0"
+	}
+
+	return s
+}
@@ -0,0 +1,157 @@
+// package planets returns the rise and set times and the current position
+// of the moon and the planets in the sky at geographic locations.
+package planets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/args"
+	"github.com/knadh/dns.toys/internal/errcode"
+	"github.com/knadh/dns.toys/internal/geo"
+	"github.com/knadh/dns.toys/internal/planets"
+)
+
+const layout = "2006-01-02"
+
+var errInvalid = errors.New("invalid query. Use body-city/country-code/yyyy-mm-dd, eg: mars-berlin.")
+
+// Planets returns the positions of bodies for a location.
+type Planets struct {
+	geo *geo.Geo
+}
+
+// New returns a new instance of Planets.
+func New(g *geo.Geo) *Planets {
+	return &Planets{
+		geo: g,
+	}
+}
+
+// Query returns the rise and set times of a body at a city today, and its
+// current altitude and azimuth, or the rise and set times on a given date.
+// Format: $body-$city with an optional /$country and /$date,
+// eg: mars-berlin, moon-paris/fr, venus-tokyo/2024-07-04.
+func (p *Planets) Query(ctx context.Context, q string) ([]string, error) {
+	str, err := args.Split(strings.ToLower(q), 3)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := strings.SplitN(str[0], "-", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, errInvalid
+	}
+	body, city := parts[0], parts[1]
+
+	// Is there a /2-letter-country-code and/or a /date?
+	var (
+		country = ""
+		date    = ""
+	)
+	for _, s := range str[1:] {
+		if len(s) == 2 {
+			country = strings.ToUpper(s)
+		} else if len(s) == len(layout) {
+			date = s
+		} else {
+			return nil, errInvalid
+		}
+	}
+
+	if !known(body) {
+		return nil, errcode.Errorf(errcode.NotFound, "unknown body. Use %s.", strings.Join(planets.Bodies, ", "))
+	}
+
+	loc, err := p.lookup(city, country)
+	if err != nil {
+		return nil, err
+	}
+
+	zone, err := time.LoadLocation(loc.Timezone)
+	if err != nil {
+		return nil, errcode.New(errcode.NotFound, "unknown timezone for city.")
+	}
+
+	now := time.Now().In(zone)
+	day := now
+	if date != "" {
+		d, err := time.ParseInLocation(layout, date, zone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date '%s'. Use yyyy-mm-dd.", date)
+		}
+		day = d
+	}
+
+	out := []string{
+		fmt.Sprintf("%s 1 TXT \"%s\" \"%s (%s)\" \"%s\"", q, strings.Title(body), loc.Name, loc.Country, day.Format("Mon, 02 Jan 2006")),
+	}
+
+	// The current position is only for today.
+	if date == "" {
+		alt, az, err := planets.Position(body, now, loc.Lat, loc.Lon)
+		if err != nil {
+			return nil, err
+		}
+
+		state := "above the horizon"
+		if alt < 0 {
+			state = "below the horizon"
+		}
+		out = append(out, fmt.Sprintf("%s 1 TXT \"altitude %0.1fdeg\" \"azimuth %0.0fdeg %s\" \"%s\"", q, alt, az, geo.Compass(az), state))
+	}
+
+	rise, set, err := planets.RiseSet(body, day, loc.Lat, loc.Lon)
+	switch err {
+	case nil:
+		out = append(out, fmt.Sprintf("%s 1 TXT \"rise %s\" \"set %s\"", q, fmtTime(rise), fmtTime(set)))
+	case planets.ErrAlwaysAbove:
+		out = append(out, fmt.Sprintf("%s 1 TXT \"above the horizon all day\"", q))
+	case planets.ErrAlwaysBelow:
+		out = append(out, fmt.Sprintf("%s 1 TXT \"below the horizon all day\"", q))
+	default:
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// Dump is not implemented in this package.
+func (p *Planets) Dump() ([]byte, error) {
+	return nil, nil
+}
+
+// lookup returns the most populous location for a city and an optional
+// 2-letter country code.
+func (p *Planets) lookup(city, country string) (geo.Location, error) {
+	for _, l := range p.geo.Query(city) {
+		if country == "" || l.Country == country {
+			return l, nil
+		}
+	}
+
+	return geo.Location{}, errcode.New(errcode.NotFound, "unknown city.")
+}
+
+func known(body string) bool {
+	for _, b := range planets.Bodies {
+		if b == body {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fmtTime formats a rise or set time, or "none" if it doesn't happen on
+// the day, eg: the moon.
+func fmtTime(t time.Time) string {
+	if t.IsZero() {
+		return "none"
+	}
+
+	return t.Format("15:04")
+}
@@ -0,0 +1,217 @@
+// package plural returns the plural (or singular) form of English nouns.
+package plural
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/knadh/dns.toys/internal/args"
+)
+
+// Irregular nouns. singular: plural.
+var irregulars = map[string]string{
+	"child":      "children",
+	"person":     "people",
+	"man":        "men",
+	"woman":      "women",
+	"mouse":      "mice",
+	"louse":      "lice",
+	"goose":      "geese",
+	"foot":       "feet",
+	"tooth":      "teeth",
+	"ox":         "oxen",
+	"die":        "dice",
+	"cactus":     "cacti",
+	"fungus":     "fungi",
+	"nucleus":    "nuclei",
+	"radius":     "radii",
+	"stimulus":   "stimuli",
+	"syllabus":   "syllabi",
+	"alumnus":    "alumni",
+	"analysis":   "analyses",
+	"axis":       "axes",
+	"basis":      "bases",
+	"crisis":     "crises",
+	"diagnosis":  "diagnoses",
+	"thesis":     "theses",
+	"phenomenon": "phenomena",
+	"criterion":  "criteria",
+	"datum":      "data",
+	"medium":     "media",
+	"curriculum": "curricula",
+	"appendix":   "appendices",
+	"index":      "indices",
+	"matrix":     "matrices",
+	"vertex":     "vertices",
+	"quiz":       "quizzes",
+	"potato":     "potatoes",
+	"tomato":     "tomatoes",
+	"hero":       "heroes",
+	"echo":       "echoes",
+	"veto":       "vetoes",
+	"roof":       "roofs",
+	"chef":       "chefs",
+	"chief":      "chiefs",
+	"belief":     "beliefs",
+	"cliff":      "cliffs",
+}
+
+// Nouns whose singular and plural forms are the same.
+var invariants = map[string]bool{
+	"sheep":       true,
+	"fish":        true,
+	"deer":        true,
+	"moose":       true,
+	"series":      true,
+	"species":     true,
+	"aircraft":    true,
+	"salmon":      true,
+	"trout":       true,
+	"swine":       true,
+	"bison":       true,
+	"offspring":   true,
+	"news":        true,
+	"equipment":   true,
+	"information": true,
+	"rice":        true,
+	"money":       true,
+	"police":      true,
+	"music":       true,
+	"furniture":   true,
+}
+
+// Suffix rules applied in order when a noun isn't irregular or invariant.
+type rule struct {
+	re   *regexp.Regexp
+	repl string
+}
+
+var (
+	pluralRules = []rule{
+		{regexp.MustCompile(`(?i)([^aeiou])y$`), "${1}ies"},
+		{regexp.MustCompile(`(?i)(s|ss|sh|ch|x|z)$`), "${1}es"},
+		{regexp.MustCompile(`(?i)(?:([^f])fe|([lr]|ea|oa|ie)f)$`), "${1}${2}ves"},
+		{regexp.MustCompile(`(?i)([^aeiou])o$`), "${1}os"},
+		{regexp.MustCompile(`$`), "s"},
+	}
+
+	singularRules = []rule{
+		{regexp.MustCompile(`(?i)([^aeiou])ies$`), "${1}y"},
+		{regexp.MustCompile(`(?i)([lr]|ea|oa|ie)ves$`), "${1}f"},
+		{regexp.MustCompile(`(?i)([^f])ves$`), "${1}fe"},
+		{regexp.MustCompile(`(?i)(ss|sh|ch|x|z)es$`), "${1}"},
+		{regexp.MustCompile(`(?i)([^s])s$`), "${1}"},
+	}
+
+	reWord = regexp.MustCompile(`^[a-z]+$`)
+)
+
+// Plural returns plural and singular forms of English nouns.
+type Plural struct {
+	singulars map[string]string
+}
+
+// New returns a new instance of Plural.
+func New() *Plural {
+	p := &Plural{
+		singulars: make(map[string]string, len(irregulars)),
+	}
+
+	// Reverse lookup map for singularizing irregular nouns.
+	for s, pl := range irregulars {
+		p.singulars[pl] = s
+	}
+
+	return p
+}
+
+// Query returns the plural of a given noun. If the query is suffixed
+// with /singular, the singular of the noun is returned instead.
+// eg: mouse.plural, mice/singular.plural
+func (p *Plural) Query(ctx context.Context, q string) ([]string, error) {
+	str, err := args.Split(q, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		word = strings.ToLower(str[0])
+		mode = "plural"
+	)
+
+	if len(str) == 2 {
+		mode = strings.ToLower(str[1])
+	}
+
+	if !reWord.MatchString(word) || len(word) > 64 {
+		return nil, errors.New("invalid word.")
+	}
+
+	var res string
+	switch mode {
+	case "plural":
+		res = p.Pluralize(word)
+	case "singular":
+		res = p.Singularize(word)
+	default:
+		return nil, errors.New("unknown mode. Use word.plural or word/singular.plural")
+	}
+
+	r := fmt.Sprintf("%s 1 TXT \"%s = %s\"", q, word, res)
+	return []string{r}, nil
+}
+
+// Pluralize returns the plural form of a singular noun.
+func (p *Plural) Pluralize(word string) string {
+	if invariants[word] {
+		return word
+	}
+
+	if pl, ok := irregulars[word]; ok {
+		return pl
+	}
+
+	// Already a known plural.
+	if _, ok := p.singulars[word]; ok {
+		return word
+	}
+
+	return applyRules(word, pluralRules)
+}
+
+// Singularize returns the singular form of a plural noun.
+func (p *Plural) Singularize(word string) string {
+	if invariants[word] {
+		return word
+	}
+
+	if s, ok := p.singulars[word]; ok {
+		return s
+	}
+
+	// Already a known singular.
+	if _, ok := irregulars[word]; ok {
+		return word
+	}
+
+	return applyRules(word, singularRules)
+}
+
+// Dump is not implemented in this package.
+func (p *Plural) Dump() ([]byte, error) {
+	return nil, nil
+}
+
+// applyRules applies the first matching suffix rule to the word.
+func applyRules(word string, rules []rule) string {
+	for _, r := range rules {
+		if r.re.MatchString(word) {
+			return r.re.ReplaceAllString(word, r.repl)
+		}
+	}
+
+	return word
+}
@@ -0,0 +1,97 @@
+package plural
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPluralize(t *testing.T) {
+	tests := []struct {
+		singular string
+		plural   string
+	}{
+		// Irregulars.
+		{"child", "children"},
+		{"person", "people"},
+		{"mouse", "mice"},
+		{"cactus", "cacti"},
+		{"roof", "roofs"},
+
+		// -y => -ies, but not after vowels.
+		{"city", "cities"},
+		{"party", "parties"},
+		{"day", "days"},
+
+		// -f/-fe => -ves.
+		{"wolf", "wolves"},
+		{"leaf", "leaves"},
+		{"knife", "knives"},
+		{"wife", "wives"},
+
+		// -s, -sh, -ch, -x, -z => -es.
+		{"glass", "glasses"},
+		{"dish", "dishes"},
+		{"church", "churches"},
+		{"box", "boxes"},
+
+		// Invariants.
+		{"sheep", "sheep"},
+		{"fish", "fish"},
+		{"species", "species"},
+
+		{"cat", "cats"},
+	}
+
+	p := New()
+	for _, tc := range tests {
+		if out := p.Pluralize(tc.singular); out != tc.plural {
+			t.Errorf("pluralize %s: expected %s, got %s", tc.singular, tc.plural, out)
+		}
+		if out := p.Singularize(tc.plural); out != tc.singular {
+			t.Errorf("singularize %s: expected %s, got %s", tc.plural, tc.singular, out)
+		}
+	}
+}
+
+func TestPluralizeKnown(t *testing.T) {
+	// Words that are already in the requested form are returned as is.
+	p := New()
+	if out := p.Pluralize("children"); out != "children" {
+		t.Errorf("expected children, got %s", out)
+	}
+	if out := p.Singularize("child"); out != "child" {
+		t.Errorf("expected child, got %s", out)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		q   string
+		out string
+		err bool
+	}{
+		{"Child", `Child 1 TXT "child = children"`, false},
+		{"people/singular", `people/singular 1 TXT "people = person"`, false},
+		{"sheep/plural", `sheep/plural 1 TXT "sheep = sheep"`, false},
+		{"mice/ungular", "", true},
+		{"r2d2", "", true},
+	}
+
+	p := New()
+	for _, tc := range tests {
+		out, err := p.Query(context.Background(), tc.q)
+		if tc.err {
+			if err == nil {
+				t.Errorf("%s: expected an error", tc.q)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.q, err)
+			continue
+		}
+		if len(out) != 1 || out[0] != tc.out {
+			t.Errorf("%s: expected %s, got %v", tc.q, tc.out, out)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// package reverse reverses strings by characters (grapheme clusters).
+package reverse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/knadh/dns.toys/internal/errcode"
+)
+
+const (
+	maxLen = 128
+
+	// Zero width joiner that joins emoji into sequences.
+	zwj = '\u200d'
+)
+
+// Reverse reverses strings.
+type Reverse struct{}
+
+// New returns a new instance of Reverse.
+func New() *Reverse {
+	return &Reverse{}
+}
+
+// Query reverses the text in the query. As DNS labels can't have
+// spaces, words in the query are separated by underscores, eg: hello_world.
+func (r *Reverse) Query(ctx context.Context, q string) ([]string, error) {
+	if q == "" {
+		return nil, errors.New("invalid text.")
+	}
+	if len(q) > maxLen {
+		return nil, errcode.Errorf(errcode.Limit, "text is too long. Max %d chars.", maxLen)
+	}
+
+	out := strings.ReplaceAll(String(q), "_", " ")
+	return []string{fmt.Sprintf("%s 1 TXT \"%s\"", q, out)}, nil
+}
+
+// Dump is not implemented in this package.
+func (r *Reverse) Dump() ([]byte, error) {
+	return nil, nil
+}
+
+// String reverses a string by its characters and not bytes or runes, so
+// that combining marks, emoji sequences, and flags stay intact.
+func String(s string) string {
+	cl := clusters(s)
+	for i, j := 0, len(cl)-1; i < j; i, j = i+1, j-1 {
+		cl[i], cl[j] = cl[j], cl[i]
+	}
+
+	return strings.Join(cl, "")
+}
+
+// clusters splits a string into approximate grapheme clusters: a base
+// rune followed by its combining marks, variation selectors, emoji
+// modifiers, and zero width joined runes. Regional indicator (flag)
+// runes are paired.
+func clusters(s string) []string {
+	var (
+		out  []string
+		cur  []rune
+		join bool
+	)
+
+	for _, c := range s {
+		switch {
+		case len(cur) == 0:
+		case join, c == zwj, unicode.Is(unicode.M, c), isEmojiModifier(c):
+		case isRegional(c) && len(cur) == 1 && isRegional(cur[0]):
+		default:
+			out = append(out, string(cur))
+			cur = nil
+		}
+
+		cur = append(cur, c)
+		join = c == zwj
+	}
+
+	if len(cur) > 0 {
+		out = append(out, string(cur))
+	}
+
+	return out
+}
+
+// isEmojiModifier checks if a rune is an emoji skin tone modifier.
+func isEmojiModifier(c rune) bool {
+	return c >= 0x1f3fb && c <= 0x1f3ff
+}
+
+// isRegional checks if a rune is a regional indicator that make up flags.
+func isRegional(c rune) bool {
+	return c >= 0x1f1e6 && c <= 0x1f1ff
+}
@@ -0,0 +1,61 @@
+package reverse
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/knadh/dns.toys/internal/errcode"
+)
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		out  string
+	}{
+		{"ascii", "hello", "olleh"},
+		{"single", "a", "a"},
+		{"empty", "", ""},
+		{"accented", "café", "éfac"},
+		{"combining mark", "cafe\u0301", "e\u0301fac"},
+		{"cyrillic", "привет", "тевирп"},
+		{"emoji", "hi🙂", "🙂ih"},
+		{"skin tone", "a👍🏽b", "b👍🏽a"},
+		{"zwj sequence", "x👩‍💻y", "y👩‍💻x"},
+		{"flags", "🇮🇳🇩🇪", "🇩🇪🇮🇳"},
+		{"variation selector", "a❤️b", "b❤️a"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if out := String(tc.in); out != tc.out {
+				t.Fatalf("expected %q, got %q", tc.out, out)
+			}
+
+			// And back.
+			if out := String(String(tc.in)); out != tc.in {
+				t.Fatalf("expected %q reversed twice, got %q", tc.in, out)
+			}
+		})
+	}
+}
+
+func TestQuery(t *testing.T) {
+	r := New()
+
+	out, err := r.Query(context.Background(), "hello_world")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || out[0] != `hello_world 1 TXT "dlrow olleh"` {
+		t.Fatalf("unexpected response: %v", out)
+	}
+
+	if _, err := r.Query(context.Background(), ""); err == nil {
+		t.Fatal("expected an error for empty text")
+	}
+	if _, err := r.Query(context.Background(), strings.Repeat("a", maxLen+1)); errcode.Of(err) != errcode.Limit {
+		t.Fatalf("expected %s for long text, got %v", errcode.Limit, err)
+	}
+}
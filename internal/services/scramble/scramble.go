@@ -0,0 +1,88 @@
+// package scramble returns random anagrams of words.
+package scramble
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/knadh/dns.toys/internal/args"
+	"github.com/knadh/dns.toys/internal/errcode"
+)
+
+const maxLen = 64
+
+// Scramble returns random anagrams of words.
+type Scramble struct{}
+
+// New returns a new instance of Scramble.
+func New() *Scramble {
+	return &Scramble{}
+}
+
+// Query returns a random anagram of the given word. If the word is
+// suffixed with /sort, the letters of the word are returned sorted,
+// which can be used as a key for grouping anagrams.
+// eg: listen.scramble, listen/sort.scramble
+func (s *Scramble) Query(ctx context.Context, q string) ([]string, error) {
+	str, err := args.Split(q, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		word = []rune(strings.ToLower(str[0]))
+		mode = ""
+	)
+
+	if len(str) == 2 {
+		mode = strings.ToLower(str[1])
+	}
+
+	if len(word) == 0 {
+		return nil, errors.New("invalid word.")
+	}
+	if len(word) > maxLen {
+		return nil, errcode.Errorf(errcode.Limit, "word is too long. Max %d chars.", maxLen)
+	}
+
+	switch mode {
+	case "":
+		if err := shuffle(word); err != nil {
+			return nil, err
+		}
+	case "sort":
+		sort.Slice(word, func(i, j int) bool {
+			return word[i] < word[j]
+		})
+	default:
+		return nil, errors.New("unknown mode. Use word.scramble or word/sort.scramble")
+	}
+
+	r := fmt.Sprintf("%s 1 TXT \"%s\"", q, string(word))
+	return []string{r}, nil
+}
+
+// Dump is not implemented in this package.
+func (s *Scramble) Dump() ([]byte, error) {
+	return nil, nil
+}
+
+// shuffle does a Fisher-Yates shuffle of the runes using crypto/rand.
+func shuffle(r []rune) error {
+	for i := len(r) - 1; i > 0; i-- {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return errcode.New(errcode.Internal, "error generating random number.")
+		}
+
+		j := n.Int64()
+		r[i], r[j] = r[j], r[i]
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+// package search finds the names of the cities in the geo dataset that
+// contain a substring, eg: to find the exact name to query other services with.
+package search
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/knadh/dns.toys/internal/errcode"
+	"github.com/knadh/dns.toys/internal/geo"
+)
+
+const (
+	// Min query length to not match most of the dataset.
+	minLen = 3
+
+	// Max locations in a response.
+	maxResults = 10
+)
+
+// Search searches the geo dataset.
+type Search struct {
+	geo *geo.Geo
+}
+
+// New returns a new instance of Search.
+func New(g *geo.Geo) *Search {
+	return &Search{geo: g}
+}
+
+// Query returns the locations whose names contain the query, the biggest
+// first, as the queryable name, the name, the country, and the timezone.
+func (s *Search) Query(ctx context.Context, q string) ([]string, error) {
+	if len(q) < minLen {
+		return nil, fmt.Errorf("query should be at least %d characters.", minLen)
+	}
+
+	locs, total := s.geo.Search(q, maxResults)
+	if total == 0 {
+		return nil, errcode.New(errcode.NotFound, "no matching cities.")
+	}
+
+	out := make([]string, 0, len(locs)+1)
+	for _, l := range locs {
+		out = append(out, fmt.Sprintf("%s 1 TXT \"%s\" \"%s, %s\" \"%s\"", q, geo.Name(l), strings.ReplaceAll(l.Name, "\"", ""), l.Country, l.Timezone))
+	}
+
+	if total > len(locs) {
+		out = append(out, fmt.Sprintf("%s 1 TXT \"%d more. Refine the query.\"", q, total-len(locs)))
+	}
+
+	return out, nil
+}
+
+// Dump is not implemented in this package.
+func (s *Search) Dump() ([]byte, error) {
+	return nil, nil
+}
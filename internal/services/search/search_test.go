@@ -0,0 +1,88 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/knadh/dns.toys/internal/errcode"
+	"github.com/knadh/dns.toys/internal/geo"
+)
+
+// newTest returns a Search over a geo dataset with the given
+// name, country, timezone, and population rows.
+func newTest(t *testing.T, rows [][]string) *Search {
+	var b strings.Builder
+	for i, r := range rows {
+		// The geonames.org columns.
+		fmt.Fprintf(&b, "%d\t%s\t%s\t\t1.0\t1.0\t\t\t%s\t\t\t\t\t\t%s\t\t\t%s\t\n", i, r[0], r[0], r[1], r[3], r[2])
+	}
+
+	fPath := filepath.Join(t.TempDir(), "cities.txt")
+	if err := ioutil.WriteFile(fPath, []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	g, err := geo.New(fPath, geo.DefaultColumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return New(g)
+}
+
+func TestQuery(t *testing.T) {
+	rows := [][]string{
+		{"Berlin", "US", "America/New_York", "20000"},
+		{"Berlin", "DE", "Europe/Berlin", "3500000"},
+		{"New York City", "US", "America/New_York", "8000000"},
+		{"Paris", "FR", "Europe/Paris", "2000000"},
+	}
+	for i := 0; i < 12; i++ {
+		rows = append(rows, []string{"Town" + string(rune('a'+i)), "GB", "Europe/London", fmt.Sprint(1000 + i)})
+	}
+	s := newTest(t, rows)
+
+	tests := []struct {
+		q   string
+		out []string
+	}{
+		// The biggest first.
+		{"berl", []string{
+			`berl 1 TXT "berlin" "Berlin, DE" "Europe/Berlin"`,
+			`berl 1 TXT "berlin" "Berlin, US" "America/New_York"`,
+		}},
+		{"york", []string{`york 1 TXT "newyorkcity" "New York City, US" "America/New_York"`}},
+		{"PARIS", []string{`PARIS 1 TXT "paris" "Paris, FR" "Europe/Paris"`}},
+	}
+	for _, tc := range tests {
+		out, err := s.Query(context.Background(), tc.q)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.q, err)
+		}
+		if strings.Join(out, "\n") != strings.Join(tc.out, "\n") {
+			t.Fatalf("%s: expected %v, got %v", tc.q, tc.out, out)
+		}
+	}
+
+	// Results are capped with a note.
+	out, err := s.Query(context.Background(), "town")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != maxResults+1 || !strings.HasPrefix(out[0], `town 1 TXT "townl" "Townl, GB"`) {
+		t.Fatalf("expected %d results and a note, got %v", maxResults, out)
+	}
+	if exp := `town 1 TXT "2 more. Refine the query."`; out[maxResults] != exp {
+		t.Fatalf("expected %s, got %s", exp, out[maxResults])
+	}
+
+	// Errors.
+	for q, code := range map[string]errcode.Code{"be": errcode.Invalid, "atlantis": errcode.NotFound} {
+		if _, err := s.Query(context.Background(), q); errcode.Of(err) != code {
+			t.Fatalf("%s: expected %s, got %v", q, code, err)
+		}
+	}
+}
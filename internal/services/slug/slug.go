@@ -0,0 +1,88 @@
+// package slug converts text into URL friendly slugs.
+package slug
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/knadh/dns.toys/internal/errcode"
+)
+
+const maxLen = 128
+
+// Transliterations of common accented and special characters to ASCII.
+var translit = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a",
+	'æ': "ae", 'ç': "c", 'ć': "c", 'č': "c", 'ď': "d", 'đ': "d", 'ð': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ė': "e", 'ę': "e", 'ě': "e",
+	'ğ': "g", 'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i", 'į': "i", 'ı': "i",
+	'ł': "l", 'ľ': "l", 'ñ': "n", 'ń': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ő': "o", 'œ': "oe",
+	'ŕ': "r", 'ř': "r", 'ś': "s", 'š': "s", 'ş': "s", 'ß': "ss", 'ť': "t", 'ţ': "t", 'þ': "th",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ů': "u", 'ű': "u", 'ų': "u",
+	'ý': "y", 'ÿ': "y", 'ź': "z", 'ż': "z", 'ž': "z",
+}
+
+// Slug converts text to URL slugs.
+type Slug struct{}
+
+// New returns a new instance of Slug.
+func New() *Slug {
+	return &Slug{}
+}
+
+// Query converts the given text to a slug. As DNS labels can't have spaces,
+// underscores in the query are treated as spaces.
+// eg: Hello_World_2024.slug
+func (s *Slug) Query(ctx context.Context, q string) ([]string, error) {
+	if len(q) > maxLen {
+		return nil, errcode.Errorf(errcode.Limit, "text is too long. Max %d chars.", maxLen)
+	}
+
+	sl := Make(q)
+	if sl == "" {
+		return nil, errors.New("invalid text.")
+	}
+
+	r := fmt.Sprintf("%s 1 TXT \"%s\"", q, sl)
+	return []string{r}, nil
+}
+
+// Make converts the given string to a lowercase slug with all
+// non-alphanumeric runs collapsed into a single hyphen.
+func Make(str string) string {
+	var (
+		b    strings.Builder
+		dash = false
+	)
+
+	for _, c := range strings.ToLower(str) {
+		if t, ok := translit[c]; ok {
+			b.WriteString(t)
+			dash = false
+			continue
+		}
+
+		if c < unicode.MaxASCII && (unicode.IsLetter(c) || unicode.IsDigit(c)) {
+			b.WriteRune(c)
+			dash = false
+			continue
+		}
+
+		// Collapse all other chars into a single hyphen.
+		if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// Dump is not implemented in this package.
+func (s *Slug) Dump() ([]byte, error) {
+	return nil, nil
+}
@@ -0,0 +1,270 @@
+package spell
+
+import "strings"
+
+var (
+	esSmall = []string{
+		"cero", "uno", "dos", "tres", "cuatro", "cinco", "seis", "siete", "ocho", "nueve",
+		"diez", "once", "doce", "trece", "catorce", "quince", "dieciséis", "diecisiete", "dieciocho", "diecinueve",
+		"veinte", "veintiuno", "veintidós", "veintitrés", "veinticuatro", "veinticinco", "veintiséis", "veintisiete", "veintiocho", "veintinueve",
+	}
+	esTens = []string{
+		"", "", "", "treinta", "cuarenta", "cincuenta", "sesenta", "setenta", "ochenta", "noventa",
+	}
+	esHundreds = []string{
+		"", "ciento", "doscientos", "trescientos", "cuatrocientos", "quinientos",
+		"seiscientos", "setecientos", "ochocientos", "novecientos",
+	}
+
+	deSmall = []string{
+		"null", "eins", "zwei", "drei", "vier", "fünf", "sechs", "sieben", "acht", "neun",
+		"zehn", "elf", "zwölf", "dreizehn", "vierzehn", "fünfzehn", "sechzehn", "siebzehn", "achtzehn", "neunzehn",
+	}
+	deTens = []string{
+		"", "", "zwanzig", "dreißig", "vierzig", "fünfzig", "sechzig", "siebzig", "achtzig", "neunzig",
+	}
+
+	frSmall = []string{
+		"zéro", "un", "deux", "trois", "quatre", "cinq", "six", "sept", "huit", "neuf",
+		"dix", "onze", "douze", "treize", "quatorze", "quinze", "seize", "dix-sept", "dix-huit", "dix-neuf",
+	}
+	frTens = []string{
+		"", "", "vingt", "trente", "quarante", "cinquante", "soixante", "soixante", "quatre-vingt", "quatre-vingt",
+	}
+)
+
+// es spells a number in Spanish.
+func es(n int) string {
+	if n == 0 {
+		return esSmall[0]
+	}
+
+	var out []string
+	if b := n / 1000000; b > 0 {
+		if b == 1 {
+			out = append(out, "un millón")
+		} else {
+			out = append(out, esThousands(b, true)+" millones")
+		}
+	}
+	if r := n % 1000000; r > 0 {
+		out = append(out, esThousands(r, false))
+	}
+
+	return strings.Join(out, " ")
+}
+
+// esThousands spells 1 - 999999. short shortens a trailing uno to un
+// (apocope) before a noun, eg: veintiún millones.
+func esThousands(n int, short bool) string {
+	var out []string
+	if t := n / 1000; t == 1 {
+		out = append(out, "mil")
+	} else if t > 1 {
+		out = append(out, esHundred(t, true)+" mil")
+	}
+	if r := n % 1000; r > 0 {
+		out = append(out, esHundred(r, short))
+	}
+
+	return strings.Join(out, " ")
+}
+
+// esHundred spells 1 - 999.
+func esHundred(n int, short bool) string {
+	if n == 100 {
+		return "cien"
+	}
+
+	var out []string
+	if h := n / 100; h > 0 {
+		out = append(out, esHundreds[h])
+	}
+
+	t := n % 100
+	switch {
+	case t == 0:
+	case t < 30:
+		w := esSmall[t]
+		if short && t == 1 {
+			w = "un"
+		} else if short && t == 21 {
+			w = "veintiún"
+		}
+		out = append(out, w)
+	default:
+		w := esTens[t/10]
+		if u := t % 10; u > 0 {
+			uw := esSmall[u]
+			if short && u == 1 {
+				uw = "un"
+			}
+			w += " y " + uw
+		}
+		out = append(out, w)
+	}
+
+	return strings.Join(out, " ")
+}
+
+// de spells a number in German.
+func de(n int) string {
+	if n == 0 {
+		return deSmall[0]
+	}
+
+	var out []string
+	for _, s := range []struct {
+		n          int
+		one, other string
+	}{
+		{1000000000, "eine Milliarde", "Milliarden"},
+		{1000000, "eine Million", "Millionen"},
+	} {
+		if v := n / s.n; v == 1 {
+			out = append(out, s.one)
+		} else if v > 1 {
+			out = append(out, deHundred(v, "eine")+" "+s.other)
+		}
+		n %= s.n
+	}
+
+	// Thousands and below are written as one word.
+	w := ""
+	if t := n / 1000; t > 0 {
+		w = deHundred(t, "ein") + "tausend"
+	}
+	if r := n % 1000; r > 0 {
+		w += deHundred(r, "eins")
+	}
+	if w != "" {
+		out = append(out, w)
+	}
+
+	return strings.Join(out, " ")
+}
+
+// deHundred spells 1 - 999. one is the form of a trailing one, eg: eins,
+// or ein and eine before a noun.
+func deHundred(n int, one string) string {
+	w := ""
+	if h := n / 100; h > 0 {
+		w = deOne(h, "ein") + "hundert"
+	}
+
+	t := n % 100
+	switch {
+	case t == 0:
+	case t < 20:
+		w += deOne(t, one)
+	default:
+		if u := t % 10; u > 0 {
+			w += deOne(u, "ein") + "und"
+		}
+		w += deTens[t/10]
+	}
+
+	return w
+}
+
+func deOne(n int, one string) string {
+	if n == 1 {
+		return one
+	}
+
+	return deSmall[n]
+}
+
+// fr spells a number in French (traditional spelling).
+func fr(n int) string {
+	if n == 0 {
+		return frSmall[0]
+	}
+
+	var out []string
+	for _, s := range []struct {
+		n    int
+		name string
+	}{
+		{1000000000, "milliard"},
+		{1000000, "million"},
+	} {
+		if v := n / s.n; v == 1 {
+			out = append(out, "un "+s.name)
+		} else if v > 1 {
+			out = append(out, frHundred(v, true)+" "+s.name+"s")
+		}
+		n %= s.n
+	}
+
+	// Mille is invariable and vingt and cent aren't plural before it.
+	if t := n / 1000; t == 1 {
+		out = append(out, "mille")
+	} else if t > 1 {
+		out = append(out, frHundred(t, false)+" mille")
+	}
+	if r := n % 1000; r > 0 {
+		out = append(out, frHundred(r, true))
+	}
+
+	return strings.Join(out, " ")
+}
+
+// frHundred spells 1 - 999. plural adds the s to a trailing cent or
+// quatre-vingt, eg: deux cents.
+func frHundred(n int, plural bool) string {
+	var (
+		out []string
+		t   = n % 100
+	)
+
+	if h := n / 100; h > 0 {
+		w := "cent"
+		if h > 1 {
+			w = frSmall[h] + " cent"
+			if t == 0 && plural {
+				w += "s"
+			}
+		}
+		out = append(out, w)
+	}
+
+	if t > 0 {
+		out = append(out, frTen(t, plural))
+	}
+
+	return strings.Join(out, " ")
+}
+
+// frTen spells 1 - 99.
+func frTen(t int, plural bool) string {
+	if t < 20 {
+		return frSmall[t]
+	}
+
+	var (
+		tens = t / 10
+		u    = t % 10
+		w    = frTens[tens]
+	)
+
+	// 70 - 79 and 90 - 99 are 60 + 10 - 19 and 80 + 10 - 19.
+	if tens == 7 || tens == 9 {
+		u += 10
+	}
+
+	switch {
+	case u == 0:
+		if tens == 8 && plural {
+			w += "s"
+		}
+	case u == 1 && tens < 8:
+		w += " et un"
+	case u == 11 && tens == 7:
+		w += " et onze"
+	default:
+		w += "-" + frSmall[u]
+	}
+
+	return w
+}
@@ -0,0 +1,89 @@
+// package spell writes numbers in words in different languages.
+package spell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/knadh/dns.toys/internal/args"
+	"github.com/knadh/dns.toys/internal/errcode"
+	"github.com/knadh/dns.toys/internal/i18n"
+	"github.com/knadh/dns.toys/internal/services/num2words"
+)
+
+const maxNum = 999999999999
+
+// Spellers by language for positive numbers. English is the fallback.
+var langs = map[string]func(n int) string{
+	"en": num2words.Words,
+	"es": es,
+	"de": de,
+	"fr": fr,
+}
+
+// Word for "minus" by language.
+var minus = map[string]string{
+	"en": "minus",
+	"es": "menos",
+	"de": "minus",
+	"fr": "moins",
+}
+
+// Spell writes numbers in words.
+type Spell struct{}
+
+// New returns a new instance of Spell.
+func New() *Spell {
+	return &Spell{}
+}
+
+// Query returns a number in words in a language (en, es, de, fr). Unsupported
+// languages fall back to English.
+// Format: $number/$lang or $number/lang-$lang, eg: 1234/es, 42/lang-de.
+func (s *Spell) Query(ctx context.Context, q string) ([]string, error) {
+	str, err := args.Split(q, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := strconv.Atoi(str[0])
+	if err != nil {
+		return nil, errors.New("invalid number. eg: 1234/es")
+	}
+	if n > maxNum || n < -maxNum {
+		return nil, errcode.Errorf(errcode.Limit, "number is out of range. Max %d.", maxNum)
+	}
+
+	lang := i18n.Default
+	if len(str) == 2 {
+		l, ok := i18n.ParseLang(str[1])
+		if !ok {
+			if len(str[1]) != 2 {
+				return nil, errors.New("invalid language. Use a 2 letter code, eg: es, de, fr.")
+			}
+			l = strings.ToLower(str[1])
+		}
+
+		if _, ok := langs[l]; ok {
+			lang = l
+		}
+	}
+
+	var w string
+	if n < 0 {
+		w = minus[lang] + " " + langs[lang](-n)
+	} else {
+		w = langs[lang](n)
+	}
+
+	r := fmt.Sprintf("%s 1 TXT \"%d = %s\" \"%s\"", q, n, w, lang)
+	return []string{r}, nil
+}
+
+// Dump is not implemented in this package.
+func (s *Spell) Dump() ([]byte, error) {
+	return nil, nil
+}
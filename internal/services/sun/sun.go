@@ -0,0 +1,124 @@
+// package sun returns the sunrise, sunset, and twilight times for
+// geographic locations.
+package sun
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/args"
+	"github.com/knadh/dns.toys/internal/errcode"
+	"github.com/knadh/dns.toys/internal/geo"
+	"github.com/knadh/dns.toys/internal/sun"
+)
+
+// Day phases and the sun's elevation at their start and end.
+var phases = []struct {
+	name string
+	elev float64
+}{
+	{"civil twilight", sun.Civil},
+	{"nautical twilight", sun.Nautical},
+	{"astronomical twilight", sun.Astronomical},
+}
+
+// Sun returns the sun's timings for a location.
+type Sun struct {
+	geo *geo.Geo
+}
+
+// New returns a new instance of Sun.
+func New(g *geo.Geo) *Sun {
+	return &Sun{
+		geo: g,
+	}
+}
+
+// Query returns the sunrise, sunset, and twilight times for a location today.
+// Format: $city, $city/$country, or $lat,$lon, eg: berlin, paris/fr, 52.52,13.40.
+func (s *Sun) Query(ctx context.Context, q string) ([]string, error) {
+	str, err := args.Split(q, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	country := ""
+
+	// Is there a /2-letter-country-code?
+	if len(str) == 2 && len(str[1]) == 2 {
+		q = str[0]
+		country = strings.ToUpper(str[1])
+	}
+	q = strings.ToLower(q)
+
+	loc, err := s.locate(q, country)
+	if err != nil {
+		return nil, err
+	}
+
+	zone, err := time.LoadLocation(loc.Timezone)
+	if err != nil {
+		return nil, errcode.New(errcode.NotFound, "unknown timezone for city.")
+	}
+
+	// Coordinates have no timezone, so the times are in UTC.
+	name := fmt.Sprintf("%s (%s)", loc.Name, loc.Country)
+	if geo.IsCoords(q) {
+		name = loc.Name + " (UTC)"
+	}
+
+	now := time.Now().In(zone)
+	out := []string{
+		fmt.Sprintf("%s 1 TXT \"%s\" \"%s\"", q, name, now.Format("Mon, 02 Jan 2006")),
+	}
+
+	// Sunrise and sunset.
+	rise, set, err := sun.Crossing(now, loc.Lat, loc.Lon, sun.Horizon)
+	switch err {
+	case nil:
+		out = append(out, fmt.Sprintf("%s 1 TXT \"sunrise %s\" \"sunset %s\"", q, rise.Format("15:04"), set.Format("15:04")))
+	case sun.ErrAlwaysAbove:
+		out = append(out, fmt.Sprintf("%s 1 TXT \"the sun doesn't set today\"", q))
+	case sun.ErrAlwaysBelow:
+		out = append(out, fmt.Sprintf("%s 1 TXT \"the sun doesn't rise today\"", q))
+	}
+
+	// Twilights begin in the morning when the sun rises above the phase's
+	// elevation and end in the evening when it sets below it.
+	for _, p := range phases {
+		begin, end, err := sun.Crossing(now, loc.Lat, loc.Lon, p.elev)
+		switch err {
+		case nil:
+			out = append(out, fmt.Sprintf("%s 1 TXT \"%s begins %s\" \"ends %s\"", q, p.name, begin.Format("15:04"), end.Format("15:04")))
+		case sun.ErrAlwaysAbove:
+			out = append(out, fmt.Sprintf("%s 1 TXT \"%s: it doesn't get dark enough to end today\"", q, p.name))
+		case sun.ErrAlwaysBelow:
+			out = append(out, fmt.Sprintf("%s 1 TXT \"%s: the sun doesn't rise high enough for it today\"", q, p.name))
+		}
+	}
+
+	return out, nil
+}
+
+// locate returns the location for coordinates, eg: 52.52,13.40, or the first
+// (most populous) location for a city matching the country, if given.
+func (s *Sun) locate(q, country string) (geo.Location, error) {
+	if geo.IsCoords(q) {
+		return geo.ParseCoords(q)
+	}
+
+	for _, l := range s.geo.Query(q) {
+		if country == "" || l.Country == country {
+			return l, nil
+		}
+	}
+
+	return geo.Location{}, errcode.New(errcode.NotFound, "unknown city.")
+}
+
+// Dump is not implemented in this package.
+func (s *Sun) Dump() ([]byte, error) {
+	return nil, nil
+}
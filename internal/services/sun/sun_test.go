@@ -0,0 +1,25 @@
+package sun
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestQueryCoords(t *testing.T) {
+	s := New(nil)
+
+	out, err := s.Query(context.Background(), "52.52,13.40")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(out) < 2 || !strings.Contains(out[0], "52.52,13.40 (UTC)") {
+		t.Fatalf("unexpected response: %v", out)
+	}
+
+	for _, q := range []string{"95,13.40", "52.52,200"} {
+		if _, err := s.Query(context.Background(), q); err == nil {
+			t.Fatalf("%s: expected an out of range error", q)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// package temp converts temperatures between the Celsius, Fahrenheit,
+// Kelvin, and Rankine scales.
+package temp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type scale struct {
+	name string
+
+	// Conversions to and from Kelvin.
+	toK, fromK func(float64) float64
+}
+
+var (
+	reParse = regexp.MustCompile(`^(\-?[0-9\.]+)([a-zA-Z])\-([a-zA-Z])$`)
+
+	scales = map[string]scale{
+		"c": {"Celsius", func(v float64) float64 { return v + 273.15 }, func(k float64) float64 { return k - 273.15 }},
+		"f": {"Fahrenheit", func(v float64) float64 { return (v + 459.67) * 5 / 9 }, func(k float64) float64 { return k*9/5 - 459.67 }},
+		"k": {"Kelvin", func(v float64) float64 { return v }, func(k float64) float64 { return k }},
+		"r": {"Rankine", func(v float64) float64 { return v * 5 / 9 }, func(k float64) float64 { return k * 9 / 5 }},
+	}
+
+	// from+to => the formula.
+	formulas = map[string]string{
+		"cf": "F = C * 9/5 + 32",
+		"ck": "K = C + 273.15",
+		"cr": "R = (C + 273.15) * 9/5",
+		"fc": "C = (F - 32) * 5/9",
+		"fk": "K = (F + 459.67) * 5/9",
+		"fr": "R = F + 459.67",
+		"kc": "C = K - 273.15",
+		"kf": "F = K * 9/5 - 459.67",
+		"kr": "R = K * 9/5",
+		"rc": "C = (R - 491.67) * 5/9",
+		"rf": "F = R - 459.67",
+		"rk": "K = R * 5/9",
+	}
+)
+
+// Temp converts temperatures.
+type Temp struct{}
+
+// New returns a new instance of Temp.
+func New() *Temp {
+	return &Temp{}
+}
+
+// Query converts a temperature between scales. Format: $value$from-$to,
+// eg: 100c-f, -40f-c, 300k-r.
+func (t *Temp) Query(ctx context.Context, q string) ([]string, error) {
+	res := reParse.FindStringSubmatch(q)
+	if res == nil {
+		return nil, errors.New("invalid conversion. eg: 100c-f")
+	}
+
+	val, err := strconv.ParseFloat(res[1], 64)
+	if err != nil {
+		return nil, errors.New("invalid temperature.")
+	}
+
+	fromSym, toSym := strings.ToLower(res[2]), strings.ToLower(res[3])
+	from, ok := scales[fromSym]
+	if !ok {
+		return nil, fmt.Errorf("unknown scale: %s. Use c, f, k, or r.", res[2])
+	}
+	to, ok := scales[toSym]
+	if !ok {
+		return nil, fmt.Errorf("unknown scale: %s. Use c, f, k, or r.", res[3])
+	}
+
+	k := from.toK(val)
+	if k < 0 {
+		return nil, errors.New("temperature is below absolute zero.")
+	}
+
+	formula := "same scale"
+	if fromSym != toSym {
+		formula = formulas[fromSym+toSym]
+	}
+
+	r := fmt.Sprintf("%s 1 TXT \"%s %s (%s) = %s %s (%s)\" \"%s\"", q,
+		format(val), from.name, strings.ToUpper(fromSym),
+		format(to.fromK(k)), to.name, strings.ToUpper(toSym), formula)
+
+	return []string{r}, nil
+}
+
+// Knows checks if a scale symbol, eg: c, is known.
+func (t *Temp) Knows(sym string) bool {
+	_, ok := scales[strings.ToLower(sym)]
+	return ok
+}
+
+// Dump is not implemented in this package.
+func (t *Temp) Dump() ([]byte, error) {
+	return nil, nil
+}
+
+// format formats a temperature with up to 2 decimals, eg: 212, 37.78.
+func format(v float64) string {
+	v = math.Round(v*100) / 100
+	if v == 0 {
+		// Avoid -0.
+		v = 0
+	}
+
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
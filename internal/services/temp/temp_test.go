@@ -0,0 +1,66 @@
+package temp
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	// The same temperatures in each scale.
+	points := []map[string]string{
+		{"c": "100", "f": "212", "k": "373.15", "r": "671.67"},
+		{"c": "-40", "f": "-40", "k": "233.15", "r": "419.67"},
+		{"c": "0", "f": "32", "k": "273.15", "r": "491.67"},
+		{"c": "-273.15", "f": "-459.67", "k": "0", "r": "0"},
+	}
+
+	tp := New()
+	for _, p := range points {
+		for from, v := range p {
+			for to, exp := range p {
+				q := v + from + "-" + to
+				out, err := tp.Query(context.Background(), q)
+				if err != nil {
+					t.Fatalf("%s: unexpected error: %v", q, err)
+				}
+
+				s := "= " + exp + " " + scales[to].name + " (" + strings.ToUpper(to) + ")"
+				if len(out) != 1 || !strings.Contains(out[0], s) {
+					t.Fatalf("%s: expected %s, got %v", q, s, out)
+				}
+
+				f := "same scale"
+				if from != to {
+					f = formulas[from+to]
+				}
+				if !strings.HasSuffix(out[0], "\""+f+"\"") {
+					t.Fatalf("%s: expected the formula %s, got %s", q, f, out[0])
+				}
+			}
+		}
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	tests := []struct {
+		q   string
+		err string
+	}{
+		{"100c-x", "unknown scale: x"},
+		{"100x-c", "unknown scale: x"},
+		{"100c", "invalid conversion"},
+		{"c-f", "invalid conversion"},
+		{"1.2.3c-f", "invalid temperature"},
+		{"-1k-c", "below absolute zero"},
+		{"-300c-f", "below absolute zero"},
+		{"-500f-c", "below absolute zero"},
+	}
+
+	tp := New()
+	for _, tc := range tests {
+		if _, err := tp.Query(context.Background(), tc.q); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Fatalf("%s: expected %q, got %v", tc.q, tc.err, err)
+		}
+	}
+}
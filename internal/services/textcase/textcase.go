@@ -0,0 +1,141 @@
+// package textcase transforms text between cases (camel, snake etc.)
+package textcase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/knadh/dns.toys/internal/args"
+	"github.com/knadh/dns.toys/internal/errcode"
+)
+
+const maxLen = 128
+
+// Case transformation functions by mode.
+var modes = map[string]func([]string) string{
+	"upper": func(w []string) string {
+		return strings.ToUpper(strings.Join(w, " "))
+	},
+	"lower": func(w []string) string {
+		return strings.ToLower(strings.Join(w, " "))
+	},
+	"title": func(w []string) string {
+		out := make([]string, len(w))
+		for i, s := range w {
+			out[i] = capitalize(s)
+		}
+		return strings.Join(out, " ")
+	},
+	"camel": Camel,
+	"snake": Snake,
+	"kebab": func(w []string) string {
+		return strings.ToLower(strings.Join(w, "-"))
+	},
+	"shout": func(w []string) string {
+		return strings.ToUpper(strings.Join(w, " ")) + "!"
+	},
+}
+
+// TextCase transforms text between cases.
+type TextCase struct{}
+
+// New returns a new instance of TextCase.
+func New() *TextCase {
+	return &TextCase{}
+}
+
+// Query transforms the text to the given case. As DNS labels can't have
+// spaces, words in the query are separated by underscores.
+// Format: $text/$mode, eg: hello_world/upper.
+func (t *TextCase) Query(ctx context.Context, q string) ([]string, error) {
+	str, err := args.Split(q, 2)
+	if err != nil || len(str) != 2 {
+		return nil, errors.New("invalid case query. Use text/mode. eg: hello_world/camel")
+	}
+
+	if len(str[0]) > maxLen {
+		return nil, errcode.Errorf(errcode.Limit, "text is too long. Max %d chars.", maxLen)
+	}
+
+	fn, ok := modes[strings.ToLower(str[1])]
+	if !ok {
+		return nil, errors.New("unknown case. Use upper, lower, title, camel, snake, kebab, or shout.")
+	}
+
+	words := Split(str[0])
+	if len(words) == 0 {
+		return nil, errors.New("invalid text.")
+	}
+
+	r := fmt.Sprintf("%s 1 TXT \"%s\"", q, fn(words))
+	return []string{r}, nil
+}
+
+// Dump is not implemented in this package.
+func (t *TextCase) Dump() ([]byte, error) {
+	return nil, nil
+}
+
+// Split splits text into words on underscores, hyphens, and
+// lower-to-upper case boundaries (camelCase).
+func Split(s string) []string {
+	var (
+		out  []string
+		word []rune
+		prev rune
+	)
+
+	for _, c := range s {
+		switch {
+		case c == '_' || c == '-':
+			if len(word) > 0 {
+				out = append(out, string(word))
+			}
+			word = nil
+		case unicode.IsUpper(c) && (unicode.IsLower(prev) || unicode.IsDigit(prev)):
+			out = append(out, string(word))
+			word = []rune{c}
+		default:
+			word = append(word, c)
+		}
+		prev = c
+	}
+
+	if len(word) > 0 {
+		out = append(out, string(word))
+	}
+
+	return out
+}
+
+// Camel joins words into camelCase.
+func Camel(w []string) string {
+	var b strings.Builder
+	for i, s := range w {
+		if i == 0 {
+			b.WriteString(strings.ToLower(s))
+			continue
+		}
+		b.WriteString(capitalize(s))
+	}
+
+	return b.String()
+}
+
+// Snake joins words into snake_case.
+func Snake(w []string) string {
+	return strings.ToLower(strings.Join(w, "_"))
+}
+
+// capitalize uppercases the first letter of a word and lowercases the rest.
+func capitalize(s string) string {
+	r := []rune(strings.ToLower(s))
+	if len(r) > 0 {
+		r[0] = unicode.ToUpper(r[0])
+	}
+
+	return string(r)
+}
@@ -0,0 +1,91 @@
+package textcase
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		in  string
+		out []string
+	}{
+		{"hello_world", []string{"hello", "world"}},
+		{"hello-world", []string{"hello", "world"}},
+		{"helloWorld", []string{"hello", "World"}},
+		{"HelloWorld", []string{"Hello", "World"}},
+		{"html5Parser", []string{"html5", "Parser"}},
+		{"__hello__world_", []string{"hello", "world"}},
+		{"hello", []string{"hello"}},
+		{"_", nil},
+	}
+
+	for _, tc := range tests {
+		if out := Split(tc.in); !reflect.DeepEqual(out, tc.out) {
+			t.Errorf("%s: expected %v, got %v", tc.in, tc.out, out)
+		}
+	}
+}
+
+func TestCamelSnake(t *testing.T) {
+	tests := []struct {
+		snake string
+		camel string
+	}{
+		{"hello_world", "helloWorld"},
+		{"the_quick_brown_fox", "theQuickBrownFox"},
+		{"user_id", "userId"},
+		{"html5_parser", "html5Parser"},
+		{"word", "word"},
+	}
+
+	for _, tc := range tests {
+		c := Camel(Split(tc.snake))
+		if c != tc.camel {
+			t.Errorf("camel %s: expected %s, got %s", tc.snake, tc.camel, c)
+		}
+
+		// And back.
+		if s := Snake(Split(c)); s != tc.snake {
+			t.Errorf("snake %s: expected %s, got %s", c, tc.snake, s)
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		q   string
+		out string
+		err bool
+	}{
+		{"hello_world/upper", `hello_world/upper 1 TXT "HELLO WORLD"`, false},
+		{"HELLO_WORLD/lower", `HELLO_WORLD/lower 1 TXT "hello world"`, false},
+		{"hello_world/title", `hello_world/title 1 TXT "Hello World"`, false},
+		{"hello_world/CAMEL", `hello_world/CAMEL 1 TXT "helloWorld"`, false},
+		{"helloWorld/snake", `helloWorld/snake 1 TXT "hello_world"`, false},
+		{"helloWorld/kebab", `helloWorld/kebab 1 TXT "hello-world"`, false},
+		{"hello_world/shout", `hello_world/shout 1 TXT "HELLO WORLD!"`, false},
+		{"hello_world/pascal", "", true},
+		{"hello_world", "", true},
+		{"_/upper", "", true},
+	}
+
+	c := New()
+	for _, tc := range tests {
+		out, err := c.Query(context.Background(), tc.q)
+		if tc.err {
+			if err == nil {
+				t.Errorf("%s: expected an error", tc.q)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.q, err)
+			continue
+		}
+		if len(out) != 1 || out[0] != tc.out {
+			t.Errorf("%s: expected %s, got %v", tc.q, tc.out, out)
+		}
+	}
+}
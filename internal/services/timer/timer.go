@@ -0,0 +1,134 @@
+// package timer returns the wall-clock end time of a timer (eg: pomodoro)
+// that's started now.
+package timer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/args"
+	"github.com/knadh/dns.toys/internal/errcode"
+	"github.com/knadh/dns.toys/internal/geo"
+)
+
+const maxDuration = time.Hour * 24
+
+// Preset durations that can be used instead of a duration.
+var presets = map[string]time.Duration{
+	"pomodoro":  time.Minute * 25,
+	"break":     time.Minute * 5,
+	"longbreak": time.Minute * 15,
+}
+
+var errInvalid = errors.New("invalid timer. Use duration/city. eg: 25m, 1h30m/berlin, pomodoro")
+
+// Timer computes timer end times.
+type Timer struct {
+	geo *geo.Geo
+}
+
+// New returns a new instance of Timer.
+func New(g *geo.Geo) *Timer {
+	return &Timer{
+		geo: g,
+	}
+}
+
+// Query returns the end time of a timer for a duration from now, in UTC
+// or in an optional city's timezone.
+// Format: $duration, $duration/$city, or $duration/$city/$country,
+// eg: 25m, 1h30m/berlin, pomodoro/paris/fr.
+func (t *Timer) Query(ctx context.Context, q string) ([]string, error) {
+	str, err := args.Split(strings.ToLower(q), 3)
+	if err != nil {
+		return nil, err
+	}
+
+	d, ok := presets[str[0]]
+	if !ok {
+		d, err = time.ParseDuration(str[0])
+		if err != nil {
+			return nil, errInvalid
+		}
+	}
+	if d < time.Second || d > maxDuration {
+		return nil, errcode.Errorf(errcode.Limit, "duration should be between 1s and %s.", fmtDuration(maxDuration))
+	}
+
+	var (
+		zone = time.UTC
+		name = "UTC"
+	)
+	if len(str) > 1 {
+		loc, err := t.lookup(str[1:])
+		if err != nil {
+			return nil, err
+		}
+
+		z, err := time.LoadLocation(loc.Timezone)
+		if err != nil {
+			return nil, errcode.New(errcode.NotFound, "unknown timezone for city.")
+		}
+		zone, name = z, fmt.Sprintf("%s (%s)", loc.Name, loc.Country)
+	}
+
+	var (
+		now = time.Now().In(zone)
+		end = now.Add(d)
+	)
+
+	// Show the seconds for timers that aren't whole minutes and the day
+	// for timers that end on another day.
+	layout := "15:04"
+	if d%time.Minute != 0 {
+		layout = "15:04:05"
+	}
+	if end.YearDay() != now.YearDay() {
+		layout += ", Mon"
+	}
+
+	r := fmt.Sprintf("%s 1 TXT \"timer ends at %s (in %s)\" \"%s\"", q, end.Format(layout), fmtDuration(d), name)
+	return []string{r}, nil
+}
+
+// Dump is not implemented in this package.
+func (t *Timer) Dump() ([]byte, error) {
+	return nil, nil
+}
+
+// lookup returns the most populous location for a city and an optional
+// 2-letter country code.
+func (t *Timer) lookup(str []string) (geo.Location, error) {
+	country := ""
+	if len(str) == 2 {
+		if len(str[1]) != 2 {
+			return geo.Location{}, errInvalid
+		}
+		country = strings.ToUpper(str[1])
+	}
+
+	for _, l := range t.geo.Query(str[0]) {
+		if country == "" || l.Country == country {
+			return l, nil
+		}
+	}
+
+	return geo.Location{}, errcode.New(errcode.NotFound, "unknown city.")
+}
+
+// fmtDuration formats a duration without the zero units, eg: 25m and
+// not 25m0s.
+func fmtDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+
+	return s
+}
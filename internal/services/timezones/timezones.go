@@ -2,51 +2,100 @@
 package timezones
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
 	"time"
 
+	"github.com/knadh/dns.toys/internal/args"
+	"github.com/knadh/dns.toys/internal/errcode"
 	"github.com/knadh/dns.toys/internal/geo"
+	"github.com/knadh/dns.toys/internal/i18n"
 )
 
+// Separator between the source and the target in a conversion query.
+const convSep = "-in-"
+
+// Accepted formats for the time in a conversion query.
+var clockFormats = []string{"3pm", "3:04pm", "15:04", "1504", "15"}
+
 // Timezones controller returns times for various geographic locations.
 type Timezones struct {
+	opt Opt
 	geo *geo.Geo
 }
 
 // Opt contains config options for the Time package.
-type Opt struct{}
+type Opt struct {
+	// Default language for the day names.
+	DefaultLang string
+}
 
 // New returns a new instance of Time.
 func New(o Opt, g *geo.Geo) *Timezones {
 	return &Timezones{
+		opt: o,
 		geo: g,
 	}
 }
 
 // Query parses a given query string and returns the answer.
-// For the time package, the query is a location name.
-func (t *Timezones) Query(q string) ([]string, error) {
+// For the time package, the query is a location name, or a conversion
+// of a time in a city to another city, eg: 3pm-london-in-tokyo.
+func (t *Timezones) Query(ctx context.Context, q string) ([]string, error) {
+	if strings.Contains(q, convSep) {
+		return t.convert(q)
+	}
+
+	// city/country-code/lang-xx.
+	str, err := args.Split(q, 3)
+	if err != nil {
+		return nil, err
+	}
+
 	var (
-		str     = strings.Split(q, "/")
 		country = ""
+		lang    = t.opt.DefaultLang
 	)
 
-	// Is there a /2-letter-country-code?
-	if len(str) == 2 && len(str[1]) == 2 {
+	// Is there a /2-letter-country-code and/or a /lang-xx language?
+	if len(str) > 1 {
 		q = str[0]
-		country = strings.ToUpper(str[1])
+		for _, s := range str[1:] {
+			if l, ok := i18n.ParseLang(s); ok {
+				lang = l
+			} else if len(s) == 2 {
+				country = strings.ToUpper(s)
+			} else {
+				return nil, errors.New("invalid query. Use city/country-code/lang-xx.")
+			}
+		}
 	}
+	// The original case is used to detect airport codes, eg: LHR.
+	name := q
 	q = strings.ToLower(q)
 
-	locs := t.geo.Query(q)
+	// Pick a random city for discovery.
+	if q == "random" {
+		if q = t.geo.Random(); q == "" {
+			return nil, errcode.New(errcode.Unavailable, "no cities available.")
+		}
+		name = q
+	}
+
+	locs := t.geo.Lookup(name)
 	if locs == nil {
-		return nil, errors.New("unknown city.")
+		return nil, errcode.New(errcode.NotFound, "unknown city or airport code.")
 	}
 
 	out := make([]string, 0, len(locs))
 	for _, l := range locs {
+		// Stop if the query's deadline has been hit.
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		// Filter by country.
 		if country != "" {
 			if l.Country != country {
@@ -59,8 +108,10 @@ func (t *Timezones) Query(q string) ([]string, error) {
 			continue
 		}
 
-		r := fmt.Sprintf("%s 1 TXT \"%s (%s, %s)\" \"%s\"",
-			q, l.Name, l.Timezone, l.Country, time.Now().In(zone).Format(time.RFC1123Z))
+		// RFC1123Z with the localized day name, and a friendly phrase.
+		t := time.Now().In(zone)
+		r := fmt.Sprintf("%s 1 TXT \"%s (%s, %s)\" \"%s\" \"%s\"",
+			q, l.Name, l.Timezone, l.Country, i18n.Weekday(lang, t.Weekday())+t.Format(", 02 Jan 2006 15:04:05 -0700"), friendly(t))
 
 		out = append(out, r)
 	}
@@ -68,7 +119,110 @@ func (t *Timezones) Query(q string) ([]string, error) {
 	return out, nil
 }
 
+// convert converts a time in one city to another city's time.
+// Format: $time-$city-in-$city, eg: 3pm-london-in-tokyo, 15:30-paris-in-mumbai.
+func (t *Timezones) convert(q string) ([]string, error) {
+	parts := strings.SplitN(strings.ToLower(q), convSep, 2)
+	from := strings.SplitN(parts[0], "-", 2)
+	if len(from) != 2 || from[1] == "" || parts[1] == "" {
+		return nil, errors.New("invalid query. Use time-city-in-city. eg: 3pm-london-in-tokyo")
+	}
+
+	hour, min, err := parseClock(from[0])
+	if err != nil {
+		return nil, err
+	}
+
+	src, srcZone, err := t.lookup(from[1])
+	if err != nil {
+		return nil, err
+	}
+	dst, dstZone, err := t.lookup(parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	// The given time is assumed to be today in the source city.
+	var (
+		now = time.Now().In(srcZone)
+		a   = time.Date(now.Year(), now.Month(), now.Day(), hour, min, 0, 0, srcZone)
+		b   = a.In(dstZone)
+	)
+
+	r := fmt.Sprintf("%s 1 TXT \"%s (%s, %s)\" \"%s\" \"%s (%s, %s)\" \"%s%s\"",
+		q, src.Name, src.Timezone, src.Country, friendly(a),
+		dst.Name, dst.Timezone, dst.Country, friendly(b), dayShift(a, b))
+
+	return []string{r}, nil
+}
+
+// lookup returns the first (most populous) location for a city name.
+func (t *Timezones) lookup(city string) (geo.Location, *time.Location, error) {
+	locs := t.geo.Query(city)
+	for _, l := range locs {
+		zone, err := time.LoadLocation(l.Timezone)
+		if err != nil {
+			continue
+		}
+
+		return l, zone, nil
+	}
+
+	return geo.Location{}, nil, errcode.Errorf(errcode.NotFound, "unknown city: %s.", city)
+}
+
 // Dump produces a gob dump of the cached data.
 func (t *Timezones) Dump() ([]byte, error) {
 	return nil, nil
 }
+
+// friendly returns a relative phrase for a time with the part of the day,
+// eg: 3:45 PM (afternoon).
+func friendly(t time.Time) string {
+	return fmt.Sprintf("%s (%s)", t.Format("3:04 PM"), daypart(t.Hour()))
+}
+
+// daypart returns the part of the day for an hour.
+func daypart(h int) string {
+	switch {
+	case h >= 5 && h < 12:
+		return "morning"
+	case h >= 12 && h < 17:
+		return "afternoon"
+	case h >= 17 && h < 21:
+		return "evening"
+	default:
+		return "night"
+	}
+}
+
+// dayShift returns whether the converted time b falls on the previous
+// or the next day relative to a.
+func dayShift(a, b time.Time) string {
+	var (
+		y1, m1, d1 = a.Date()
+		y2, m2, d2 = b.Date()
+		da         = time.Date(y1, m1, d1, 0, 0, 0, 0, time.UTC)
+		db         = time.Date(y2, m2, d2, 0, 0, 0, 0, time.UTC)
+	)
+
+	switch {
+	case db.After(da):
+		return ", next day"
+	case db.Before(da):
+		return ", previous day"
+	}
+
+	return ""
+}
+
+// parseClock parses a time of the day, eg: 3pm, 3:45pm, 15:30, 1530.
+func parseClock(s string) (int, int, error) {
+	for _, f := range clockFormats {
+		if t, err := time.Parse(f, s); err == nil {
+			return t.Hour(), t.Minute(), nil
+		}
+	}
+
+	return 0, 0, errors.New("invalid time. Use 3pm, 3:45pm, or 15:30.")
+}
@@ -0,0 +1,105 @@
+// package tip calculates tips and splits bills.
+package tip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/knadh/dns.toys/internal/args"
+	"github.com/knadh/dns.toys/internal/numfmt"
+)
+
+const (
+	maxAmount = 1e9
+	maxPeople = 1000
+)
+
+// Tip calculates tips and bill splits.
+type Tip struct {
+	opt Opt
+}
+
+// Opt contains config options for Tip.
+type Opt struct {
+	// Format for the numbers in the output.
+	NumberFormat numfmt.Format
+
+	// Splits the query into its parts. Defaults to args.Sep.
+	Args args.Splitter
+}
+
+// New returns a new instance of Tip.
+func New(o Opt) *Tip {
+	return &Tip{
+		opt: o,
+	}
+}
+
+// Query calculates the tip on a bill and splits the total.
+// Format: $amount/$tipPercent/$people, eg: 85.50/18/4. People is optional.
+func (t *Tip) Query(ctx context.Context, q string) ([]string, error) {
+	str, err := t.opt.Args.Split(q, 3)
+	if err != nil || len(str) < 2 {
+		return nil, errors.New(t.opt.Args.Example("invalid tip query. Use amount/tip%/people. eg: 85.50/18/4"))
+	}
+
+	amount, err := strconv.ParseFloat(str[0], 64)
+	if err != nil || amount <= 0 || amount > maxAmount {
+		return nil, errors.New("invalid amount.")
+	}
+
+	pct, err := strconv.ParseFloat(str[1], 64)
+	if err != nil || pct < 0 || pct > 100 {
+		return nil, errors.New("invalid tip percentage. Should be between 0 and 100.")
+	}
+
+	people := 1
+	if len(str) == 3 {
+		p, err := strconv.Atoi(str[2])
+		if err != nil || p < 1 || p > maxPeople {
+			return nil, fmt.Errorf("invalid number of people. Should be between 1 and %d.", maxPeople)
+		}
+		people = p
+	}
+
+	// Do all the math in cents to avoid rounding errors.
+	var (
+		bill  = int64(math.Round(amount * 100))
+		tip   = int64(math.Round(float64(bill) * pct / 100))
+		total = bill + tip
+
+		// Distribute the remainder cents one each to the first few people
+		// so that the shares add up to the total.
+		share = total / int64(people)
+		rem   = total % int64(people)
+	)
+
+	out := []string{
+		fmt.Sprintf("%s 1 TXT \"tip (%s%%) = %s\"", q, str[1], t.cents(tip)),
+		fmt.Sprintf("%s 1 TXT \"total = %s\"", q, t.cents(total)),
+	}
+
+	if people > 1 {
+		if rem == 0 {
+			out = append(out, fmt.Sprintf("%s 1 TXT \"per person = %d x %s\"", q, people, t.cents(share)))
+		} else {
+			out = append(out, fmt.Sprintf("%s 1 TXT \"per person = %d x %s, %d x %s\"",
+				q, rem, t.cents(share+1), int64(people)-rem, t.cents(share)))
+		}
+	}
+
+	return out, nil
+}
+
+// Dump is not implemented in this package.
+func (t *Tip) Dump() ([]byte, error) {
+	return nil, nil
+}
+
+// cents formats cents as a decimal amount.
+func (t *Tip) cents(c int64) string {
+	return t.opt.NumberFormat.Float(float64(c)/100, 2)
+}
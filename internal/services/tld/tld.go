@@ -0,0 +1,66 @@
+// package tld returns the type and the sponsor (or country) of top-level
+// domains from an embedded subset of the IANA root zone database.
+package tld
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/knadh/dns.toys/internal/errcode"
+)
+
+//go:embed tlds.json
+var dataB []byte
+
+type tld struct {
+	// ccTLD, gTLD, sTLD, or infrastructure.
+	Type string `json:"type"`
+
+	// The sponsoring organisation or the country for ccTLDs.
+	Sponsor string `json:"sponsor"`
+}
+
+// TLD looks up top-level domains.
+type TLD struct {
+	// Lowercase TLD => info.
+	data map[string]tld
+}
+
+// New returns a new instance of TLD.
+func New() (*TLD, error) {
+	t := &TLD{}
+	if err := json.Unmarshal(dataB, &t.data); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Query returns the type and the sponsor of a TLD, eg: io, com.
+// For names with multiple labels, eg: example.co.uk, the last one is used.
+func (t *TLD) Query(ctx context.Context, q string) ([]string, error) {
+	name := strings.ToLower(strings.Trim(q, "."))
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	if name == "" {
+		return nil, errors.New("invalid tld.")
+	}
+
+	d, ok := t.data[name]
+	if !ok {
+		return nil, errcode.New(errcode.NotFound, "unknown TLD.")
+	}
+
+	r := fmt.Sprintf("%s 1 TXT \"%s\" \"%s\" \"%s\"", q, name, d.Type, strings.ReplaceAll(d.Sponsor, "\"", ""))
+	return []string{r}, nil
+}
+
+// Dump is not implemented in this package.
+func (t *TLD) Dump() ([]byte, error) {
+	return nil, nil
+}
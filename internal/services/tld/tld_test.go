@@ -0,0 +1,43 @@
+package tld
+
+import (
+	"context"
+	"testing"
+
+	"github.com/knadh/dns.toys/internal/errcode"
+)
+
+func TestQuery(t *testing.T) {
+	tl, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		q   string
+		out string
+	}{
+		{"io", `io 1 TXT "io" "ccTLD" "British Indian Ocean Territory"`},
+		{"com", `com 1 TXT "com" "gTLD" "VeriSign Global Registry Services"`},
+		{"DE", `DE 1 TXT "de" "ccTLD" "Germany"`},
+		{"museum", `museum 1 TXT "museum" "sTLD" "Museum Domain Management Association"`},
+		{"arpa", `arpa 1 TXT "arpa" "infrastructure" "Internet Architecture Board (IAB)"`},
+		{"example.co.uk", `example.co.uk 1 TXT "uk" "ccTLD" "Britain (UK)"`},
+	}
+	for _, tc := range tests {
+		out, err := tl.Query(context.Background(), tc.q)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.q, err)
+		}
+		if len(out) != 1 || out[0] != tc.out {
+			t.Fatalf("%s: expected %s, got %v", tc.q, tc.out, out)
+		}
+	}
+
+	// Misses.
+	for q, code := range map[string]errcode.Code{"notatld": errcode.NotFound, "": errcode.Invalid, ".": errcode.Invalid} {
+		if _, err := tl.Query(context.Background(), q); errcode.Of(err) != code {
+			t.Fatalf("%q: expected %s, got %v", q, code, err)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package units
 
 import (
+	"context"
 	_ "embed"
 	"encoding/json"
 	"errors"
@@ -9,6 +10,8 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+
+	"github.com/knadh/dns.toys/internal/errcode"
 )
 
 type fileData struct {
@@ -62,7 +65,7 @@ func New() (*Units, error) {
 }
 
 // Query parses a unit conversion string and returns the results.
-func (u *Units) Query(q string) ([]string, error) {
+func (u *Units) Query(ctx context.Context, q string) ([]string, error) {
 	if q == "unit." {
 		return u.help, nil
 	}
@@ -90,7 +93,7 @@ func (u *Units) Query(q string) ([]string, error) {
 		f := strings.ToLower(fromSym)
 		lg, ok := u.symbols[f]
 		if !ok {
-			return nil, fmt.Errorf("unknown unit: %v. 'dig unit' to see list of units.", fromSym)
+			return nil, errcode.Errorf(errcode.NotFound, "unknown unit: %v. 'dig unit' to see list of units.", fromSym)
 		}
 
 		g = lg
@@ -106,7 +109,7 @@ func (u *Units) Query(q string) ([]string, error) {
 		f := strings.ToLower(toSym)
 		lg, ok := u.symbols[f]
 		if !ok {
-			return nil, fmt.Errorf("unknown unit: %v. 'dig unit' to see list of units.", toSym)
+			return nil, errcode.Errorf(errcode.NotFound, "unknown unit: %v. 'dig unit' to see list of units.", toSym)
 		}
 
 		toG = lg
@@ -133,6 +136,16 @@ func (u *Units) Query(q string) ([]string, error) {
 	return []string{r}, nil
 }
 
+// Knows checks if a unit symbol is known, eg: km.
+func (u *Units) Knows(sym string) bool {
+	if _, ok := u.symbols[sym]; ok {
+		return true
+	}
+
+	_, ok := u.symbols[strings.ToLower(sym)]
+	return ok
+}
+
 // Dump is not implemented in this package.
 func (u *Units) Dump() ([]byte, error) {
 	return nil, nil
@@ -0,0 +1,215 @@
+package weather
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/knadh/dns.toys/internal/i18n"
+)
+
+// Default English descriptions for the yr.no weather symbol codes.
+// https://api.met.no/weatherapi/weathericon/2.0/documentation
+var defaultConditions = map[string]string{
+	"clearsky":                     "clear sky",
+	"fair":                         "fair",
+	"partlycloudy":                 "partly cloudy",
+	"cloudy":                       "cloudy",
+	"fog":                          "fog",
+	"lightrain":                    "light rain",
+	"rain":                         "rain",
+	"heavyrain":                    "heavy rain",
+	"lightrainshowers":             "light rain showers",
+	"rainshowers":                  "rain showers",
+	"heavyrainshowers":             "heavy rain showers",
+	"lightrainshowersandthunder":   "light rain showers and thunder",
+	"rainshowersandthunder":        "rain showers and thunder",
+	"heavyrainshowersandthunder":   "heavy rain showers and thunder",
+	"lightrainandthunder":          "light rain and thunder",
+	"rainandthunder":               "rain and thunder",
+	"heavyrainandthunder":          "heavy rain and thunder",
+	"lightsleet":                   "light sleet",
+	"sleet":                        "sleet",
+	"heavysleet":                   "heavy sleet",
+	"lightsleetshowers":            "light sleet showers",
+	"sleetshowers":                 "sleet showers",
+	"heavysleetshowers":            "heavy sleet showers",
+	"lightsleetandthunder":         "light sleet and thunder",
+	"sleetandthunder":              "sleet and thunder",
+	"heavysleetandthunder":         "heavy sleet and thunder",
+	"lightssleetshowersandthunder": "light sleet showers and thunder",
+	"sleetshowersandthunder":       "sleet showers and thunder",
+	"heavysleetshowersandthunder":  "heavy sleet showers and thunder",
+	"lightsnow":                    "light snow",
+	"snow":                         "snow",
+	"heavysnow":                    "heavy snow",
+	"lightsnowshowers":             "light snow showers",
+	"snowshowers":                  "snow showers",
+	"heavysnowshowers":             "heavy snow showers",
+	"lightsnowandthunder":          "light snow and thunder",
+	"snowandthunder":               "snow and thunder",
+	"heavysnowandthunder":          "heavy snow and thunder",
+	"lightssnowshowersandthunder":  "light snow showers and thunder",
+	"snowshowersandthunder":        "snow showers and thunder",
+	"heavysnowshowersandthunder":   "heavy snow showers and thunder",
+}
+
+// condition returns the description for a weather symbol code, eg:
+// partlycloudy_day in the given language. The _day/_night/_polartwilight
+// variants are looked up without the suffix. Custom descriptions from the
+// config are used in all languages. Unknown codes are returned as-is.
+func (w *Weather) condition(code, lang string) string {
+	if c, ok := lookupCode(w.opt.Conditions, code); ok {
+		return c
+	}
+
+	if lang != i18n.Default {
+		if c, ok := translate(code, lang); ok {
+			return c
+		}
+	}
+
+	if c, ok := lookupCode(w.conditions, code); ok {
+		return c
+	}
+
+	return code
+}
+
+// lookupCode looks up a weather symbol code, and then the code without
+// the _day/_night/_polartwilight suffix, in a code => description map.
+func lookupCode(m map[string]string, code string) (string, bool) {
+	if c, ok := m[code]; ok {
+		return c, true
+	}
+
+	c, ok := m[strings.Split(code, "_")[0]]
+	return c, ok
+}
+
+// validCondition checks if a custom description can be used as is
+// in a TXT record string.
+func validCondition(c string) error {
+	if c == "" || len(c) > 255 {
+		return errors.New("should be 1 - 255 chars")
+	}
+	if strings.ContainsAny(c, "\"\\\n\r") {
+		return errors.New("should not have quotes, backslashes, or line breaks")
+	}
+
+	return nil
+}
+
+// Translated descriptions for the weather symbol codes by language. The
+// "andthunder" codes are translated by their base code with the thunder
+// suffix appended.
+var translations = map[string]map[string]string{
+	"de": {
+		"clearsky":          "klar",
+		"fair":              "heiter",
+		"partlycloudy":      "teilweise bewölkt",
+		"cloudy":            "bewölkt",
+		"fog":               "Nebel",
+		"lightrain":         "leichter Regen",
+		"rain":              "Regen",
+		"heavyrain":         "starker Regen",
+		"lightrainshowers":  "leichte Regenschauer",
+		"rainshowers":       "Regenschauer",
+		"heavyrainshowers":  "starke Regenschauer",
+		"lightsleet":        "leichter Schneeregen",
+		"sleet":             "Schneeregen",
+		"heavysleet":        "starker Schneeregen",
+		"lightsleetshowers": "leichte Schneeregenschauer",
+		"sleetshowers":      "Schneeregenschauer",
+		"heavysleetshowers": "starke Schneeregenschauer",
+		"lightsnow":         "leichter Schneefall",
+		"snow":              "Schneefall",
+		"heavysnow":         "starker Schneefall",
+		"lightsnowshowers":  "leichte Schneeschauer",
+		"snowshowers":       "Schneeschauer",
+		"heavysnowshowers":  "starke Schneeschauer",
+	},
+	"fr": {
+		"clearsky":          "ciel dégagé",
+		"fair":              "beau temps",
+		"partlycloudy":      "partiellement nuageux",
+		"cloudy":            "nuageux",
+		"fog":               "brouillard",
+		"lightrain":         "pluie faible",
+		"rain":              "pluie",
+		"heavyrain":         "forte pluie",
+		"lightrainshowers":  "faibles averses de pluie",
+		"rainshowers":       "averses de pluie",
+		"heavyrainshowers":  "fortes averses de pluie",
+		"lightsleet":        "faible neige fondue",
+		"sleet":             "neige fondue",
+		"heavysleet":        "forte neige fondue",
+		"lightsleetshowers": "faibles averses de neige fondue",
+		"sleetshowers":      "averses de neige fondue",
+		"heavysleetshowers": "fortes averses de neige fondue",
+		"lightsnow":         "faible neige",
+		"snow":              "neige",
+		"heavysnow":         "forte neige",
+		"lightsnowshowers":  "faibles averses de neige",
+		"snowshowers":       "averses de neige",
+		"heavysnowshowers":  "fortes averses de neige",
+	},
+	"es": {
+		"clearsky":          "despejado",
+		"fair":              "buen tiempo",
+		"partlycloudy":      "parcialmente nublado",
+		"cloudy":            "nublado",
+		"fog":               "niebla",
+		"lightrain":         "lluvia ligera",
+		"rain":              "lluvia",
+		"heavyrain":         "lluvia fuerte",
+		"lightrainshowers":  "chubascos ligeros",
+		"rainshowers":       "chubascos",
+		"heavyrainshowers":  "chubascos fuertes",
+		"lightsleet":        "aguanieve ligera",
+		"sleet":             "aguanieve",
+		"heavysleet":        "aguanieve fuerte",
+		"lightsleetshowers": "chubascos ligeros de aguanieve",
+		"sleetshowers":      "chubascos de aguanieve",
+		"heavysleetshowers": "chubascos fuertes de aguanieve",
+		"lightsnow":         "nieve ligera",
+		"snow":              "nieve",
+		"heavysnow":         "nieve fuerte",
+		"lightsnowshowers":  "chubascos ligeros de nieve",
+		"snowshowers":       "chubascos de nieve",
+		"heavysnowshowers":  "chubascos fuertes de nieve",
+	},
+}
+
+var thunder = map[string]string{
+	"de": " und Gewitter",
+	"fr": " et orage",
+	"es": " con tormenta",
+}
+
+// translate returns the description of a weather symbol code in the given
+// language. It returns false if there's no translation.
+func translate(code, lang string) (string, bool) {
+	t, ok := translations[lang]
+	if !ok {
+		return "", false
+	}
+
+	var (
+		base   = strings.Split(code, "_")[0]
+		suffix = ""
+	)
+	if strings.HasSuffix(base, "andthunder") {
+		base = strings.TrimSuffix(base, "andthunder")
+		suffix = thunder[lang]
+	}
+
+	// Fix the typos in some of the upstream codes. eg: lightssnowshowers.
+	base = strings.Replace(base, "lightss", "lights", 1)
+
+	c, ok := t[base]
+	if !ok {
+		return "", false
+	}
+
+	return c + suffix, true
+}
@@ -0,0 +1,54 @@
+package weather
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewConditions(t *testing.T) {
+	tests := []struct {
+		desc string
+		ok   bool
+	}{
+		{"sunny", true},
+		{"sunny, \"warm\"", false},
+		{"back\\slash", false},
+		{"two\nlines", false},
+		{"", false},
+		{strings.Repeat("a", 256), false},
+	}
+
+	for _, tc := range tests {
+		_, err := New(Opt{Conditions: map[string]string{"clearsky": tc.desc}}, nil)
+		if tc.ok && err != nil {
+			t.Fatalf("%q: unexpected error: %v", tc.desc, err)
+		}
+		if !tc.ok && err == nil {
+			t.Fatalf("%q: expected an error", tc.desc)
+		}
+	}
+}
+
+func TestCondition(t *testing.T) {
+	w, err := New(Opt{Conditions: map[string]string{"clearsky": "sunny"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		code, lang, out string
+	}{
+		{"clearsky_day", "en", "sunny"},
+		{"clearsky_day", "de", "sunny"},
+		{"cloudy", "en", "cloudy"},
+		{"cloudy", "de", "bewölkt"},
+		{"rain_night", "en", "rain"},
+		{"unknowncode", "de", "unknowncode"},
+	}
+
+	for _, tc := range tests {
+		if out := w.condition(tc.code, tc.lang); out != tc.out {
+			t.Fatalf("%s (%s): expected %q, got %q", tc.code, tc.lang, tc.out, out)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/geo"
+)
+
+// Cached geocoding results for names that aren't found are retried sooner
+// as the API's data may change.
+const geocodeMissTTL = time.Hour
+
+// geocode is a cached geocoding result. Loc is nil for unknown names.
+type geocode struct {
+	Loc       *geo.Location
+	ExpiresAt time.Time
+}
+
+// Expiry returns the result's expiry time for the cache.
+func (g geocode) Expiry() time.Time {
+	return g.ExpiresAt
+}
+
+// Open-Meteo compatible geocoding API response.
+type geocodeData struct {
+	Results []struct {
+		ID         int64   `json:"id"`
+		Name       string  `json:"name"`
+		Lat        float64 `json:"latitude"`
+		Lon        float64 `json:"longitude"`
+		Country    string  `json:"country_code"`
+		Timezone   string  `json:"timezone"`
+		Population int     `json:"population"`
+	} `json:"results"`
+}
+
+// geocodeCity resolves a city that isn't in the local geo dataset using the
+// geocoding API. The results are cached separately from the forecasts for
+// long, as coordinates don't change. It returns nil for unknown names.
+func (w *Weather) geocodeCity(ctx context.Context, name string) (*geo.Location, error) {
+	name = strings.ToLower(name)
+
+	if v, ok := w.geocodes.Get(name); ok && v.Expiry().After(time.Now()) {
+		w.debug("geocode cache hit: %s", name)
+		return v.(geocode).Loc, nil
+	}
+	w.debug("geocode cache miss: %s", name)
+
+	loc, err := w.fetchGeocode(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	g := geocode{Loc: loc, ExpiresAt: time.Now().Add(w.opt.GeocodeTTL)}
+	if loc == nil {
+		g.ExpiresAt = time.Now().Add(geocodeMissTTL)
+	}
+
+	w.geocodes.Set(name, g)
+
+	return loc, nil
+}
+
+func (w *Weather) fetchGeocode(ctx context.Context, name string) (*geo.Location, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(w.opt.GeocodeURL, url.QueryEscape(name)), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("User-Agent", w.opt.UserAgent)
+
+	w.debug("geocode request: %s", req.URL)
+	r, err := w.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("geocode request failed: %v", r.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var data geocodeData
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, err
+	}
+
+	if len(data.Results) == 0 || data.Results[0].Timezone == "" {
+		return nil, nil
+	}
+
+	// The IDs are prefixed to not collide with the local dataset's in the
+	// forecast cache.
+	d := data.Results[0]
+	return &geo.Location{
+		ID:         "geocode:" + strconv.FormatInt(d.ID, 10),
+		Name:       d.Name,
+		Lat:        d.Lat,
+		Lon:        d.Lon,
+		Country:    d.Country,
+		Timezone:   d.Timezone,
+		Population: d.Population,
+	}, nil
+}
@@ -0,0 +1,99 @@
+package weather
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGeocodeCache(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		reqs = map[string]int{}
+		fail = false
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("name")
+
+		mu.Lock()
+		reqs[name]++
+		f := fail
+		mu.Unlock()
+
+		switch {
+		case f:
+			w.WriteHeader(http.StatusInternalServerError)
+		case name == "springfield":
+			w.Write([]byte(`{"results": [{"id": 42, "name": "Springfield", "latitude": 39.8, "longitude": -89.6,
+				"country_code": "US", "timezone": "America/Chicago", "population": 100000}]}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+
+	w, err := New(Opt{BaseURL: srv.URL, GeocodeURL: srv.URL + "/?name=%s", GeocodeTTL: time.Hour,
+		CacheTTL: time.Hour, ReqTimeout: time.Second, MaxEntries: 5, ForecastInterval: time.Hour}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	count := func(name string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return reqs[name]
+	}
+
+	// Known and unknown names are looked up once and then served from the cache.
+	tests := []struct {
+		name  string
+		found bool
+	}{
+		{"springfield", true},
+		{"Springfield", true},
+		{"SPRINGFIELD", true},
+		{"atlantis", false},
+		{"Atlantis", false},
+	}
+	for _, tc := range tests {
+		l, err := w.geocodeCity(context.Background(), tc.name)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		if found := l != nil; found != tc.found {
+			t.Fatalf("%s: expected found=%v, got %v", tc.name, tc.found, found)
+		}
+		if l != nil && (l.ID != "geocode:42" || l.Timezone != "America/Chicago") {
+			t.Fatalf("%s: unexpected location: %+v", tc.name, l)
+		}
+	}
+	if c := count("springfield"); c != 1 {
+		t.Fatalf("expected 1 geocode request for springfield, got %d", c)
+	}
+	if c := count("atlantis"); c != 1 {
+		t.Fatalf("expected 1 geocode request for atlantis, got %d", c)
+	}
+
+	// The geocodes are cached separately from the forecasts.
+	if w.data.Len() != 0 {
+		t.Fatalf("expected no forecast cache entries, got %d", w.data.Len())
+	}
+
+	// Expired results are looked up again, and errors aren't cached.
+	w.geocodes.Set("springfield", geocode{ExpiresAt: time.Now().Add(-time.Second)})
+	mu.Lock()
+	fail = true
+	mu.Unlock()
+	for i := 0; i < 2; i++ {
+		if _, err := w.geocodeCity(context.Background(), "springfield"); err == nil {
+			t.Fatal("expected an error for a failed geocode request")
+		}
+	}
+	if c := count("springfield"); c != 3 {
+		t.Fatalf("expected failed geocode requests to be retried, got %d requests", c)
+	}
+}
@@ -0,0 +1,100 @@
+package weather
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/geo"
+)
+
+// Max concurrent upstream fetches while warming up the cache.
+const warmupConcurrency = 4
+
+// Warmup fetches and caches the forecasts for a list of cities, eg: popular
+// ones, so that their first queries don't wait on the upstream. Like queries,
+// each city warms up its top 3 locations. Locations that are already cached,
+// eg: from a snapshot, are skipped, and failures are logged and ignored.
+// It blocks until all the fetches are done or the service is stopped.
+func (w *Weather) Warmup(cities []string) {
+	var (
+		locs []geo.Location
+
+		// Cities may be listed more than once or share locations.
+		seen = map[string]bool{}
+	)
+	for _, c := range cities {
+		l := w.geo.Lookup(c)
+		if l == nil {
+			log.Printf("weather warmup: unknown city: %s", c)
+			continue
+		}
+		if len(l) > 3 {
+			l = l[:3]
+		}
+
+		for _, loc := range l {
+			if !seen[loc.ID] {
+				seen[loc.ID] = true
+				locs = append(locs, loc)
+			}
+		}
+	}
+
+	var (
+		start   = time.Now()
+		sem     = make(chan struct{}, warmupConcurrency)
+		wg      sync.WaitGroup
+		mut     sync.Mutex
+		fetched = 0
+		cached  = 0
+	)
+	for _, l := range locs {
+		if w.ctx.Err() != nil {
+			break
+		}
+
+		e, ok := w.cached(l.ID)
+		if ok && e.Valid && e.ExpiresAt.After(time.Now()) {
+			cached++
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(l geo.Location) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			// Share the upstream's rate limit with the queries.
+			if err := w.limiter.Wait(w.ctx); err != nil {
+				return
+			}
+
+			res, err := w.fetchAPI(w.ctx, l.Lat, l.Lon)
+			if err != nil {
+				if w.ctx.Err() == nil {
+					log.Printf("weather warmup: error fetching %s (%s): %v", l.Name, l.Country, err)
+				}
+				return
+			}
+
+			w.data.Set(l.ID, res)
+
+			mut.Lock()
+			fetched++
+			mut.Unlock()
+		}(l)
+	}
+	wg.Wait()
+
+	if w.ctx.Err() != nil {
+		log.Printf("weather warmup: stopped. %d of %d locations cached", cached+fetched, len(locs))
+		return
+	}
+
+	log.Printf("weather warmup: %d of %d locations cached (%d fetched, %d failed) in %v",
+		cached+fetched, len(locs), fetched, len(locs)-cached-fetched, time.Since(start).Round(time.Millisecond))
+}
@@ -0,0 +1,84 @@
+package weather
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/geo"
+)
+
+// Rows in the geonames.org format. London has two locations.
+var testCities = []string{
+	"1\tLondon\tLondon\t\t51.50\t-0.12\t\t\tGB\t\t\t\t\t\t8000000\t\t\tEurope/London\t",
+	"2\tLondon\tLondon\t\t42.98\t-81.24\t\t\tCA\t\t\t\t\t\t400000\t\t\tAmerica/Toronto\t",
+	"3\tParis\tParis\t\t48.85\t2.35\t\t\tFR\t\t\t\t\t\t2000000\t\t\tEurope/Paris\t",
+}
+
+// newWarmupTest returns a Weather with a test geo dataset and API server
+// that counts the requests.
+func newWarmupTest(t *testing.T) (*Weather, *int32) {
+	fPath := filepath.Join(t.TempDir(), "cities.txt")
+	if err := ioutil.WriteFile(fPath, []byte(strings.Join(testCities, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	g, err := geo.New(fPath, geo.DefaultColumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv, n, _ := newAPI(t, apiFixture(1, `"air_temperature": 20`))
+
+	w, err := New(Opt{BaseURL: srv.URL, CacheTTL: time.Hour, ReqTimeout: time.Second, MaxEntries: 1}, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(w.Stop)
+
+	return w, n
+}
+
+func TestWarmup(t *testing.T) {
+	w, n := newWarmupTest(t)
+
+	// Duplicate and unknown cities are skipped.
+	w.Warmup([]string{"london", "London", "paris", "atlantis"})
+
+	if c := atomic.LoadInt32(n); c != 3 {
+		t.Fatalf("expected 3 API requests for the unique locations, got %d", c)
+	}
+	for _, id := range []string{"1", "2", "3"} {
+		if e, ok := w.cached(id); !ok || !e.Valid {
+			t.Fatalf("location %s wasn't cached", id)
+		}
+	}
+
+	// Cached locations aren't fetched again.
+	w.Warmup([]string{"paris"})
+	if c := atomic.LoadInt32(n); c != 3 {
+		t.Fatalf("expected no API requests for cached locations, got %d", c-3)
+	}
+}
+
+func TestWarmupStopped(t *testing.T) {
+	w, n := newWarmupTest(t)
+	w.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		w.Warmup([]string{"london", "paris"})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("warmup didn't return after the service was stopped")
+	}
+	if c := atomic.LoadInt32(n); c != 0 {
+		t.Fatalf("expected no API requests after stopping, got %d", c)
+	}
+}
@@ -3,6 +3,7 @@ package weather
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/gob"
 	"encoding/json"
 	"errors"
@@ -10,22 +11,35 @@ import (
 	"io"
 	"io/ioutil"
 	"log"
+	"math"
 	"net/http"
 	"strings"
-	"sync"
 	"time"
 
+	"github.com/knadh/dns.toys/internal/args"
+	"github.com/knadh/dns.toys/internal/cache"
+	"github.com/knadh/dns.toys/internal/errcode"
+	"github.com/knadh/dns.toys/internal/extra"
 	"github.com/knadh/dns.toys/internal/geo"
+	"github.com/knadh/dns.toys/internal/i18n"
 	"golang.org/x/time/rate"
 )
 
 const (
-	apiURL = "https://api.met.no/weatherapi/locationforecast/2.0/compact?lat=%0.5f&lon=%0.5f"
+	// Default API base URL to which the lat/lon params are appended.
+	apiURL = "https://api.met.no/weatherapi/locationforecast/2.0/compact"
 
 	// Max requests/sec allowed by the API.
 	apiRateLimit = 15
+
+	// Max cities in a multi-city query, eg: london/paris/rome.
+	maxCities = 5
 )
 
+// Fields are the forecast fields that queries can select, eg: berlin/temp,wind.
+// The location and the time are always included.
+var Fields = []string{"temp", "feels", "humidity", "uv", "wind", "condition"}
+
 type entry struct {
 	Forecasts []forecast
 	Location  string
@@ -33,6 +47,17 @@ type entry struct {
 	Lat, Lon  float32
 	ExpiresAt time.Time
 	Valid     bool
+
+	// Number of forecasts (of MaxEntries) that the API had no data for.
+	Missing int
+
+	// Time of the last upstream fetch, successful or not.
+	FetchedAt time.Time
+}
+
+// Expiry returns the entry's expiry time for the cache.
+func (e entry) Expiry() time.Time {
+	return e.ExpiresAt
 }
 
 type forecast struct {
@@ -40,6 +65,16 @@ type forecast struct {
 	TempC, TempF float32
 	Humidity     float32
 
+	// Wind speed in m/s and the direction it's coming from in degrees.
+	WindSpeed float32
+	WindDir   float32
+
+	// Apparent temperature.
+	FeelsC float32
+
+	// UV index, if the API returns it.
+	UV *float32
+
 	// English weather descriptions.
 	Forecast1H string
 }
@@ -54,9 +89,11 @@ type apiData struct {
 			Data struct {
 				Instant struct {
 					Details struct {
-						AirTemperature   float32 `json:"air_temperature"`
-						RelativeHumidity float32 `json:"relative_humidity"`
-						WindSpeed        float32 `json:"wind_speed"`
+						AirTemperature   *float32 `json:"air_temperature"`
+						RelativeHumidity float32  `json:"relative_humidity"`
+						WindSpeed        float32  `json:"wind_speed"`
+						WindFromDir      float32  `json:"wind_from_direction"`
+						UV               *float32 `json:"ultraviolet_index_clear_sky"`
 					} `json:"details"`
 				} `json:"instant"`
 				Next12Hours struct {
@@ -81,23 +118,71 @@ type apiData struct {
 
 // Opt contains config options for Weather.
 type Opt struct {
+	// API base URL, eg: for a self-hosted mirror. The API should respond
+	// with yr.no's locationforecast format. Defaults to yr.no.
+	BaseURL string
+
 	ForecastInterval time.Duration
 	MaxEntries       int
 
+	// Respond with a single summary record (high, low, condition)
+	// instead of the forecasts. Queries can ask for it with /summary.
+	SummaryOnly bool
+
+	// Max number of locations to cache. 0 for no limit.
+	CacheSize  int
 	CacheTTL   time.Duration
 	ReqTimeout time.Duration
 	UserAgent  string
+
+	// Min time between upstream fetches for the same location. Expired
+	// data is served in between. 0 for no limit.
+	MinFetchInterval time.Duration
+
+	// Max time past the cache TTL that expired data is served for while it's
+	// refreshed in the background (stale-while-revalidate). Older data is
+	// not served. 0 for no limit.
+	StaleGrace time.Duration
+
+	// Optional Open-Meteo compatible geocoding API URL with a %s placeholder
+	// for the city name to look up cities that aren't in the geo dataset.
+	GeocodeURL string
+	GeocodeTTL time.Duration
+
+	// Log upstream requests, cache hits/misses, and timings.
+	Debug bool
+
+	// Default language for the descriptions and day names.
+	DefaultLang string
+
+	// Custom descriptions for weather symbol codes that override
+	// the defaults. eg: {"clearsky": "sunny"}
+	Conditions map[string]string
 }
 
 // Weather fetches weather forecasts for a given geo location.
 type Weather struct {
-	data map[string]entry
+	// Forecasts (entry) keyed by location ID. Entries hold all the forecasts
+	// with language neutral values in both C and F. The per-query variants,
+	// the language, /summary, and the fields, are applied when rendering the
+	// response, so they're not a part of the key.
+	data *cache.Cache
+
+	// Geocoding results (geocode) keyed by city name for cities that aren't
+	// in the geo dataset.
+	geocodes *cache.Cache
 
 	// Queue for defering API fetch requests.
 	fetchQueue chan geo.Location
 
 	limiter *rate.Limiter
-	mut     sync.RWMutex
+
+	// Cancelled on Stop() to stop the background fetches.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// Weather symbol code => description.
+	conditions map[string]string
 
 	opt    Opt
 	geo    *geo.Geo
@@ -106,15 +191,32 @@ type Weather struct {
 
 var errQueued = errors.New("data is queued.")
 
-func New(o Opt, g *geo.Geo) *Weather {
+// New returns a new instance of Weather. It returns an error if the
+// custom condition descriptions are invalid.
+func New(o Opt, g *geo.Geo) (*Weather, error) {
+	for k, v := range o.Conditions {
+		if err := validCondition(v); err != nil {
+			return nil, fmt.Errorf("invalid description for weather condition '%s': %v", k, err)
+		}
+	}
+
+	if o.BaseURL == "" {
+		o.BaseURL = apiURL
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
 	w := &Weather{
-		data:       make(map[string]entry),
+		data:       cache.New(o.CacheSize),
+		geocodes:   cache.New(o.CacheSize),
 		fetchQueue: make(chan geo.Location, 1000),
 
 		// yr.no API request rate limit.
-		limiter: rate.NewLimiter(apiRateLimit, 1),
-		opt:     o,
-		geo:     g,
+		limiter:    rate.NewLimiter(apiRateLimit, 1),
+		ctx:        ctx,
+		cancel:     cancel,
+		opt:        o,
+		geo:        g,
+		conditions: make(map[string]string, len(defaultConditions)),
 		client: &http.Client{
 			Timeout: o.ReqTimeout,
 			Transport: &http.Transport{
@@ -124,32 +226,139 @@ func New(o Opt, g *geo.Geo) *Weather {
 		},
 	}
 
+	// Apply custom condition descriptions over the defaults.
+	for k, v := range defaultConditions {
+		w.conditions[k] = v
+	}
+	for k, v := range o.Conditions {
+		if _, ok := defaultConditions[k]; !ok {
+			log.Printf("unknown weather condition code: %s", k)
+		}
+		w.conditions[k] = v
+	}
+
 	go w.runFetchQueue()
 
-	return w
+	return w, nil
 }
 
-// Query queries the weather for a given location.
-func (w *Weather) Query(q string) ([]string, error) {
+// Query queries the weather for a given location. Multiple cities can be
+// queried at once, eg: london/paris/rome, where each city gets one location
+// and errors for a city are inline records instead of failing the query.
+func (w *Weather) Query(ctx context.Context, q string) ([]string, error) {
+	// city/city.../country-code/lang-xx/summary/fields.
+	str, err := args.Split(q, maxCities+4)
+	if err != nil {
+		return nil, err
+	}
+
 	var (
-		str     = strings.Split(q, "/")
+		cities  []string
 		country = ""
+		lang    = w.opt.DefaultLang
+		summary = w.opt.SummaryOnly
+
+		// Selected fields. nil for all.
+		fields map[string]bool
 	)
 
-	// Is there a /2-letter-country-code?
-	if len(str) == 2 && len(str[1]) == 2 {
-		q = str[0]
-		country = strings.ToUpper(str[1])
+	// Is there a /2-letter-country-code, /lang-xx language, /summary, and/or
+	// a /field,field list? The other parts are cities.
+	cities = append(cities, str[0])
+	for _, s := range str[1:] {
+		if l, ok := i18n.ParseLang(s); ok {
+			lang = l
+		} else if s == "summary" {
+			summary = true
+		} else if f, ok := parseFields(s); ok {
+			fields = f
+		} else if len(s) == 2 {
+			country = strings.ToUpper(s)
+		} else if s != "" {
+			cities = append(cities, s)
+		} else {
+			return nil, errors.New("invalid query. Use city/country-code/lang-xx/summary/fields.")
+		}
+	}
+
+	if len(cities) == 1 {
+		return w.city(ctx, cities[0], country, lang, summary, fields, 3)
 	}
+	if len(cities) > maxCities {
+		return nil, errcode.Errorf(errcode.Limit, "too many cities. Max %d.", maxCities)
+	}
+
+	var out []string
+	for _, c := range cities {
+		r, err := w.city(ctx, c, country, lang, summary, fields, 1)
+		if err != nil {
+			// Stop if the query's deadline has been hit.
+			if ctx.Err() != nil {
+				return nil, err
+			}
+
+			r = []string{fmt.Sprintf("%s 1 TXT \"error: %s %s\"", strings.ToLower(c), errcode.Of(err), err)}
+		}
+		out = append(out, r...)
+	}
+
+	return out, nil
+}
+
+// city returns the weather records for up to max locations matching a
+// city name, airport code, or coordinates. The forecasts have only the
+// selected fields, or all of them if fields is nil.
+func (w *Weather) city(ctx context.Context, q, country, lang string, summary bool, fields map[string]bool, max int) ([]string, error) {
+	// The original case is used to detect airport codes, eg: LHR.
+	name := q
 	q = strings.ToLower(q)
 
-	locs := w.geo.Query(q)
-	if locs == nil {
-		return nil, errors.New("unknown city.")
+	// Pick a random city for discovery.
+	if q == "random" {
+		if q = w.geo.Random(); q == "" {
+			return nil, errcode.New(errcode.Unavailable, "no cities available.")
+		}
+		name = q
+	}
+
+	var locs []geo.Location
+	if geo.IsCoords(q) {
+		// Coordinates (lat,lon) are given instead of a city name.
+		l, err := geo.ParseCoords(q)
+		if err != nil {
+			return nil, err
+		}
+		locs = []geo.Location{l}
+	} else {
+		locs = w.geo.Lookup(name)
+
+		// Look up cities that aren't in the dataset with the geocoding API.
+		if locs == nil && w.opt.GeocodeURL != "" {
+			l, err := w.geocodeCity(ctx, name)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, err
+				}
+				log.Printf("error geocoding city: %v", err)
+				return nil, errcode.New(errcode.Unavailable, "unable to look up the city. Try again later.")
+			}
+			if l != nil {
+				locs = []geo.Location{*l}
+			}
+		}
+		if locs == nil {
+			return nil, errcode.New(errcode.NotFound, "unknown city or airport code.")
+		}
 	}
 
 	out := make([]string, 0, len(locs)*3)
-	for n, l := range locs {
+	found := 0
+	for _, l := range locs {
+		// Stop if the query's deadline has been hit.
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		// Filter by country.
 		if country != "" {
 			if l.Country != country {
@@ -157,7 +366,7 @@ func (w *Weather) Query(q string) ([]string, error) {
 			}
 		}
 
-		data, err := w.get(l)
+		data, hit, err := w.get(l)
 		if err != nil {
 			// Data never existed and has been queued. Show a friendly
 			// message instead of an error.
@@ -174,13 +383,36 @@ func (w *Weather) Query(q string) ([]string, error) {
 			continue
 		}
 
+		if summary {
+			out = append(out, w.summarize(q, l, data.Forecasts, zone, lang))
+		}
 		for _, f := range data.Forecasts {
-			r := fmt.Sprintf("%s 1 TXT \"%s (%s)\" \"%0.2fC (%0.2fF)\" \"%0.2f%% hu.\" \"%s\" \"%s\"",
-				q, l.Name, l.Country, f.TempC, f.TempF, f.Humidity, f.Forecast1H, f.Time.In(zone).Format("15:04, Mon"))
-			out = append(out, r)
+			if summary {
+				break
+			}
+
+			t := f.Time.In(zone)
+			out = append(out, fmt.Sprintf("%s 1 TXT \"%s (%s)\" %s \"%s\"",
+				q, l.Name, l.Country, w.fields(f, fields, lang), t.Format("15:04, ")+i18n.Weekday(lang, t.Weekday())))
 		}
 
-		if n > 2 {
+		if data.Missing > 0 && !summary {
+			out = append(out, fmt.Sprintf("%s 1 TXT \"%s (%s)\" \"forecast unavailable for %d later periods\"",
+				q, l.Name, l.Country, data.Missing))
+		}
+
+		// Show the cache status to debug caching from the client side.
+		if w.opt.Debug {
+			status := "miss"
+			if hit {
+				status = "hit"
+			}
+			out = append(out, extra.Mark(fmt.Sprintf("%s 1 TXT \"debug: cache %s, ttl %s\"",
+				q, status, time.Until(data.ExpiresAt).Round(time.Second))))
+		}
+
+		found++
+		if found >= max {
 			break
 		}
 	}
@@ -188,45 +420,189 @@ func (w *Weather) Query(q string) ([]string, error) {
 	return out, nil
 }
 
+// fields returns the selected fields of a forecast as quoted TXT strings,
+// or all of them if sel is nil.
+func (w *Weather) fields(f forecast, sel map[string]bool, lang string) string {
+	var out []string
+	for _, name := range Fields {
+		if sel != nil && !sel[name] {
+			continue
+		}
+
+		switch name {
+		case "temp":
+			out = append(out, fmt.Sprintf("%0.2fC (%0.2fF)", f.TempC, f.TempF))
+		case "feels":
+			out = append(out, fmt.Sprintf("feels %0.1fC (%0.1fF)", f.FeelsC, f.FeelsC*1.8+32))
+		case "humidity":
+			out = append(out, fmt.Sprintf("%0.2f%% hu.", f.Humidity))
+		case "uv":
+			// UV index is omitted if the API didn't return it.
+			if f.UV != nil {
+				out = append(out, fmt.Sprintf("uv %0.1f", *f.UV))
+			}
+		case "wind":
+			out = append(out, fmt.Sprintf("%0.1fm/s %s (%0.0fdeg)", f.WindSpeed, geo.Compass(float64(f.WindDir)), f.WindDir))
+		case "condition":
+			out = append(out, w.condition(f.Forecast1H, lang))
+		}
+	}
+
+	if len(out) == 0 {
+		return ""
+	}
+
+	return "\"" + strings.Join(out, "\" \"") + "\""
+}
+
+// parseFields parses a field list, eg: temp,wind, and returns whether the
+// string is a field list. Only strings where every item is a known field are
+// field lists, so that other parts with commas, eg: coordinates, aren't.
+func parseFields(s string) (map[string]bool, bool) {
+	out := map[string]bool{}
+	for _, f := range strings.Split(s, ",") {
+		known := false
+		for _, k := range Fields {
+			if f == k {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, false
+		}
+
+		out[f] = true
+	}
+
+	return out, true
+}
+
+// summarize returns a single record with the high, low, and the most
+// frequent condition over the next 24 hours of forecasts.
+func (w *Weather) summarize(q string, l geo.Location, fc []forecast, zone *time.Location, lang string) string {
+	var (
+		high, low = fc[0].TempC, fc[0].TempC
+		counts    = map[string]int{}
+		cond      = fc[0].Forecast1H
+		end       = fc[0].Time.Add(time.Hour * 24)
+	)
+	for _, f := range fc {
+		if !f.Time.Before(end) {
+			break
+		}
+
+		if f.TempC > high {
+			high = f.TempC
+		}
+		if f.TempC < low {
+			low = f.TempC
+		}
+		counts[f.Forecast1H]++
+	}
+
+	// Pick the most frequent condition and the earliest one on ties.
+	for _, f := range fc {
+		if !f.Time.Before(end) {
+			break
+		}
+		if counts[f.Forecast1H] > counts[cond] {
+			cond = f.Forecast1H
+		}
+	}
+
+	t := fc[0].Time.In(zone)
+	return fmt.Sprintf("%s 1 TXT \"%s (%s)\" \"high %0.1fC (%0.1fF)\" \"low %0.1fC (%0.1fF)\" \"%s\" \"next 24h from %s\"",
+		q, l.Name, l.Country, high, high*1.8+32, low, low*1.8+32,
+		w.condition(cond, lang), t.Format("15:04, ")+i18n.Weekday(lang, t.Weekday()))
+}
+
 // Dump produces a gob dump of the cached data.
 func (w *Weather) Dump() ([]byte, error) {
-	buf := &bytes.Buffer{}
+	data := make(map[string]entry, w.data.Len())
+	w.data.Range(func(id string, e cache.Entry) {
+		data[id] = e.(entry)
+	})
 
-	w.mut.RLock()
-	defer w.mut.RUnlock()
-	if err := gob.NewEncoder(buf).Encode(w.data); err != nil {
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(data); err != nil {
 		return nil, err
 	}
 
 	return buf.Bytes(), nil
 }
 
-// Load loads a gob dump of cached data.
+// Load loads a gob dump of cached data. Entries that have
+// already expired are discarded.
 func (w *Weather) Load(b []byte) error {
-	buf := bytes.NewBuffer(b)
+	var (
+		buf  = bytes.NewBuffer(b)
+		data map[string]entry
+	)
+	if err := gob.NewDecoder(buf).Decode(&data); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for id, e := range data {
+		if e.ExpiresAt.After(now) {
+			w.data.Set(id, e)
+		}
+	}
+
+	return nil
+}
+
+// debug logs a message if debug logging is enabled.
+func (w *Weather) debug(format string, v ...interface{}) {
+	if w.opt.Debug {
+		log.Printf("weather: "+format, v...)
+	}
+}
+
+// apparentTemp returns the "feels like" temperature (C) for a temperature (C),
+// relative humidity (%) and wind speed (m/s) using the Australian Bureau of
+// Meteorology's formula as the API doesn't provide it.
+// http://www.bom.gov.au/info/thermal_stress/#atapproximation
+func apparentTemp(t, rh, ws float32) float32 {
+	// Water vapour pressure (hPa).
+	e := float64(rh) / 100 * 6.105 * math.Exp(17.27*float64(t)/(237.7+float64(t)))
 
-	w.mut.RLock()
-	defer w.mut.RUnlock()
+	return float32(float64(t) + 0.33*e - 0.70*float64(ws) - 4.00)
+}
 
-	err := gob.NewDecoder(buf).Decode(&w.data)
-	return err
+// Stop stops the background fetches.
+func (w *Weather) Stop() {
+	w.cancel()
 }
 
 func (w *Weather) runFetchQueue() {
 	for {
 		select {
+		case <-w.ctx.Done():
+			return
+
 		case l := <-w.fetchQueue:
+			// The location may have been queued multiple times. Throttled
+			// fetches shouldn't use up the rate limit.
+			e, ok := w.cached(l.ID)
+			if ok && w.throttled(e) {
+				w.debug("fetch throttled: %s (%s)", l.Name, l.ID)
+				continue
+			}
+
 			if !w.limiter.Allow() {
 				log.Println("weather API rate limit exceeded")
 				continue
 			}
 
-			res, err := w.fetchAPI(l.Lat, l.Lon)
+			res, err := w.fetchAPI(w.ctx, l.Lat, l.Lon)
+			if w.ctx.Err() != nil {
+				return
+			}
 
 			// Even if it's an error, cache to avoid flooding the service.
-			w.mut.Lock()
-			w.data[l.ID] = res
-			w.mut.Unlock()
+			w.data.Set(l.ID, res)
 
 			if err != nil {
 				log.Printf("error fetching weather API: %v", err)
@@ -236,45 +612,77 @@ func (w *Weather) runFetchQueue() {
 	}
 }
 
-func (w *Weather) get(l geo.Location) (entry, error) {
-	w.mut.RLock()
-	data, ok := w.data[l.ID]
-	w.mut.RUnlock()
+// get returns the cached data for a location and whether it was a cache hit.
+func (w *Weather) get(l geo.Location) (entry, bool, error) {
+	data, ok := w.cached(l.ID)
+
+	hit := ok && data.ExpiresAt.After(time.Now())
+	if hit {
+		w.debug("cache hit: %s (%s)", l.Name, l.ID)
+	} else {
+		w.debug("cache miss: %s (%s)", l.Name, l.ID)
 
-	if !ok || data.ExpiresAt.Before(time.Now()) {
 		// If data is cached but has expired, return the existing data
-		// to respond instantly but queue re-fetch in the background to
-		// update it for the next request.
-		select {
-		case w.fetchQueue <- l:
-		default:
+		// (if it's within the stale grace window) to respond instantly but
+		// queue re-fetch in the background to update it for the next request.
+		// Locations that were fetched recently aren't re-fetched to protect
+		// the upstream's quota.
+		if ok && w.throttled(data) {
+			w.debug("fetch throttled: %s (%s)", l.Name, l.ID)
+		} else {
+			select {
+			case w.fetchQueue <- l:
+			default:
+			}
 		}
 
 		// Set the expiry date to the future to not send further
 		// requests for the same location until the fetch queue is processed.
 		data.ExpiresAt = time.Now().Add(time.Minute)
-		w.mut.Lock()
-		w.data[l.ID] = data
-		w.mut.Unlock()
+		w.data.Set(l.ID, data)
 	}
 
-	if !ok {
-		return entry{}, errQueued
+	// The placeholder expiry above makes too stale entries look like hits
+	// for a minute, so staleness is checked on hits too.
+	if !ok || w.tooStale(data) {
+		return entry{}, false, errQueued
 	}
 
 	if !data.Valid {
-		return entry{}, errors.New("weather data is unavailable. Try again in a few seconds.")
+		return entry{}, false, errcode.New(errcode.Unavailable, "weather data is unavailable. Try again in a few seconds.")
+	}
+
+	return data, hit, nil
+}
+
+// throttled checks if an entry was fetched within the min fetch interval.
+func (w *Weather) throttled(e entry) bool {
+	return w.opt.MinFetchInterval > 0 && time.Since(e.FetchedAt) < w.opt.MinFetchInterval
+}
+
+// tooStale checks if an entry's data is older than the cache TTL and the
+// stale grace window.
+func (w *Weather) tooStale(e entry) bool {
+	return w.opt.StaleGrace > 0 && time.Since(e.FetchedAt) > w.opt.CacheTTL+w.opt.StaleGrace
+}
+
+// cached returns the cached entry for a location ID, expired or not.
+func (w *Weather) cached(id string) (entry, bool) {
+	e, ok := w.data.Get(id)
+	if !ok {
+		return entry{}, false
 	}
 
-	return data, nil
+	return e.(entry), true
 }
 
-func (w *Weather) fetchAPI(lat, lon float64) (entry, error) {
+func (w *Weather) fetchAPI(ctx context.Context, lat, lon float64) (entry, error) {
 	// If the request fails, still cache the bad result with a TTL to avoid
 	// flooding the upstream with subsequent requests.
-	bad := entry{Valid: false, ExpiresAt: time.Now().Add(time.Minute * 10)}
+	bad := entry{Valid: false, ExpiresAt: time.Now().Add(time.Minute * 10), FetchedAt: time.Now()}
 
-	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(apiURL, lat, lon), nil)
+	u := fmt.Sprintf("%s?lat=%0.5f&lon=%0.5f", w.opt.BaseURL, lat, lon)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
 	if err != nil {
 		return bad, err
 	}
@@ -282,18 +690,21 @@ func (w *Weather) fetchAPI(lat, lon float64) (entry, error) {
 	req.Header.Add("User-Agent", w.opt.UserAgent)
 	req.Header.Add("Accept-Encoding", "gzip")
 
+	start := time.Now()
 	r, err := w.client.Do(req)
 	if err != nil {
 		return bad, err
 	}
+	w.debug("fetched %s: %d in %v", u, r.StatusCode, time.Since(start))
 	defer func() {
 		// Drain and close the body to let the Transport reuse the connection
 		io.Copy(ioutil.Discard, r.Body)
 		r.Body.Close()
 	}()
 
+	// Mirrors may not compress the response despite Accept-Encoding.
 	var body []byte
-	if !r.Uncompressed {
+	if r.Header.Get("Content-Encoding") == "gzip" {
 		defer r.Body.Close()
 		reader, err := gzip.NewReader(r.Body)
 		if err != nil {
@@ -320,7 +731,7 @@ func (w *Weather) fetchAPI(lat, lon float64) (entry, error) {
 	// Even if the request failed, still cache the invalid request with a TTL
 	// so as to not bombard the API.
 	if r.StatusCode == http.StatusForbidden || r.StatusCode == http.StatusTooManyRequests {
-		return bad, errors.New("error fetching weather data.")
+		return bad, errcode.New(errcode.Unavailable, "error fetching weather data.")
 	}
 
 	var data apiData
@@ -336,22 +747,38 @@ func (w *Weather) fetchAPI(lat, lon float64) (entry, error) {
 
 	out := entry{
 		ExpiresAt: time.Now().Add(w.opt.CacheTTL),
+		FetchedAt: time.Now(),
 		Valid:     true,
 	}
 
 	now := time.Now()
 	for _, p := range data.Properties.Timeseries {
-		// Skip stale entries.
-		if p.Time.Before(now) {
+		// Skip stale entries and the ones with missing data.
+		d := p.Data.Instant.Details
+		if p.Time.Before(now) || d.AirTemperature == nil {
 			continue
 		}
 
+		// The 1 hour summary is absent for the farther timesteps.
+		cond := p.Data.Next1Hours.Summary.SymbolCode
+		if cond == "" {
+			cond = p.Data.Next6Hours.Summary.SymbolCode
+		}
+		if cond == "" {
+			cond = p.Data.Next12Hours.Summary.SymbolCode
+		}
+
+		temp := *d.AirTemperature
 		f := forecast{
 			Time:       p.Time,
-			TempC:      p.Data.Instant.Details.AirTemperature,
-			TempF:      (p.Data.Instant.Details.AirTemperature * 1.8) + 32.0,
-			Forecast1H: p.Data.Next1Hours.Summary.SymbolCode,
-			Humidity:   p.Data.Instant.Details.RelativeHumidity,
+			TempC:      temp,
+			TempF:      (temp * 1.8) + 32.0,
+			Forecast1H: cond,
+			Humidity:   d.RelativeHumidity,
+			WindSpeed:  d.WindSpeed,
+			WindDir:    d.WindFromDir,
+			UV:         d.UV,
+			FeelsC:     apparentTemp(temp, d.RelativeHumidity, d.WindSpeed),
 		}
 
 		// Only pick up entries with with a certain gap.
@@ -369,5 +796,11 @@ func (w *Weather) fetchAPI(lat, lon float64) (entry, error) {
 		}
 	}
 
+	// Return whatever forecasts are available if the API's data is partial.
+	if len(out.Forecasts) == 0 {
+		return bad, errors.New("no forecasts in response")
+	}
+	out.Missing = w.opt.MaxEntries - len(out.Forecasts)
+
 	return out, nil
 }
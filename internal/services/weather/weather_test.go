@@ -0,0 +1,651 @@
+package weather
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/cache"
+	"github.com/knadh/dns.toys/internal/errcode"
+	"github.com/knadh/dns.toys/internal/extra"
+	"github.com/knadh/dns.toys/internal/geo"
+	"github.com/knadh/dns.toys/internal/i18n"
+)
+
+// newTest returns a Weather without the fetch queue worker and the
+// upstream so that the cache can be tested in isolation.
+func newTest(o Opt) *Weather {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Weather{
+		data:       cache.New(o.CacheSize),
+		geocodes:   cache.New(o.CacheSize),
+		fetchQueue: make(chan geo.Location, 100),
+		conditions: defaultConditions,
+		ctx:        ctx,
+		cancel:     cancel,
+		opt:        o,
+	}
+}
+
+func TestStaleGrace(t *testing.T) {
+	var (
+		ttl   = time.Hour
+		grace = time.Hour * 6
+		loc   = geo.Location{ID: "1", Name: "Berlin"}
+	)
+
+	tests := []struct {
+		name    string
+		fetched time.Duration
+		queued  bool
+		hit     bool
+	}{
+		{"fresh", time.Minute, false, true},
+		{"stale within grace", ttl + time.Hour, false, false},
+		{"stale beyond grace", ttl + grace + time.Hour, true, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := newTest(Opt{CacheTTL: ttl, StaleGrace: grace})
+			fetched := time.Now().Add(-tc.fetched)
+			w.data.Set(loc.ID, entry{
+				Valid:     true,
+				FetchedAt: fetched,
+				ExpiresAt: fetched.Add(ttl),
+			})
+
+			// Query twice as the first miss marks the entry as pending.
+			for i := 0; i < 2; i++ {
+				_, hit, err := w.get(loc)
+				if tc.queued {
+					if err != errQueued {
+						t.Fatalf("query %d: expected errQueued, got %v", i, err)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("query %d: unexpected error: %v", i, err)
+				}
+				if i == 0 && hit != tc.hit {
+					t.Fatalf("expected hit=%v, got %v", tc.hit, hit)
+				}
+			}
+
+			// Expired entries should be queued for a refresh.
+			if !tc.hit && len(w.fetchQueue) == 0 {
+				t.Fatal("expired entry wasn't queued for a refresh")
+			}
+		})
+	}
+}
+
+func TestStaleGraceDisabled(t *testing.T) {
+	var (
+		w   = newTest(Opt{CacheTTL: time.Hour})
+		loc = geo.Location{ID: "1"}
+		old = time.Now().Add(-time.Hour * 24 * 30)
+	)
+	w.data.Set(loc.ID, entry{Valid: true, FetchedAt: old, ExpiresAt: old.Add(time.Hour)})
+
+	if _, _, err := w.get(loc); err != nil {
+		t.Fatalf("expected stale data without a grace limit, got %v", err)
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	tests := []struct {
+		in     string
+		fields bool
+		n      int
+	}{
+		{"temp", true, 1},
+		{"temp,wind", true, 2},
+		{"temp,wind,temp", true, 2},
+		{"london", false, 0},
+		{"52.52,13.40", false, 0},
+		{"temp,london", false, 0},
+		{"", false, 0},
+	}
+
+	for _, tc := range tests {
+		f, ok := parseFields(tc.in)
+		if ok != tc.fields || len(f) != tc.n {
+			t.Fatalf("%q: expected fields=%v (%d), got %v (%v)", tc.in, tc.fields, tc.n, ok, f)
+		}
+	}
+}
+
+func TestQueryCoordsPart(t *testing.T) {
+	w := newTest(Opt{CacheTTL: time.Hour})
+
+	// Coordinates after a city are a city and not a field list. Both are
+	// queued as there's no cached data.
+	out, err := w.Query(context.Background(), "52.52,13.40/48.85,2.35")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(out) != 2 || !strings.Contains(out[1], "being fetched") {
+		t.Fatalf("unexpected response: %v", out)
+	}
+}
+
+func TestQueryVariants(t *testing.T) {
+	w := newTest(Opt{CacheTTL: time.Hour})
+
+	// A single cached entry for the location holds the values in both
+	// units. The language, summary, and fields are applied when it's rendered.
+	ft := time.Now().UTC().Add(time.Hour).Truncate(time.Hour)
+	w.data.Set("52.52,13.40", entry{
+		Valid:     true,
+		ExpiresAt: time.Now().Add(time.Hour),
+		FetchedAt: time.Now(),
+		Forecasts: []forecast{{Time: ft, TempC: 20, TempF: 68, Forecast1H: "clearsky_day", Humidity: 50}},
+	})
+
+	tests := []struct {
+		q        string
+		contains []string
+		excludes []string
+	}{
+		{"52.52,13.40", []string{"20.00C (68.00F)", "50.00% hu.", "clear sky", i18n.Weekday("en", ft.Weekday())}, nil},
+		{"52.52,13.40/lang-de", []string{"20.00C (68.00F)", w.condition("clearsky_day", "de"), i18n.Weekday("de", ft.Weekday())}, []string{"clear sky"}},
+		{"52.52,13.40/temp", []string{"\"20.00C (68.00F)\" \"" + ft.Format("15:04")}, []string{"hu.", "clear sky"}},
+		{"52.52,13.40/summary", []string{"high 20.0C (68.0F)", "low 20.0C (68.0F)", "clear sky"}, []string{"hu."}},
+		{"52.52,13.40/summary/lang-fr", []string{"high 20.0C (68.0F)", w.condition("clearsky_day", "fr")}, []string{"clear sky"}},
+	}
+
+	for _, tc := range tests {
+		out, err := w.Query(context.Background(), tc.q)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.q, err)
+		}
+		if len(out) != 1 {
+			t.Fatalf("%s: expected one record, got %v", tc.q, out)
+		}
+		for _, s := range tc.contains {
+			if !strings.Contains(out[0], s) {
+				t.Errorf("%s: expected %q in %s", tc.q, s, out[0])
+			}
+		}
+		for _, s := range tc.excludes {
+			if strings.Contains(out[0], s) {
+				t.Errorf("%s: unexpected %q in %s", tc.q, s, out[0])
+			}
+		}
+	}
+
+	// All the variants were served from the one entry without fetching.
+	if n := w.data.Len(); n != 1 {
+		t.Fatalf("expected 1 cache entry, got %d", n)
+	}
+	if n := len(w.fetchQueue); n != 0 {
+		t.Fatalf("expected no fetches, got %d", n)
+	}
+}
+
+// apiFixture returns a response in yr.no's locationforecast format with
+// hourly forecasts, from the next hour, with the given instant details.
+func apiFixture(hours int, details string) string {
+	start := time.Now().UTC().Add(time.Hour).Truncate(time.Hour)
+
+	ts := make([]string, 0, hours)
+	for i := 0; i < hours; i++ {
+		ts = append(ts, fmt.Sprintf(`{"time": "%s", "data": {"instant": {"details": {%s}},
+			"next_1_hours": {"summary": {"symbol_code": "clearsky_day"}}}}`,
+			start.Add(time.Duration(i)*time.Hour).Format(time.RFC3339), details))
+	}
+
+	return `{"properties": {"meta": {"updated_at": "2022-01-01T00:00:00Z"}, "timeseries": [` + strings.Join(ts, ",") + `]}}`
+}
+
+// newAPI returns a test API server that responds with body and counts
+// the requests. The last request is sent on reqs.
+func newAPI(t *testing.T, body string) (*httptest.Server, *int32, chan *http.Request) {
+	var (
+		n    int32
+		reqs = make(chan *http.Request, 100)
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&n, 1)
+		select {
+		case reqs <- r:
+		default:
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &n, reqs
+}
+
+// queryWait queries until the response isn't the queued message.
+func queryWait(t *testing.T, w *Weather, q string) []string {
+	t.Helper()
+
+	for i := 0; i < 100; i++ {
+		out, err := w.Query(context.Background(), q)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", q, err)
+		}
+		if len(out) == 0 || !strings.Contains(out[0], "being fetched") {
+			return out
+		}
+		time.Sleep(time.Millisecond * 20)
+	}
+
+	t.Fatalf("%s: data wasn't fetched", q)
+	return nil
+}
+
+func TestBaseURL(t *testing.T) {
+	srv, n, reqs := newAPI(t, apiFixture(3, `"air_temperature": 21.5, "relative_humidity": 40, "wind_speed": 3, "wind_from_direction": 90`))
+
+	w, err := New(Opt{
+		BaseURL:          srv.URL + "/forecast",
+		UserAgent:        "dnstoys-test",
+		CacheTTL:         time.Hour,
+		ReqTimeout:       time.Second,
+		MaxEntries:       3,
+		ForecastInterval: time.Hour,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	out := queryWait(t, w, "52.52,13.40")
+	if len(out) != 3 || !strings.Contains(out[0], "21.50C") || !strings.Contains(out[0], "3.0m/s E") {
+		t.Fatalf("unexpected response: %v", out)
+	}
+
+	r := <-reqs
+	if r.URL.Path != "/forecast" || r.URL.Query().Get("lat") != "52.52000" || r.URL.Query().Get("lon") != "13.40000" {
+		t.Fatalf("unexpected request: %s", r.URL)
+	}
+	if ua := r.Header.Get("User-Agent"); ua != "dnstoys-test" {
+		t.Fatalf("unexpected user agent: %s", ua)
+	}
+
+	// Served from the cache.
+	queryWait(t, w, "52.52,13.40")
+	if c := atomic.LoadInt32(n); c != 1 {
+		t.Fatalf("expected 1 API request, got %d", c)
+	}
+}
+
+func TestDumpLoad(t *testing.T) {
+	var (
+		w   = newTest(Opt{CacheTTL: time.Hour})
+		now = time.Now().Round(0)
+		e   = entry{
+			Valid:     true,
+			ExpiresAt: now.Add(time.Hour),
+			FetchedAt: now,
+			Forecasts: []forecast{{Time: now.Add(time.Hour), TempC: 20, TempF: 68, Forecast1H: "rain"}},
+			Missing:   2,
+		}
+	)
+	w.data.Set("1", e)
+	w.data.Set("2", entry{Valid: true, ExpiresAt: now.Add(-time.Minute), FetchedAt: now.Add(-time.Hour)})
+
+	b, err := w.Dump()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Expired entries are dropped on load.
+	w2 := newTest(Opt{CacheTTL: time.Hour})
+	if err := w2.Load(b); err != nil {
+		t.Fatal(err)
+	}
+	if n := w2.data.Len(); n != 1 {
+		t.Fatalf("expected 1 entry, got %d", n)
+	}
+
+	out, ok := w2.cached("1")
+	if !ok || !out.ExpiresAt.Equal(e.ExpiresAt) || !out.FetchedAt.Equal(e.FetchedAt) ||
+		len(out.Forecasts) != 1 || out.Forecasts[0] != e.Forecasts[0] || out.Missing != 2 || !out.Valid {
+		t.Fatalf("entry didn't round trip: %+v", out)
+	}
+
+	if err := w2.Load([]byte("junk")); err == nil {
+		t.Fatal("expected an error for an invalid dump")
+	}
+}
+
+func TestFeelsHumidity(t *testing.T) {
+	tests := []struct {
+		details  string
+		contains []string
+		excludes []string
+	}{
+		{
+			`"air_temperature": 25, "relative_humidity": 60, "wind_speed": 2, "ultraviolet_index_clear_sky": 5.5`,
+			[]string{"25.00C", fmt.Sprintf("feels %0.1fC", apparentTemp(25, 60, 2)), "60.00% hu.", "uv 5.5"},
+			nil,
+		},
+
+		// The UV index is omitted if the API doesn't return it.
+		{
+			`"air_temperature": 10, "relative_humidity": 80, "wind_speed": 8`,
+			[]string{"10.00C", fmt.Sprintf("feels %0.1fC", apparentTemp(10, 80, 8)), "80.00% hu."},
+			[]string{"uv"},
+		},
+	}
+
+	for _, tc := range tests {
+		srv, _, _ := newAPI(t, apiFixture(1, tc.details))
+		w, err := New(Opt{BaseURL: srv.URL, CacheTTL: time.Hour, ReqTimeout: time.Second, MaxEntries: 1}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out := queryWait(t, w, "52.52,13.40")
+		w.Stop()
+		if len(out) != 1 {
+			t.Fatalf("%s: unexpected response: %v", tc.details, out)
+		}
+		for _, s := range tc.contains {
+			if !strings.Contains(out[0], s) {
+				t.Errorf("%s: expected %q in %s", tc.details, s, out[0])
+			}
+		}
+		for _, s := range tc.excludes {
+			if strings.Contains(out[0], s) {
+				t.Errorf("%s: unexpected %q in %s", tc.details, s, out[0])
+			}
+		}
+	}
+}
+
+func TestApparentTemp(t *testing.T) {
+	tests := []struct {
+		temp, humidity, wind float32
+		feels                float32
+	}{
+		// Humid air feels warmer and wind feels colder.
+		{30, 80, 0, 37.2},
+		{30, 20, 0, 28.8},
+		{5, 50, 10, -4.6},
+	}
+
+	for _, tc := range tests {
+		if f := apparentTemp(tc.temp, tc.humidity, tc.wind); f < tc.feels-0.1 || f > tc.feels+0.1 {
+			t.Errorf("%v: expected %0.1f, got %0.1f", tc, tc.feels, f)
+		}
+	}
+}
+
+func TestDebugCache(t *testing.T) {
+	for _, debug := range []bool{false, true} {
+		w := newTest(Opt{CacheTTL: time.Hour, Debug: debug})
+		w.data.Set("52.52,13.40", entry{
+			Valid:     true,
+			ExpiresAt: time.Now().Add(time.Hour),
+			FetchedAt: time.Now(),
+			Forecasts: []forecast{{Time: time.Now().Add(time.Hour), TempC: 20, TempF: 68}},
+		})
+
+		out, err := w.Query(context.Background(), "52.52,13.40")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The debug line is a supplementary record.
+		ans, ext := extra.Split(out)
+		if len(ans) != 1 {
+			t.Fatalf("debug=%v: unexpected answers: %v", debug, ans)
+		}
+		if !debug {
+			if len(ext) != 0 {
+				t.Fatalf("unexpected debug records: %v", ext)
+			}
+			continue
+		}
+		if len(ext) != 1 || !strings.Contains(ext[0], "debug: cache hit, ttl ") {
+			t.Fatalf("expected a cache hit debug record, got %v", ext)
+		}
+	}
+}
+
+func TestPartialForecast(t *testing.T) {
+	// 3 of the 5 forecasts, where one of them has no temperature.
+	body := strings.Replace(apiFixture(3, `"air_temperature": 20`), `"air_temperature": 20`, `"relative_humidity": 50`, 1)
+	srv, _, _ := newAPI(t, body)
+
+	w, err := New(Opt{BaseURL: srv.URL, CacheTTL: time.Hour, ReqTimeout: time.Second, MaxEntries: 5, ForecastInterval: time.Hour}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	out := queryWait(t, w, "52.52,13.40")
+	if len(out) != 3 {
+		t.Fatalf("expected 2 forecasts and a note, got %v", out)
+	}
+	for _, r := range out[:2] {
+		if !strings.Contains(r, "20.00C") {
+			t.Fatalf("unexpected forecast: %s", r)
+		}
+	}
+	if !strings.Contains(out[2], "forecast unavailable for 3 later periods") {
+		t.Fatalf("expected a note on the missing forecasts, got %s", out[2])
+	}
+
+	// Summaries don't have the note.
+	if out := queryWait(t, w, "52.52,13.40/summary"); len(out) != 1 || !strings.Contains(out[0], "high 20.0C") {
+		t.Fatalf("unexpected summary: %v", out)
+	}
+}
+
+func TestNoForecasts(t *testing.T) {
+	// Responses without any forecast aren't served.
+	srv, _, _ := newAPI(t, apiFixture(2, `"relative_humidity": 50`))
+
+	w, err := New(Opt{BaseURL: srv.URL, CacheTTL: time.Hour, ReqTimeout: time.Second, MaxEntries: 5, ForecastInterval: time.Hour}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	for i := 0; i < 100; i++ {
+		_, err = w.Query(context.Background(), "52.52,13.40")
+		if err != nil {
+			break
+		}
+		time.Sleep(time.Millisecond * 20)
+	}
+	if errcode.Of(err) != errcode.Unavailable {
+		t.Fatalf("expected %s, got %v", errcode.Unavailable, err)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	var (
+		w   = newTest(Opt{})
+		l   = geo.Location{Name: "Berlin", Country: "DE"}
+		now = time.Date(2022, 6, 1, 9, 0, 0, 0, time.UTC)
+	)
+
+	fc := func(hours []int, temps []float32, conds ...string) []forecast {
+		out := make([]forecast, len(hours))
+		for i, h := range hours {
+			out[i] = forecast{Time: now.Add(time.Duration(h) * time.Hour), TempC: temps[i], Forecast1H: conds[i]}
+		}
+		return out
+	}
+
+	tests := []struct {
+		name string
+		fc   []forecast
+		out  string
+	}{
+		{"single",
+			fc([]int{0}, []float32{20}, "clearsky_day"),
+			`"high 20.0C (68.0F)" "low 20.0C (68.0F)" "clear sky" "next 24h from 09:00, Wed"`},
+		{"high low and the most frequent condition",
+			fc([]int{0, 6, 12, 18}, []float32{12, 25, 18, -5}, "clearsky_day", "rain", "rain", "cloudy"),
+			`"high 25.0C (77.0F)" "low -5.0C (23.0F)" "rain"`},
+		{"earliest condition on ties",
+			fc([]int{0, 6, 12, 18}, []float32{10, 10, 10, 10}, "cloudy", "rain", "rain", "cloudy"),
+			`"cloudy"`},
+		{"forecasts beyond 24 hours are ignored",
+			fc([]int{0, 12, 24, 30}, []float32{10, 15, 30, -10}, "rain", "cloudy", "clearsky_day", "clearsky_day"),
+			`"high 15.0C (59.0F)" "low 10.0C (50.0F)" "rain"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out := w.summarize("berlin.", l, tc.fc, time.UTC, "en")
+			if !strings.HasPrefix(out, `berlin. 1 TXT "Berlin (DE)" `) {
+				t.Fatalf("unexpected record: %s", out)
+			}
+			if !strings.Contains(out, tc.out) {
+				t.Fatalf("expected %s in %s", tc.out, out)
+			}
+		})
+	}
+}
+
+func TestSummaryOnly(t *testing.T) {
+	ft := time.Now().UTC().Add(time.Hour).Truncate(time.Hour)
+	e := entry{
+		Valid:     true,
+		ExpiresAt: time.Now().Add(time.Hour),
+		FetchedAt: time.Now(),
+		Forecasts: []forecast{
+			{Time: ft, TempC: 20, TempF: 68, Forecast1H: "clearsky_day"},
+			{Time: ft.Add(time.Hour), TempC: 22, TempF: 71.6, Forecast1H: "clearsky_day"},
+		},
+	}
+
+	for _, o := range []bool{false, true} {
+		w := newTest(Opt{CacheTTL: time.Hour, SummaryOnly: o})
+		w.data.Set("52.52,13.40", e)
+
+		out, err := w.Query(context.Background(), "52.52,13.40")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The detailed records by default and a single summary otherwise.
+		if !o {
+			if len(out) != 2 || strings.Contains(out[0], "high") {
+				t.Fatalf("expected 2 forecasts, got %v", out)
+			}
+			continue
+		}
+		if len(out) != 1 || !strings.Contains(out[0], `"high 22.0C (71.6F)" "low 20.0C (68.0F)"`) {
+			t.Fatalf("expected a summary, got %v", out)
+		}
+	}
+}
+
+func TestFetchThrottle(t *testing.T) {
+	loc := geo.Location{ID: "1", Name: "Berlin"}
+
+	tests := []struct {
+		name     string
+		interval time.Duration
+		fetched  time.Duration
+		queued   bool
+	}{
+		{"disabled", 0, time.Second, true},
+		{"within the interval", time.Minute, time.Second, false},
+		{"after the interval", time.Minute, time.Minute * 2, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := newTest(Opt{CacheTTL: time.Millisecond, MinFetchInterval: tc.interval})
+
+			// An expired entry is served and queued for a re-fetch
+			// unless it was fetched recently.
+			fetched := time.Now().Add(-tc.fetched)
+			w.data.Set(loc.ID, entry{Valid: true, FetchedAt: fetched, ExpiresAt: fetched.Add(time.Millisecond)})
+
+			if _, _, err := w.get(loc); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if queued := len(w.fetchQueue) == 1; queued != tc.queued {
+				t.Fatalf("expected queued=%v, got %v", tc.queued, queued)
+			}
+		})
+	}
+}
+
+func TestFetchThrottleQueue(t *testing.T) {
+	srv, n, _ := newAPI(t, apiFixture(1, `"air_temperature": 20`))
+
+	w, err := New(Opt{BaseURL: srv.URL, CacheTTL: time.Millisecond, ReqTimeout: time.Second,
+		MaxEntries: 5, ForecastInterval: time.Hour, MinFetchInterval: time.Minute}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	// Queued duplicates of a recently fetched location aren't fetched.
+	loc := geo.Location{ID: "1", Name: "Berlin"}
+	w.data.Set(loc.ID, entry{Valid: true, FetchedAt: time.Now(), ExpiresAt: time.Now()})
+	for i := 0; i < 3; i++ {
+		w.fetchQueue <- loc
+	}
+	time.Sleep(time.Millisecond * 50)
+	if c := atomic.LoadInt32(n); c != 0 {
+		t.Fatalf("expected no fetches, got %d", c)
+	}
+
+	// Once the interval has passed, it's fetched again.
+	w.data.Set(loc.ID, entry{Valid: true, FetchedAt: time.Now().Add(-time.Hour), ExpiresAt: time.Now()})
+	w.fetchQueue <- loc
+	time.Sleep(time.Millisecond * 50)
+	if c := atomic.LoadInt32(n); c != 1 {
+		t.Fatalf("expected 1 fetch, got %d", c)
+	}
+}
+
+func TestMultiCity(t *testing.T) {
+	w, n := newWarmupTest(t)
+	w.Warmup([]string{"london", "paris"})
+
+	out, err := w.Query(context.Background(), "london/atlantis/paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A record per city in the query's order with the unknown city's
+	// error inline.
+	exp := []string{
+		`london 1 TXT "London (GB)"`,
+		`atlantis 1 TXT "error: E_NOT_FOUND unknown city or airport code."`,
+		`paris 1 TXT "Paris (FR)"`,
+	}
+	if len(out) != len(exp) {
+		t.Fatalf("expected %d records, got %v", len(exp), out)
+	}
+	for i, e := range exp {
+		if !strings.HasPrefix(out[i], e) {
+			t.Fatalf("record %d: expected %s, got %s", i, e, out[i])
+		}
+	}
+
+	// The cities are cached independently and aren't fetched again.
+	c := atomic.LoadInt32(n)
+	if out, err := w.Query(context.Background(), "paris"); err != nil || len(out) != 1 {
+		t.Fatalf("unexpected response for a single city: %v, %v", out, err)
+	}
+	if atomic.LoadInt32(n) != c {
+		t.Fatal("expected the cached city to not be fetched again")
+	}
+
+	// The city count is capped.
+	if _, err := w.Query(context.Background(), "a/b/c/d/e/f"); errcode.Of(err) != errcode.Limit {
+		t.Fatalf("expected %s for too many cities, got %v", errcode.Limit, err)
+	}
+}
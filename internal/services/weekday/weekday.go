@@ -0,0 +1,115 @@
+// package weekday returns the day of the week, the ISO week, and the day
+// of the year of a date.
+package weekday
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/args"
+	"github.com/knadh/dns.toys/internal/errcode"
+	"github.com/knadh/dns.toys/internal/geo"
+)
+
+const layout = "2006-01-02"
+
+var errInvalid = errors.New("invalid query. Use yyyy-mm-dd, today, or today/city/country-code.")
+
+// Weekday looks up dates.
+type Weekday struct {
+	geo *geo.Geo
+}
+
+// New returns a new instance of Weekday.
+func New(g *geo.Geo) *Weekday {
+	return &Weekday{
+		geo: g,
+	}
+}
+
+// Query returns the day of the week, the ISO week, and the day of the year
+// of a date. today is the current date in UTC or in an optional city.
+// Format: $date, today, today/$city, or today/$city/$country,
+// eg: 2024-07-04, today/berlin, today/paris/fr.
+func (w *Weekday) Query(ctx context.Context, q string) ([]string, error) {
+	str, err := args.Split(strings.ToLower(q), 3)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		t    time.Time
+		name string
+	)
+	if str[0] == "today" {
+		zone := time.UTC
+		if len(str) > 1 {
+			loc, err := w.lookup(str[1:])
+			if err != nil {
+				return nil, err
+			}
+
+			z, err := time.LoadLocation(loc.Timezone)
+			if err != nil {
+				return nil, errcode.New(errcode.NotFound, "unknown timezone for city.")
+			}
+			zone, name = z, fmt.Sprintf("%s (%s)", loc.Name, loc.Country)
+		}
+
+		y, m, d := time.Now().In(zone).Date()
+		t = time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	} else {
+		// Cities only apply to today.
+		if len(str) > 1 {
+			return nil, errInvalid
+		}
+
+		t, err = time.Parse(layout, str[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid date '%s'. Use yyyy-mm-dd.", str[0])
+		}
+	}
+
+	// Days in the year.
+	days := 365
+	if y := t.Year(); y%4 == 0 && (y%100 != 0 || y%400 == 0) {
+		days = 366
+	}
+
+	year, week := t.ISOWeek()
+	r := fmt.Sprintf("%s 1 TXT \"%s\" \"%s\" \"week %d-W%02d\" \"day %d of %d\"",
+		q, t.Weekday(), t.Format(layout), year, week, t.YearDay(), days)
+	if name != "" {
+		r += fmt.Sprintf(" \"%s\"", name)
+	}
+
+	return []string{r}, nil
+}
+
+// Dump is not implemented in this package.
+func (w *Weekday) Dump() ([]byte, error) {
+	return nil, nil
+}
+
+// lookup returns the most populous location for a city and an optional
+// 2-letter country code.
+func (w *Weekday) lookup(str []string) (geo.Location, error) {
+	country := ""
+	if len(str) == 2 {
+		if len(str[1]) != 2 {
+			return geo.Location{}, errInvalid
+		}
+		country = strings.ToUpper(str[1])
+	}
+
+	for _, l := range w.geo.Query(str[0]) {
+		if country == "" || l.Country == country {
+			return l, nil
+		}
+	}
+
+	return geo.Location{}, errcode.New(errcode.NotFound, "unknown city.")
+}
@@ -0,0 +1,246 @@
+// package whois returns basic registration facts for domains
+// from RDAP (the JSON successor to WHOIS).
+package whois
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/cache"
+	"github.com/knadh/dns.toys/internal/errcode"
+)
+
+var reDomain = regexp.MustCompile(`^([a-z0-9]([a-z0-9\-]*[a-z0-9])?\.)+[a-z]{2,63}$`)
+
+var (
+	errNotFound    = errcode.New(errcode.NotFound, "unknown domain or TLD.")
+	errRateLimited = errcode.New(errcode.Unavailable, "rate limited by the registry. Try again later.")
+)
+
+// Opt contains config options for Whois.
+type Opt struct {
+	// RDAP URL with a %s placeholder for the domain name.
+	APIURL string
+
+	// Max number of domains to cache. 0 for no limit.
+	CacheSize  int
+	CacheTTL   time.Duration
+	ReqTimeout time.Duration
+	UserAgent  string
+}
+
+type entry struct {
+	// False for domains that the registry doesn't know.
+	Found bool
+
+	Registrar string
+	Created   time.Time
+	Expires   time.Time
+	ExpiresAt time.Time
+}
+
+// Expiry returns the entry's expiry time for the cache.
+func (e entry) Expiry() time.Time {
+	return e.ExpiresAt
+}
+
+type apiData struct {
+	Events []struct {
+		Action string    `json:"eventAction"`
+		Date   time.Time `json:"eventDate"`
+	} `json:"events"`
+	Entities []struct {
+		Roles []string `json:"roles"`
+
+		// jCard: ["vcard", [["fn", {}, "text", "Registrar Inc."], ...]]
+		VCard []interface{} `json:"vcardArray"`
+	} `json:"entities"`
+}
+
+// Whois looks up domain registration facts.
+type Whois struct {
+	data *cache.Cache
+
+	opt    Opt
+	client *http.Client
+}
+
+// New returns a new instance of Whois.
+func New(o Opt) *Whois {
+	return &Whois{
+		data: cache.New(o.CacheSize),
+		opt:  o,
+		client: &http.Client{
+			Timeout: o.ReqTimeout,
+		},
+	}
+}
+
+// Query returns the registrar, creation, and expiry dates of a domain.
+// Format: $domain with dots or its last dot as a dash, eg: example.com, example-com.
+func (w *Whois) Query(ctx context.Context, q string) ([]string, error) {
+	domain := strings.ToLower(q)
+	if !strings.Contains(domain, ".") {
+		i := strings.LastIndex(domain, "-")
+		if i < 0 {
+			return nil, errors.New("invalid domain. eg: example-com or example.com")
+		}
+		domain = domain[:i] + "." + domain[i+1:]
+	}
+
+	if !reDomain.MatchString(domain) {
+		return nil, errors.New("invalid domain. eg: example-com or example.com")
+	}
+
+	e, err := w.get(ctx, domain)
+	if err != nil {
+		if err != errNotFound && err != errRateLimited {
+			log.Printf("error fetching whois: %v", err)
+			return nil, errcode.New(errcode.Unavailable, "whois data is unavailable. Try again later.")
+		}
+		return nil, err
+	}
+
+	out := []string{
+		fmt.Sprintf("%s 1 TXT \"domain %s\"", q, domain),
+	}
+	if e.Registrar != "" {
+		out = append(out, fmt.Sprintf("%s 1 TXT \"registrar %s\"", q, strings.ReplaceAll(e.Registrar, "\"", "")))
+	}
+	if !e.Created.IsZero() {
+		out = append(out, fmt.Sprintf("%s 1 TXT \"created %s\"", q, e.Created.Format("2006-01-02")))
+	}
+	if !e.Expires.IsZero() {
+		days := int(time.Until(e.Expires).Hours() / 24)
+		out = append(out, fmt.Sprintf("%s 1 TXT \"expires %s\" \"in %d days\"", q, e.Expires.Format("2006-01-02"), days))
+	}
+
+	return out, nil
+}
+
+// Dump is not implemented in this package.
+func (w *Whois) Dump() ([]byte, error) {
+	return nil, nil
+}
+
+// get returns the cached facts for a domain or fetches them from the API.
+func (w *Whois) get(ctx context.Context, domain string) (entry, error) {
+	e, ok := w.cached(domain)
+	if ok && e.ExpiresAt.After(time.Now()) {
+		if !e.Found {
+			return entry{}, errNotFound
+		}
+		return e, nil
+	}
+
+	e, err := w.fetchAPI(ctx, domain)
+	if err != nil && err != errNotFound {
+		return entry{}, err
+	}
+
+	// Cache unknown domains too so that they don't hit the API repeatedly.
+	w.data.Set(domain, e)
+
+	return e, err
+}
+
+// cached returns the cached entry for a key, expired or not.
+func (w *Whois) cached(id string) (entry, bool) {
+	e, ok := w.data.Get(id)
+	if !ok {
+		return entry{}, false
+	}
+
+	return e.(entry), true
+}
+
+func (w *Whois) fetchAPI(ctx context.Context, domain string) (entry, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(w.opt.APIURL, domain), nil)
+	if err != nil {
+		return entry{}, err
+	}
+	req.Header.Add("User-Agent", w.opt.UserAgent)
+	req.Header.Add("Accept", "application/rdap+json")
+
+	r, err := w.client.Do(req)
+	if err != nil {
+		return entry{}, err
+	}
+	defer r.Body.Close()
+
+	switch r.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return entry{ExpiresAt: time.Now().Add(time.Hour)}, errNotFound
+	case http.StatusTooManyRequests:
+		return entry{}, errRateLimited
+	default:
+		return entry{}, fmt.Errorf("request failed: %v", r.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return entry{}, err
+	}
+
+	var data apiData
+	if err := json.Unmarshal(body, &data); err != nil {
+		return entry{}, err
+	}
+
+	out := entry{
+		Found:     true,
+		ExpiresAt: time.Now().Add(w.opt.CacheTTL),
+	}
+	for _, e := range data.Events {
+		switch e.Action {
+		case "registration":
+			out.Created = e.Date
+		case "expiration":
+			out.Expires = e.Date
+		}
+	}
+
+	for _, e := range data.Entities {
+		for _, r := range e.Roles {
+			if r == "registrar" {
+				out.Registrar = vcardName(e.VCard)
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// vcardName returns the formatted name (fn) from a jCard.
+func vcardName(v []interface{}) string {
+	if len(v) != 2 {
+		return ""
+	}
+
+	props, ok := v[1].([]interface{})
+	if !ok {
+		return ""
+	}
+
+	for _, p := range props {
+		f, ok := p.([]interface{})
+		if !ok || len(f) != 4 || f[0] != "fn" {
+			continue
+		}
+
+		if s, ok := f[3].(string); ok {
+			return s
+		}
+	}
+
+	return ""
+}
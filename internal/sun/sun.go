@@ -0,0 +1,99 @@
+// Package sun computes the times at which the sun crosses given elevations
+// (sunrise, sunset, and the twilights) for a location on a date.
+// Algorithm: https://en.wikipedia.org/wiki/Sunrise_equation
+package sun
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// Solar elevations (in degrees) that mark the start and end of the phases.
+const (
+	// Sunrise and sunset, accounting for refraction and the sun's radius.
+	Horizon = -0.833
+
+	Civil        = -6.0
+	Nautical     = -12.0
+	Astronomical = -18.0
+)
+
+const (
+	// Julian date of the J2000 epoch, 2000-01-01 12:00 UTC.
+	j2000 = 2451545.0
+
+	// Julian date of the Unix epoch.
+	jUnix = 2440587.5
+
+	// Obliquity of the ecliptic.
+	obliquity = 23.4397
+)
+
+var (
+	// ErrAlwaysAbove is returned when the sun stays above the elevation
+	// for the whole day, eg: midnight sun.
+	ErrAlwaysAbove = errors.New("sun is always above the elevation")
+
+	// ErrAlwaysBelow is returned when the sun never reaches the elevation
+	// on the day, eg: polar night.
+	ErrAlwaysBelow = errors.New("sun is always below the elevation")
+)
+
+// Crossing returns the times at which the sun rises above and sets below
+// the given elevation (degrees) on the date (in its location) at the given
+// coordinates. The times are in the date's location.
+func Crossing(date time.Time, lat, lon, elev float64) (time.Time, time.Time, error) {
+	var (
+		// Days since J2000 at noon on the date.
+		y, m, d = date.Date()
+		noon    = time.Date(y, m, d, 12, 0, 0, 0, time.UTC)
+		n       = math.Round(toJulian(noon) - j2000 + 0.0008)
+
+		// Mean solar time.
+		js = n - lon/360
+
+		// Solar mean anomaly and the equation of the center.
+		ma = math.Mod(357.5291+0.98560028*js, 360)
+		c  = 1.9148*sin(ma) + 0.02*sin(2*ma) + 0.0003*sin(3*ma)
+
+		// Ecliptic longitude.
+		el = math.Mod(ma+c+180+102.9372, 360)
+
+		// Solar transit (noon).
+		transit = j2000 + js + 0.0053*sin(ma) - 0.0069*sin(2*el)
+
+		// Declination of the sun.
+		decl = math.Asin(sin(el) * sin(obliquity))
+	)
+
+	// Hour angle.
+	cosH := (sin(elev) - sin(lat)*math.Sin(decl)) / (cos(lat) * math.Cos(decl))
+	if cosH < -1 {
+		return time.Time{}, time.Time{}, ErrAlwaysAbove
+	}
+	if cosH > 1 {
+		return time.Time{}, time.Time{}, ErrAlwaysBelow
+	}
+
+	h := math.Acos(cosH) * 180 / math.Pi
+	loc := date.Location()
+
+	return fromJulian(transit - h/360).In(loc), fromJulian(transit + h/360).In(loc), nil
+}
+
+func toJulian(t time.Time) float64 {
+	return float64(t.Unix())/86400 + jUnix
+}
+
+func fromJulian(j float64) time.Time {
+	return time.Unix(int64(math.Round((j-jUnix)*86400)), 0)
+}
+
+func sin(deg float64) float64 {
+	return math.Sin(deg * math.Pi / 180)
+}
+
+func cos(deg float64) float64 {
+	return math.Cos(deg * math.Pi / 180)
+}